@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/habbazettt/nutrisnap-server/config"
 	"github.com/habbazettt/nutrisnap-server/internal/bootstrap"
 	"github.com/habbazettt/nutrisnap-server/pkg/database"
@@ -26,8 +31,15 @@ import (
 // @in							header
 // @name						Authorization
 // @description				JWT token dengan format: Bearer {token}
+// @securityDefinitions.apikey	APIKeyAuth
+// @in							header
+// @name						X-API-Key
+// @description				API key of a partner backend, accepted by the webhook endpoints
 func main() {
-	cfg := loadConfig()
+	role := flag.String("role", "", "Process role: api, worker or all (overrides PROCESS_ROLE)")
+	flag.Parse()
+
+	cfg := loadConfig(*role)
 
 	bootstrap.InitLogger(cfg)
 	bootstrap.InitDatabase(cfg)
@@ -35,24 +47,48 @@ func main() {
 	// Initialize dependency injection container
 	container := bootstrap.NewContainer()
 
-	// Start Background Workers
-	// We use 5 concurrent workers for OCR processing
-	container.OCRWorker.Start(5)
+	logger.Info("starting process", "role", cfg.Server.Role)
 
-	app := bootstrap.NewApp(container)
+	// Start Background Workers
+	// Each worker borrows a warm engine from the OCR pool
+	if cfg.Server.Role.RunsWorkers() {
+		container.OCRWorker.Start(cfg.OCR.Workers)
+		container.WebhookWorker.Start(cfg.Webhook.Workers)
+		container.IdempotencyPurgeJob.Start()
+		container.ProductMissPurgeJob.Start()
+		container.ReparseJob.Start()
+		if cfg.Cleanup.Enabled {
+			container.CleanupJob.Start()
+		}
+		if cfg.Storage.ReconcileEnabled {
+			container.ReconcileJob.Start()
+		}
+		if cfg.OFF.RefreshEnabled {
+			container.ProductRefreshJob.Start()
+		}
+	}
 
-	go startServer(app, cfg.Server.Port)
+	// Worker-only processes still serve health and metrics, on their own port
+	var app *fiber.App
+	if cfg.Server.Role.ServesAPI() {
+		container.ScanEventHub.Start()
+		app = bootstrap.NewApp(container)
+		go startServer(app, cfg.Server.Port)
+	} else {
+		app = bootstrap.NewWorkerApp(container)
+		go startServer(app, cfg.Server.WorkerPort)
+	}
 
 	waitForShutdown()
 
-	// Stop Background Workers
-	container.OCRWorker.Stop()
-
-	shutdown(app)
+	shutdown(app, container, cfg.Server.ShutdownTimeout)
 }
 
-func loadConfig() *config.Config {
+func loadConfig(role string) *config.Config {
 	cfg, err := config.Load()
+	if err == nil && role != "" {
+		cfg.Server.Role, err = config.ParseProcessRole(role)
+	}
 	if err != nil {
 		logger.Init(logger.Config{Level: "error", Format: "text", Environment: "development"})
 		logger.Error("failed to load configuration", "error", err)
@@ -75,11 +111,45 @@ func waitForShutdown() {
 	<-quit
 }
 
-func shutdown(app interface{ Shutdown() error }) {
-	logger.Info("shutting down server...")
+// shutdown stops the HTTP server, OCR and webhook workers, scheduled jobs
+// and event streams together, giving them one shared deadline to finish
+// what they are doing. The OCR pool and database are closed only after all
+// of them have returned.
+func shutdown(app interface {
+	ShutdownWithContext(ctx context.Context) error
+}, container *bootstrap.Container, timeout time.Duration) {
+	logger.Info("shutting down server...", "timeout", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	components := map[string]func(context.Context) error{
+		"http":                app.ShutdownWithContext,
+		"ocr_workers":         container.OCRWorker.Shutdown,
+		"webhook_workers":     container.WebhookWorker.Shutdown,
+		"cleanup_job":         container.CleanupJob.Shutdown,
+		"idempotency_job":     container.IdempotencyPurgeJob.Shutdown,
+		"reconcile_job":       container.ReconcileJob.Shutdown,
+		"product_refresh_job": container.ProductRefreshJob.Shutdown,
+		"product_miss_job":    container.ProductMissPurgeJob.Shutdown,
+		"reparse_job":         container.ReparseJob.Shutdown,
+		"scan_events":         container.ScanEventHub.Shutdown,
+	}
+
+	var wg sync.WaitGroup
+	for name, stop := range components {
+		wg.Add(1)
+		go func(name string, stop func(context.Context) error) {
+			defer wg.Done()
+			if err := stop(ctx); err != nil {
+				logger.Error("error during shutdown", "component", name, "error", err)
+			}
+		}(name, stop)
+	}
+	wg.Wait()
 
-	if err := app.Shutdown(); err != nil {
-		logger.Error("error during server shutdown", "error", err)
+	if container.OCRPool != nil {
+		container.OCRPool.Close()
 	}
 
 	if err := database.Close(); err != nil {
@@ -1,17 +1,159 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 )
 
 type Config struct {
-	Server     ServerConfig
-	Database   DatabaseConfig
-	JWT        JWTConfig
-	Google     GoogleOAuthConfig
-	Cloudinary CloudinaryConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	Google      GoogleOAuthConfig
+	Storage     StorageConfig
+	Cloudinary  CloudinaryConfig
+	S3          S3Config
+	OCR         OCRConfig
+	Queue       QueueConfig
+	Quality     QualityConfig
+	Cleanup     CleanupConfig
+	Webhook     WebhookConfig
+	Idempotency IdempotencyConfig
+	OFF         OpenFoodFactsConfig
+}
+
+type OpenFoodFactsConfig struct {
+	// BaseURL is the product API root, a country mirror or a local stub
+	BaseURL string
+	// Timeout bounds a whole lookup and AttemptTimeout each request in it
+	Timeout        time.Duration
+	AttemptTimeout time.Duration
+	MaxRetries     int
+	RetryDelay     time.Duration
+	RetryMaxDelay  time.Duration
+	// BreakerThreshold is how many failed lookups in a row stop calls to
+	// OFF for BreakerCooldown
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// MissTTL is how long a barcode OFF did not know is answered as not
+	// found without asking again, 0 to always ask
+	MissTTL time.Duration
+	// CacheTTL is how long a cached product is served before a lookup
+	// refreshes it in the background
+	CacheTTL time.Duration
+	// RefreshEnabled runs the job refreshing stale, frequently scanned products
+	RefreshEnabled  bool
+	RefreshInterval time.Duration
+	// RefreshBatch is how many products one run refreshes
+	RefreshBatch int
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long an Idempotency-Key and its response are kept
+	TTL time.Duration
+	// StaleAfter is how long a request may hold a key before a retry may
+	// take it over
+	StaleAfter time.Duration
+}
+
+type WebhookConfig struct {
+	Workers       int
+	PollInterval  time.Duration
+	Timeout       time.Duration
+	MaxAttempts   int
+	RetryDelay    time.Duration
+	RetryMaxDelay time.Duration
+	// DisableAfter is how many failed attempts in a row disable a webhook
+	DisableAfter int
+	// AllowPrivateTargets accepts receivers on loopback and private
+	// networks, never in production
+	AllowPrivateTargets bool
+}
+
+type CleanupConfig struct {
+	Enabled       bool
+	RetentionDays int
+	Interval      time.Duration
+}
+
+type QueueConfig struct {
+	PollInterval      time.Duration
+	JobTimeout        time.Duration
+	VisibilityTimeout time.Duration
+	MaxAttempts       int
+	RetryDelay        time.Duration
+	RetryMaxDelay     time.Duration
+	UserMaxInFlight   int
+}
+
+type QualityConfig struct {
+	Enabled   bool
+	MinWidth  int
+	MinHeight int
+	// MaxWidth, MaxHeight and MaxPixels are checked on every upload, even
+	// with the quality gate disabled, as are the minimum dimensions
+	MaxWidth       int
+	MaxHeight      int
+	MaxPixels      int
+	MinSharpness   float64
+	MaxGlare       float64
+	MinTextDensity float64
+}
+
+type OCRConfig struct {
+	Workers             int
+	PoolSize            int
+	EngineMaxUses       int
+	AcquireTimeout      time.Duration
+	HealthCheckInterval time.Duration
+	MultiPass           bool
+	MultiPassBudget     time.Duration
+}
+
+type StorageConfig struct {
+	// Backend is cloudinary, s3 or local
+	Backend string
+	// SignedURLTTL is how long image URLs handed to clients stay valid
+	SignedURLTTL time.Duration
+	// UploadURLTTL is how long direct upload targets stay valid
+	UploadURLTTL time.Duration
+	LocalDir     string
+	// SigningKey signs local storage URLs
+	SigningKey string
+	// ReconcileEnabled runs the job deleting orphaned objects and flagging
+	// scans whose images are gone
+	ReconcileEnabled  bool
+	ReconcileInterval time.Duration
+	// OrphanGracePeriod keeps unreferenced objects this young
+	OrphanGracePeriod time.Duration
+	// Quotas limit what users keep stored, keyed by role
+	Quotas map[string]StorageQuota
+	// QuotaEviction deletes a user's oldest images to make room for new
+	// ones instead of rejecting uploads over quota
+	QuotaEviction bool
+}
+
+// StorageQuota limits the stored images of a user. Zero means unlimited.
+type StorageQuota struct {
+	Bytes   int64
+	Objects int
+}
+
+type S3Config struct {
+	Endpoint string
+	// PublicURL is the endpoint clients reach, when it differs from Endpoint
+	PublicURL string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	UseSSL    bool
 }
 
 type CloudinaryConfig struct {
@@ -22,11 +164,48 @@ type CloudinaryConfig struct {
 	Folder    string
 }
 
+// ProcessRole selects which parts of the server a process runs
+type ProcessRole string
+
+const (
+	// RoleAPI serves HTTP requests and only enqueues scans
+	RoleAPI ProcessRole = "api"
+	// RoleWorker processes queued scans and runs scheduled jobs
+	RoleWorker ProcessRole = "worker"
+	// RoleAll runs both in one process
+	RoleAll ProcessRole = "all"
+)
+
+// ParseProcessRole validates a role name
+func ParseProcessRole(s string) (ProcessRole, error) {
+	switch role := ProcessRole(s); role {
+	case RoleAPI, RoleWorker, RoleAll:
+		return role, nil
+	default:
+		return "", fmt.Errorf("invalid role %q, must be api, worker or all", s)
+	}
+}
+
+// ServesAPI reports whether the process serves the HTTP API
+func (r ProcessRole) ServesAPI() bool {
+	return r == RoleAPI || r == RoleAll
+}
+
+// RunsWorkers reports whether the process runs OCR workers and scheduled jobs
+func (r ProcessRole) RunsWorkers() bool {
+	return r == RoleWorker || r == RoleAll
+}
+
 type ServerConfig struct {
 	Port        string
 	Environment string
 	LogLevel    string
 	BaseURL     string
+	// ShutdownTimeout bounds how long in-flight requests and scans may take to finish
+	ShutdownTimeout time.Duration
+	Role            ProcessRole
+	// WorkerPort serves health and metrics for worker-only processes
+	WorkerPort string
 }
 
 type DatabaseConfig struct {
@@ -56,10 +235,13 @@ var cfg *Config
 func Load() (*Config, error) {
 	cfg = &Config{
 		Server: ServerConfig{
-			Port:        getEnv("PORT", "3000"),
-			Environment: getEnv("ENV", "development"),
-			LogLevel:    getEnv("LOG_LEVEL", "info"),
-			BaseURL:     getEnv("BASE_URL", "http://localhost:3000"),
+			Port:            getEnv("PORT", "3000"),
+			Environment:     getEnv("ENV", "development"),
+			LogLevel:        getEnv("LOG_LEVEL", "info"),
+			BaseURL:         getEnv("BASE_URL", "http://localhost:3000"),
+			ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+			Role:            ProcessRole(getEnv("PROCESS_ROLE", string(RoleAll))),
+			WorkerPort:      getEnv("WORKER_PORT", "3100"),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", ""),
@@ -76,6 +258,98 @@ func Load() (*Config, error) {
 			URL:       getEnv("CLOUDINARY_URL", ""),
 			Folder:    getEnv("CLOUDINARY_FOLDER", "nutrisnap"),
 		},
+		Storage: StorageConfig{
+			Backend:           getEnv("STORAGE_BACKEND", ""),
+			SignedURLTTL:      getEnvDuration("STORAGE_SIGNED_URL_TTL", 15*time.Minute),
+			UploadURLTTL:      getEnvDuration("STORAGE_UPLOAD_URL_TTL", 15*time.Minute),
+			LocalDir:          getEnv("STORAGE_LOCAL_DIR", "./data/uploads"),
+			SigningKey:        getEnv("STORAGE_SIGNING_KEY", ""),
+			ReconcileEnabled:  getEnv("STORAGE_RECONCILE_ENABLED", "false") == "true",
+			ReconcileInterval: getEnvDuration("STORAGE_RECONCILE_INTERVAL", 24*time.Hour),
+			OrphanGracePeriod: getEnvDuration("STORAGE_ORPHAN_GRACE_PERIOD", 24*time.Hour),
+			Quotas: map[string]StorageQuota{
+				"user": {
+					Bytes:   int64(getEnvInt("STORAGE_QUOTA_USER_MB", 500)) << 20,
+					Objects: getEnvInt("STORAGE_QUOTA_USER_OBJECTS", 0),
+				},
+				"admin": {
+					Bytes:   int64(getEnvInt("STORAGE_QUOTA_ADMIN_MB", 0)) << 20,
+					Objects: getEnvInt("STORAGE_QUOTA_ADMIN_OBJECTS", 0),
+				},
+			},
+			QuotaEviction: getEnv("STORAGE_QUOTA_EVICTION", "false") == "true",
+		},
+		S3: S3Config{
+			Endpoint:  getEnv("S3_ENDPOINT", ""),
+			PublicURL: getEnv("S3_PUBLIC_URL", ""),
+			AccessKey: getEnv("S3_ACCESS_KEY", ""),
+			SecretKey: getEnv("S3_SECRET_KEY", ""),
+			Bucket:    getEnv("S3_BUCKET", "nutrisnap"),
+			UseSSL:    getEnv("S3_USE_SSL", "false") == "true",
+		},
+		OCR: OCRConfig{
+			Workers:             getEnvInt("OCR_WORKERS", 5),
+			PoolSize:            getEnvInt("OCR_POOL_SIZE", 0),
+			EngineMaxUses:       getEnvInt("OCR_ENGINE_MAX_USES", 200),
+			AcquireTimeout:      getEnvDuration("OCR_POOL_ACQUIRE_TIMEOUT", 30*time.Second),
+			HealthCheckInterval: getEnvDuration("OCR_POOL_HEALTH_INTERVAL", time.Minute),
+			MultiPass:           getEnv("OCR_MULTIPASS", "true") == "true",
+			MultiPassBudget:     getEnvDuration("OCR_MULTIPASS_BUDGET", 20*time.Second),
+		},
+		Queue: QueueConfig{
+			PollInterval:      getEnvDuration("SCAN_QUEUE_POLL_INTERVAL", time.Second),
+			JobTimeout:        getEnvDuration("SCAN_JOB_TIMEOUT", 2*time.Minute),
+			VisibilityTimeout: getEnvDuration("SCAN_JOB_VISIBILITY_TIMEOUT", 5*time.Minute),
+			MaxAttempts:       getEnvInt("SCAN_JOB_MAX_ATTEMPTS", 3),
+			RetryDelay:        getEnvDuration("SCAN_JOB_RETRY_DELAY", 15*time.Second),
+			RetryMaxDelay:     getEnvDuration("SCAN_JOB_RETRY_MAX_DELAY", 10*time.Minute),
+			UserMaxInFlight:   getEnvInt("SCAN_QUEUE_USER_MAX_IN_FLIGHT", 2),
+		},
+		Quality: QualityConfig{
+			Enabled:        getEnv("IMAGE_QUALITY_GATE", "true") == "true",
+			MinWidth:       getEnvInt("IMAGE_MIN_WIDTH", 200),
+			MinHeight:      getEnvInt("IMAGE_MIN_HEIGHT", 200),
+			MaxWidth:       getEnvInt("IMAGE_MAX_WIDTH", 12000),
+			MaxHeight:      getEnvInt("IMAGE_MAX_HEIGHT", 12000),
+			MaxPixels:      getEnvInt("IMAGE_MAX_PIXELS", 50_000_000),
+			MinSharpness:   getEnvFloat("IMAGE_MIN_SHARPNESS", 80),
+			MaxGlare:       getEnvFloat("IMAGE_MAX_GLARE", 0.25),
+			MinTextDensity: getEnvFloat("IMAGE_MIN_TEXT_DENSITY", 0.01),
+		},
+		Cleanup: CleanupConfig{
+			Enabled:       getEnv("IMAGE_CLEANUP_ENABLED", "false") == "true",
+			RetentionDays: getEnvInt("IMAGE_RETENTION_DAYS", 30),
+			Interval:      getEnvDuration("IMAGE_CLEANUP_INTERVAL", 24*time.Hour),
+		},
+		Webhook: WebhookConfig{
+			Workers:             getEnvInt("WEBHOOK_WORKERS", 2),
+			PollInterval:        getEnvDuration("WEBHOOK_POLL_INTERVAL", 2*time.Second),
+			Timeout:             getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:         getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			RetryDelay:          getEnvDuration("WEBHOOK_RETRY_DELAY", 30*time.Second),
+			RetryMaxDelay:       getEnvDuration("WEBHOOK_RETRY_MAX_DELAY", 6*time.Hour),
+			DisableAfter:        getEnvInt("WEBHOOK_DISABLE_AFTER", 20),
+			AllowPrivateTargets: getEnv("WEBHOOK_ALLOW_PRIVATE_TARGETS", "false") == "true",
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:        getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+			StaleAfter: getEnvDuration("IDEMPOTENCY_STALE_AFTER", 5*time.Minute),
+		},
+		OFF: OpenFoodFactsConfig{
+			BaseURL:          getEnv("OFF_BASE_URL", "https://world.openfoodfacts.org/api/v0/product"),
+			Timeout:          getEnvDuration("OFF_TIMEOUT", 6*time.Second),
+			AttemptTimeout:   getEnvDuration("OFF_ATTEMPT_TIMEOUT", 3*time.Second),
+			MaxRetries:       getEnvInt("OFF_MAX_RETRIES", 2),
+			RetryDelay:       getEnvDuration("OFF_RETRY_DELAY", 250*time.Millisecond),
+			RetryMaxDelay:    getEnvDuration("OFF_RETRY_MAX_DELAY", 2*time.Second),
+			BreakerThreshold: getEnvInt("OFF_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  getEnvDuration("OFF_BREAKER_COOLDOWN", 30*time.Second),
+			MissTTL:          getEnvDuration("OFF_MISS_TTL", 24*time.Hour),
+			CacheTTL:         getEnvDuration("OFF_CACHE_TTL", 7*24*time.Hour),
+			RefreshEnabled:   getEnv("OFF_REFRESH_ENABLED", "false") == "true",
+			RefreshInterval:  getEnvDuration("OFF_REFRESH_INTERVAL", 6*time.Hour),
+			RefreshBatch:     getEnvInt("OFF_REFRESH_BATCH", 100),
+		},
 		JWT: JWTConfig{
 			Secret:        getEnv("JWT_SECRET", "nutrisnap-secret-key-change-in-production"),
 			AccessExpiry:  getEnvDuration("JWT_ACCESS_EXPIRY", 30*time.Minute),
@@ -89,6 +363,26 @@ func Load() (*Config, error) {
 		},
 	}
 
+	// The pool is sized to the worker count unless set explicitly, so every
+	// worker can hold a warm engine without waiting on another one.
+	if cfg.OCR.PoolSize <= 0 {
+		cfg.OCR.PoolSize = cfg.OCR.Workers
+	}
+
+	// Existing deployments keep using Cloudinary; without it, images go to
+	// the local disk so development needs no cloud account
+	if cfg.Storage.Backend == "" {
+		cfg.Storage.Backend = "local"
+		if cfg.Cloudinary.URL != "" || cfg.Cloudinary.CloudName != "" {
+			cfg.Storage.Backend = "cloudinary"
+		}
+	}
+	// Outside production a key derived from JWT_SECRET saves setting one up,
+	// without storage URLs and auth tokens sharing a key
+	if cfg.Storage.SigningKey == "" && !cfg.IsProduction() {
+		cfg.Storage.SigningKey = deriveKey(cfg.JWT.Secret, "storage-url")
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
@@ -114,6 +408,121 @@ func (c *Config) Validate() error {
 		return errors.New("DB_NAME is required")
 	}
 
+	if _, err := ParseProcessRole(string(c.Server.Role)); err != nil {
+		return fmt.Errorf("PROCESS_ROLE: %w", err)
+	}
+
+	if c.OCR.Workers < 1 {
+		return errors.New("OCR_WORKERS must be at least 1")
+	}
+
+	// A job whose lock expires while it is still running would be claimed twice
+	if c.Queue.VisibilityTimeout <= c.Queue.JobTimeout {
+		return errors.New("SCAN_JOB_VISIBILITY_TIMEOUT must be longer than SCAN_JOB_TIMEOUT")
+	}
+	if c.Queue.MaxAttempts < 1 {
+		return errors.New("SCAN_JOB_MAX_ATTEMPTS must be at least 1")
+	}
+
+	if c.Webhook.MaxAttempts < 1 {
+		return errors.New("WEBHOOK_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.Webhook.Timeout <= 0 {
+		return errors.New("WEBHOOK_TIMEOUT must be positive")
+	}
+	if c.Webhook.AllowPrivateTargets && c.IsProduction() {
+		return errors.New("WEBHOOK_ALLOW_PRIVATE_TARGETS must not be enabled in production")
+	}
+
+	// Zero disables a limit
+	if c.Quality.MaxWidth < 0 || c.Quality.MaxHeight < 0 || c.Quality.MaxPixels < 0 {
+		return errors.New("IMAGE_MAX_WIDTH, IMAGE_MAX_HEIGHT and IMAGE_MAX_PIXELS must not be negative")
+	}
+
+	if c.Idempotency.TTL <= 0 {
+		return errors.New("IDEMPOTENCY_KEY_TTL must be positive")
+	}
+
+	switch c.Storage.Backend {
+	case "cloudinary":
+		if c.Cloudinary.URL == "" && (c.Cloudinary.CloudName == "" || c.Cloudinary.APIKey == "" || c.Cloudinary.APISecret == "") {
+			return errors.New("CLOUDINARY_URL or CLOUDINARY_CLOUD_NAME, CLOUDINARY_API_KEY and CLOUDINARY_API_SECRET are required for the cloudinary storage backend")
+		}
+	case "s3":
+		if c.S3.Endpoint == "" || c.S3.AccessKey == "" || c.S3.SecretKey == "" {
+			return errors.New("S3_ENDPOINT, S3_ACCESS_KEY and S3_SECRET_KEY are required for the s3 storage backend")
+		}
+	case "local":
+		if c.Storage.LocalDir == "" {
+			return errors.New("STORAGE_LOCAL_DIR is required for the local storage backend")
+		}
+		if c.Storage.SigningKey == "" {
+			return errors.New("STORAGE_SIGNING_KEY is required for the local storage backend in production")
+		}
+		if c.Storage.SigningKey == c.JWT.Secret {
+			return errors.New("STORAGE_SIGNING_KEY must differ from JWT_SECRET")
+		}
+	default:
+		return fmt.Errorf("invalid STORAGE_BACKEND %q, must be cloudinary, s3 or local", c.Storage.Backend)
+	}
+	if c.Storage.SignedURLTTL <= 0 {
+		return errors.New("STORAGE_SIGNED_URL_TTL must be positive")
+	}
+	if c.Storage.UploadURLTTL <= 0 {
+		return errors.New("STORAGE_UPLOAD_URL_TTL must be positive")
+	}
+	for role, quota := range c.Storage.Quotas {
+		if quota.Bytes < 0 || quota.Objects < 0 {
+			return fmt.Errorf("storage quotas for the %s role must not be negative", role)
+		}
+	}
+	if c.Storage.ReconcileEnabled {
+		if c.Storage.ReconcileInterval <= 0 {
+			return errors.New("STORAGE_RECONCILE_INTERVAL must be positive")
+		}
+		// Without a grace period an upload could be deleted before its scan is saved
+		if c.Storage.OrphanGracePeriod < time.Minute {
+			return errors.New("STORAGE_ORPHAN_GRACE_PERIOD must be at least 1m")
+		}
+	}
+
+	if c.Cleanup.Enabled && c.Cleanup.Interval <= 0 {
+		return errors.New("IMAGE_CLEANUP_INTERVAL must be positive")
+	}
+
+	if u, err := url.Parse(c.OFF.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("OFF_BASE_URL must be an http or https URL")
+	}
+	if c.OFF.Timeout <= 0 || c.OFF.AttemptTimeout <= 0 {
+		return errors.New("OFF_TIMEOUT and OFF_ATTEMPT_TIMEOUT must be positive")
+	}
+	if c.OFF.AttemptTimeout > c.OFF.Timeout {
+		return errors.New("OFF_ATTEMPT_TIMEOUT must not be longer than OFF_TIMEOUT")
+	}
+	if c.OFF.MaxRetries < 0 {
+		return errors.New("OFF_MAX_RETRIES must not be negative")
+	}
+	if c.OFF.BreakerThreshold < 1 {
+		return errors.New("OFF_BREAKER_THRESHOLD must be at least 1")
+	}
+	if c.OFF.BreakerCooldown <= 0 {
+		return errors.New("OFF_BREAKER_COOLDOWN must be positive")
+	}
+	if c.OFF.MissTTL < 0 {
+		return errors.New("OFF_MISS_TTL must not be negative")
+	}
+	if c.OFF.CacheTTL <= 0 {
+		return errors.New("OFF_CACHE_TTL must be positive")
+	}
+	if c.OFF.RefreshEnabled {
+		if c.OFF.RefreshInterval <= 0 {
+			return errors.New("OFF_REFRESH_INTERVAL must be positive")
+		}
+		if c.OFF.RefreshBatch < 1 {
+			return errors.New("OFF_REFRESH_BATCH must be at least 1")
+		}
+	}
+
 	return nil
 }
 
@@ -125,6 +534,14 @@ func (c *Config) IsProduction() bool {
 	return c.Server.Environment == "production"
 }
 
+// deriveKey derives a key for one purpose from a secret, so the secret
+// itself never signs anything else
+func deriveKey(secret, purpose string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -142,3 +559,25 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// setRequiredEnv sets the variables Load needs to succeed
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_USER", "nutrisnap")
+	t.Setenv("DB_PASSWORD", "secret")
+	t.Setenv("DB_NAME", "nutrisnap")
+}
+
+func TestLoadOFFCacheTTL(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"", false},
+		{"24h", false},
+		{"0", true},
+		{"-1h", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("OFF_CACHE_TTL", tt.value)
+
+			_, err := Load()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "OFF_CACHE_TTL") {
+					t.Errorf("Load() error = %v, want OFF_CACHE_TTL rejected", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Load() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadStorageSigningKey(t *testing.T) {
+	t.Run("derived outside production", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("JWT_SECRET", "jwt-secret")
+		t.Setenv("STORAGE_SIGNING_KEY", "")
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if cfg.Storage.SigningKey == "" || cfg.Storage.SigningKey == cfg.JWT.Secret {
+			t.Errorf("SigningKey = %q, want a key derived from JWT_SECRET", cfg.Storage.SigningKey)
+		}
+	})
+
+	t.Run("required in production", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("ENV", "production")
+		t.Setenv("STORAGE_BACKEND", "local")
+		t.Setenv("STORAGE_SIGNING_KEY", "")
+
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "STORAGE_SIGNING_KEY") {
+			t.Errorf("Load() error = %v, want STORAGE_SIGNING_KEY required", err)
+		}
+	})
+
+	t.Run("must differ from JWT_SECRET", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("JWT_SECRET", "shared")
+		t.Setenv("STORAGE_SIGNING_KEY", "shared")
+
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "STORAGE_SIGNING_KEY") {
+			t.Errorf("Load() error = %v, want a shared key rejected", err)
+		}
+	})
+}
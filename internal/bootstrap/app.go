@@ -4,8 +4,10 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/habbazettt/nutrisnap-server/internal/controllers"
 	"github.com/habbazettt/nutrisnap-server/internal/middleware"
 	"github.com/habbazettt/nutrisnap-server/internal/routes"
+	"github.com/habbazettt/nutrisnap-server/pkg/database"
 	"github.com/habbazettt/nutrisnap-server/pkg/logger"
 	"github.com/habbazettt/nutrisnap-server/pkg/response"
 )
@@ -22,6 +24,19 @@ func NewApp(container *Container) *fiber.App {
 	return app
 }
 
+// NewWorkerApp serves health and metrics for a process running only workers
+func NewWorkerApp(container *Container) *fiber.App {
+	app := fiber.New(fiber.Config{
+		AppName:      "NutriSnap Worker v1.0.0",
+		ErrorHandler: errorHandler,
+	})
+
+	app.Use(recover.New())
+	routes.SetupWorkerRoutes(app, controllers.NewWorkerHealthController(container.OCRWorker, database.Ping))
+
+	return app
+}
+
 func setupMiddleware(app *fiber.App) {
 	app.Use(recover.New())
 	app.Use(middleware.RateLimiter(middleware.DefaultRateLimitConfig()))
@@ -30,7 +45,8 @@ func setupMiddleware(app *fiber.App) {
 		AllowOrigins:     "http://localhost:5173",
 		AllowCredentials: true,
 		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Idempotency-Key",
+		ExposeHeaders:    "Idempotent-Replayed",
 	}))
 }
 
@@ -1,16 +1,22 @@
 package bootstrap
 
 import (
-	"log"
-
+	"github.com/gofiber/fiber/v2"
 	"github.com/habbazettt/nutrisnap-server/config"
 	"github.com/habbazettt/nutrisnap-server/internal/controllers"
+	"github.com/habbazettt/nutrisnap-server/internal/jobs"
+	"github.com/habbazettt/nutrisnap-server/internal/middleware"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/realtime"
 	"github.com/habbazettt/nutrisnap-server/internal/repositories"
 	"github.com/habbazettt/nutrisnap-server/internal/services"
 	"github.com/habbazettt/nutrisnap-server/internal/workers"
 	"github.com/habbazettt/nutrisnap-server/pkg/database"
+	"github.com/habbazettt/nutrisnap-server/pkg/imageguard"
+	"github.com/habbazettt/nutrisnap-server/pkg/imagequality"
 	"github.com/habbazettt/nutrisnap-server/pkg/jwt"
 	"github.com/habbazettt/nutrisnap-server/pkg/oauth"
+	"github.com/habbazettt/nutrisnap-server/pkg/ocr"
 	"github.com/habbazettt/nutrisnap-server/pkg/openfoodfacts"
 	"github.com/habbazettt/nutrisnap-server/pkg/storage"
 )
@@ -24,36 +30,67 @@ type Container struct {
 	GoogleOAuth *oauth.GoogleOAuth
 
 	// Storage
-	StorageClient *storage.CloudinaryClient
+	Storage storage.ObjectStore
 
 	// External APIs
 	OFFClient *openfoodfacts.Client
 
+	// OCR, only created when the process runs workers
+	OCRPool *ocr.Pool
+
 	// Repositories
-	UserRepo       repositories.UserRepository
-	ScanRepo       repositories.ScanRepository
-	ProductRepo    repositories.ProductRepository
-	CorrectionRepo repositories.CorrectionRepository
+	UserRepo        repositories.UserRepository
+	ScanRepo        repositories.ScanRepository
+	ScanImageRepo   repositories.ScanImageRepository
+	ScanJobRepo     repositories.ScanJobRepository
+	ProductRepo     repositories.ProductRepository
+	CorrectionRepo  repositories.CorrectionRepository
+	WebhookRepo     repositories.WebhookRepository
+	IdempotencyRepo repositories.IdempotencyRepository
+	ReconcileRepo   repositories.StorageReconciliationRepository
+	UsageRepo       repositories.StorageUsageRepository
+	APIClientRepo   repositories.APIClientRepository
+	ReparseRepo     repositories.ScanReparseRepository
 
 	// Services
-	AuthService    services.AuthService
-	UserService    services.UserService
-	AdminService   services.AdminService
-	ScanService    services.ScanService
-	ProductService services.ProductService
-	OCRService     services.OCRService
+	AuthService      services.AuthService
+	UserService      services.UserService
+	AdminService     services.AdminService
+	ScanService      services.ScanService
+	ProductService   services.ProductService
+	OCRService       services.OCRService
+	WebhookService   services.WebhookService
+	QuotaService     services.StorageQuotaService
+	APIClientService services.APIClientService
 
 	// Workers
-	OCRWorker *workers.OCRWorker
+	OCRWorker           *workers.OCRWorker
+	WebhookWorker       *workers.WebhookWorker
+	CleanupJob          *jobs.CleanupJob
+	IdempotencyPurgeJob *jobs.IdempotencyPurgeJob
+	ReconcileJob        *jobs.ReconcileJob
+	ProductRefreshJob   *jobs.ProductRefreshJob
+	ProductMissPurgeJob *jobs.ProductMissPurgeJob
+	ReparseJob          *jobs.ReparseJob
+
+	// Idempotency replays responses to retried mutating requests
+	Idempotency fiber.Handler
+
+	// Realtime scan events, listened for by API processes
+	ScanEventHub *realtime.Hub
 
 	// Controllers
 	AuthController       *controllers.AuthController
 	UserController       *controllers.UserController
 	AdminController      *controllers.AdminController
 	ScanController       *controllers.ScanController
+	ScanEventsController *controllers.ScanEventsController
 	ProductController    *controllers.ProductController
 	CorrectionController *controllers.CorrectionController
 	CompareController    *controllers.CompareController
+	WebhookController    *controllers.WebhookController
+	APIClientController  *controllers.APIClientController
+	StorageController    *controllers.StorageController
 }
 
 // NewContainer initializes all dependencies
@@ -76,50 +113,160 @@ func NewContainer() *Container {
 		RedirectURL:  cfg.Google.RedirectURL,
 	})
 
-	// Initialize Cloudinary storage client
-	storageClient, err := storage.NewCloudinaryClient(storage.CloudinaryConfig{
-		CloudName: cfg.Cloudinary.CloudName,
-		APIKey:    cfg.Cloudinary.APIKey,
-		APISecret: cfg.Cloudinary.APISecret,
-		URL:       cfg.Cloudinary.URL,
-		Folder:    cfg.Cloudinary.Folder,
-	})
-	if err != nil {
-		log.Printf("Warning: Failed to initialize Cloudinary storage client: %v", err)
-	}
+	// Initialize the configured storage backend
+	store := InitStorage(cfg)
+	imageURLs := services.ImageURLSigner{Store: store, TTL: cfg.Storage.SignedURLTTL}
 
 	// Initialize OpenFoodFacts client
-	offClient := openfoodfacts.NewClient()
+	offClient := openfoodfacts.NewClient(openfoodfacts.Config{
+		BaseURL:          cfg.OFF.BaseURL,
+		Timeout:          cfg.OFF.Timeout,
+		AttemptTimeout:   cfg.OFF.AttemptTimeout,
+		MaxRetries:       cfg.OFF.MaxRetries,
+		RetryDelay:       cfg.OFF.RetryDelay,
+		RetryMaxDelay:    cfg.OFF.RetryMaxDelay,
+		BreakerThreshold: cfg.OFF.BreakerThreshold,
+		BreakerCooldown:  cfg.OFF.BreakerCooldown,
+	})
+
+	// Image quality gate shared by the upload and worker paths
+	qualityConfig := services.QualityConfig{
+		Enabled: cfg.Quality.Enabled,
+		Thresholds: imagequality.Thresholds{
+			MinWidth:       cfg.Quality.MinWidth,
+			MinHeight:      cfg.Quality.MinHeight,
+			MinSharpness:   cfg.Quality.MinSharpness,
+			MaxGlare:       cfg.Quality.MaxGlare,
+			MinTextDensity: cfg.Quality.MinTextDensity,
+		},
+		Limits: imageguard.Limits{
+			MinWidth:  cfg.Quality.MinWidth,
+			MinHeight: cfg.Quality.MinHeight,
+			MaxWidth:  cfg.Quality.MaxWidth,
+			MaxHeight: cfg.Quality.MaxHeight,
+			MaxPixels: cfg.Quality.MaxPixels,
+		},
+	}
 
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(db)
 	scanRepo := repositories.NewScanRepository(db)
+	scanImageRepo := repositories.NewScanImageRepository(db)
+	scanUploadRepo := repositories.NewScanUploadRepository(db)
+	scanJobRepo := repositories.NewScanJobRepository(db)
 	productRepo := repositories.NewProductRepository(db)
 	correctionRepo := repositories.NewCorrectionRepository(db)
+	webhookRepo := repositories.NewWebhookRepository(db)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db)
+	reconcileRepo := repositories.NewStorageReconciliationRepository(db)
+	usageRepo := repositories.NewStorageUsageRepository(db)
+	leaseRepo := repositories.NewJobLeaseRepository(db)
+	apiClientRepo := repositories.NewAPIClientRepository(db)
+	reparseRepo := repositories.NewScanReparseRepository(db)
 
 	// Initialize services
 	authService := services.NewAuthService(userRepo, jwtManager, googleOAuth)
 	userService := services.NewUserService(userRepo)
-	adminService := services.NewAdminService(userRepo)
-	productService := services.NewProductService(productRepo, offClient)
-	ocrService := services.NewOCRService(storageClient)
+	quotaService := services.NewStorageQuotaService(usageRepo, userRepo, scanRepo, store, quotaConfig(cfg))
+	apiClientService := services.NewAPIClientService(apiClientRepo)
+	webhookService := services.NewWebhookService(webhookRepo, services.WebhookConfig{
+		MaxAttempts:         cfg.Webhook.MaxAttempts,
+		RequireHTTPS:        cfg.IsProduction(),
+		AllowPrivateTargets: cfg.Webhook.AllowPrivateTargets,
+	})
+	productService := services.NewProductService(productRepo, offClient, webhookService, services.ProductCacheConfig{
+		TTL:     cfg.OFF.CacheTTL,
+		MissTTL: cfg.OFF.MissTTL,
+	})
+
+	// API-only processes never run OCR, so they skip warming up engines
+	var ocrPool *ocr.Pool
+	var ocrService services.OCRService
+	if cfg.Server.Role.RunsWorkers() {
+		ocrPool = ocr.NewPool(ocr.PoolConfig{
+			Size:                cfg.OCR.PoolSize,
+			MaxUses:             cfg.OCR.EngineMaxUses,
+			AcquireTimeout:      cfg.OCR.AcquireTimeout,
+			HealthCheckInterval: cfg.OCR.HealthCheckInterval,
+		})
+		ocrService = services.NewOCRService(store, ocrPool, services.OCRConfig{
+			MultiPass:       cfg.OCR.MultiPass,
+			MultiPassBudget: cfg.OCR.MultiPassBudget,
+			Quality:         qualityConfig,
+		})
+	}
 
-	// Initialize Workers
-	ocrWorker := workers.NewOCRWorker(scanRepo, productRepo, ocrService, 100) // Buffer 100 jobs
+	// Scan events go out through Postgres NOTIFY so every API instance sees them
+	scanEventPublisher := realtime.NewPublisher(db)
+	scanEventHub := realtime.NewHub(databaseConfig(cfg).DSN())
+
+	// Initialize Workers. The worker is built in every role since the API
+	// enqueues and re-parses through it, but only started where OCR runs
+	ocrWorker := workers.NewOCRWorker(scanRepo, scanImageRepo, productRepo, scanJobRepo, ocrService, scanEventPublisher, webhookService, imageURLs, workers.QueueConfig{
+		PollInterval:      cfg.Queue.PollInterval,
+		JobTimeout:        cfg.Queue.JobTimeout,
+		VisibilityTimeout: cfg.Queue.VisibilityTimeout,
+		MaxAttempts:       cfg.Queue.MaxAttempts,
+		RetryDelay:        cfg.Queue.RetryDelay,
+		RetryMaxDelay:     cfg.Queue.RetryMaxDelay,
+		UserMaxInFlight:   cfg.Queue.UserMaxInFlight,
+	})
+	webhookWorker := workers.NewWebhookWorker(webhookRepo, workers.WebhookConfig{
+		PollInterval:        cfg.Webhook.PollInterval,
+		Timeout:             cfg.Webhook.Timeout,
+		RetryDelay:          cfg.Webhook.RetryDelay,
+		RetryMaxDelay:       cfg.Webhook.RetryMaxDelay,
+		DisableAfter:        cfg.Webhook.DisableAfter,
+		AllowPrivateTargets: cfg.Webhook.AllowPrivateTargets,
+	})
+
+	// Scheduled image retention cleanup
+	cleanupConfig := jobs.DefaultCleanupConfig()
+	cleanupConfig.RetentionDays = cfg.Cleanup.RetentionDays
+	cleanupConfig.Interval = cfg.Cleanup.Interval
+	cleanupJob := jobs.NewCleanupJob(cleanupConfig, scanRepo, usageRepo, store)
+	idempotencyPurgeJob := jobs.NewIdempotencyPurgeJob(idempotencyRepo)
+	productMissPurgeJob := jobs.NewProductMissPurgeJob(productRepo)
+	reconcileJob := jobs.NewReconcileJob(jobs.ReconcileConfig{
+		Interval:    cfg.Storage.ReconcileInterval,
+		GracePeriod: cfg.Storage.OrphanGracePeriod,
+	}, scanRepo, reconcileRepo, usageRepo, store)
+	productRefreshJob := jobs.NewProductRefreshJob(jobs.ProductRefreshConfig{
+		Interval:   cfg.OFF.RefreshInterval,
+		StaleAfter: cfg.OFF.CacheTTL,
+		Batch:      cfg.OFF.RefreshBatch,
+	}, productRepo, leaseRepo, productService)
+	reparseJob := jobs.NewReparseJob(scanRepo, reparseRepo, ocrWorker)
+
+	idempotency := middleware.Idempotency(middleware.IdempotencyConfig{
+		Store:      idempotencyRepo,
+		TTL:        cfg.Idempotency.TTL,
+		StaleAfter: cfg.Idempotency.StaleAfter,
+	})
 
-	// ScanService needs ScanQueue (implemented by ocrWorker)
-	scanService := services.NewScanService(scanRepo, storageClient, productService, ocrWorker)
+	// ScanService needs ScanQueue and ScanReparser (implemented by ocrWorker)
+	scanService := services.NewScanService(scanRepo, scanImageRepo, scanUploadRepo, store, cfg.Storage.SignedURLTTL, cfg.Storage.UploadURLTTL, productService, ocrWorker, ocrWorker, qualityConfig, quotaService)
+	adminService := services.NewAdminService(userRepo, scanRepo, scanJobRepo, reconcileRepo, usageRepo, reparseRepo)
 
 	// Initialize Correction Service
-	correctionService := services.NewCorrectionService(correctionRepo, scanRepo)
+	correctionService := services.NewCorrectionService(correctionRepo, scanRepo, webhookService)
 
 	// Initialize controllers
 	authController := controllers.NewAuthController(authService)
-	userController := controllers.NewUserController(userService)
+	userController := controllers.NewUserController(userService, quotaService)
 	adminController := controllers.NewAdminController(adminService)
 	scanController := controllers.NewScanController(scanService)
+	scanEventsController := controllers.NewScanEventsController(scanService, scanEventHub)
 	productController := controllers.NewProductController(productService)
 	correctionController := controllers.NewCorrectionController(correctionService)
+	webhookController := controllers.NewWebhookController(webhookService)
+	apiClientController := controllers.NewAPIClientController(apiClientService)
+
+	// Only the local backend needs the API to serve files
+	var storageController *controllers.StorageController
+	if localStore, ok := store.(*storage.LocalStore); ok {
+		storageController = controllers.NewStorageController(localStore)
+	}
 
 	// Initialize Compare Service and Controller
 	compareService := services.NewCompareService(productRepo, scanRepo)
@@ -128,27 +275,61 @@ func NewContainer() *Container {
 	return &Container{
 		JWTManager:           jwtManager,
 		GoogleOAuth:          googleOAuth,
-		StorageClient:        storageClient,
+		Storage:              store,
 		OFFClient:            offClient,
+		OCRPool:              ocrPool,
 		UserRepo:             userRepo,
 		ScanRepo:             scanRepo,
+		ScanImageRepo:        scanImageRepo,
+		ScanJobRepo:          scanJobRepo,
 		ProductRepo:          productRepo,
 		CorrectionRepo:       correctionRepo,
+		WebhookRepo:          webhookRepo,
+		IdempotencyRepo:      idempotencyRepo,
+		ReconcileRepo:        reconcileRepo,
+		UsageRepo:            usageRepo,
+		APIClientRepo:        apiClientRepo,
+		ReparseRepo:          reparseRepo,
 		AuthService:          authService,
 		UserService:          userService,
 		AdminService:         adminService,
 		ScanService:          scanService,
 		ProductService:       productService,
 		OCRService:           ocrService,
+		WebhookService:       webhookService,
+		QuotaService:         quotaService,
+		APIClientService:     apiClientService,
 		OCRWorker:            ocrWorker,
+		WebhookWorker:        webhookWorker,
+		CleanupJob:           cleanupJob,
+		IdempotencyPurgeJob:  idempotencyPurgeJob,
+		ReconcileJob:         reconcileJob,
+		ProductRefreshJob:    productRefreshJob,
+		ProductMissPurgeJob:  productMissPurgeJob,
+		ReparseJob:           reparseJob,
+		Idempotency:          idempotency,
+		ScanEventHub:         scanEventHub,
 		AuthController:       authController,
 		UserController:       userController,
 		AdminController:      adminController,
 		ScanController:       scanController,
+		ScanEventsController: scanEventsController,
 		ProductController:    productController,
 		CorrectionController: correctionController,
 		CompareController:    compareController,
+		WebhookController:    webhookController,
+		APIClientController:  apiClientController,
+		StorageController:    storageController,
+	}
+}
+
+// quotaConfig maps the configured storage quotas to user roles
+func quotaConfig(cfg *config.Config) services.QuotaConfig {
+	quotas := make(map[models.UserRole]services.StorageQuota, len(cfg.Storage.Quotas))
+	for role, quota := range cfg.Storage.Quotas {
+		quotas[models.UserRole(role)] = services.StorageQuota{Bytes: quota.Bytes, Objects: quota.Objects}
 	}
+	return services.QuotaConfig{Quotas: quotas, Evict: cfg.Storage.QuotaEviction}
 }
 
 // Global container instance
@@ -187,6 +368,11 @@ func (c *Container) GetScanController() *controllers.ScanController {
 	return c.ScanController
 }
 
+// GetScanEventsController returns the scan events controller
+func (c *Container) GetScanEventsController() *controllers.ScanEventsController {
+	return c.ScanEventsController
+}
+
 // GetProductController returns the product controller
 func (c *Container) GetProductController() *controllers.ProductController {
 	return c.ProductController
@@ -202,6 +388,31 @@ func (c *Container) GetCompareController() *controllers.CompareController {
 	return c.CompareController
 }
 
+// GetWebhookController returns the webhook controller
+func (c *Container) GetWebhookController() *controllers.WebhookController {
+	return c.WebhookController
+}
+
+// GetAPIClientController returns the API client controller
+func (c *Container) GetAPIClientController() *controllers.APIClientController {
+	return c.APIClientController
+}
+
+// GetAPIClients returns what authenticates API keys
+func (c *Container) GetAPIClients() middleware.APIKeyAuthenticator {
+	return c.APIClientService
+}
+
+// GetStorageController returns the storage controller, nil unless files are stored locally
+func (c *Container) GetStorageController() *controllers.StorageController {
+	return c.StorageController
+}
+
+// GetIdempotency returns the idempotency middleware
+func (c *Container) GetIdempotency() fiber.Handler {
+	return c.Idempotency
+}
+
 // GetJWTManager returns the JWT manager
 func (c *Container) GetJWTManager() *jwt.Manager {
 	return c.JWTManager
@@ -3,20 +3,14 @@ package bootstrap
 import (
 	"github.com/habbazettt/nutrisnap-server/config"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
 	"github.com/habbazettt/nutrisnap-server/pkg/database"
 	"github.com/habbazettt/nutrisnap-server/pkg/logger"
 	"gorm.io/gorm"
 )
 
 func InitDatabase(cfg *config.Config) *gorm.DB {
-	db, err := database.Connect(database.Config{
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-		DBName:   cfg.Database.DBName,
-		SSLMode:  cfg.Database.SSLMode,
-	})
+	db, err := database.Connect(databaseConfig(cfg))
 	if err != nil {
 		logger.Error("failed to connect to database", "error", err)
 		panic(err)
@@ -29,15 +23,47 @@ func InitDatabase(cfg *config.Config) *gorm.DB {
 	return db
 }
 
+func databaseConfig(cfg *config.Config) database.Config {
+	return database.Config{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.DBName,
+		SSLMode:  cfg.Database.SSLMode,
+	}
+}
+
 func runMigrations(db *gorm.DB) {
 	if err := database.AutoMigrate(db,
 		&models.User{},
 		&models.OAuthAccount{},
 		&models.Product{},
+		&models.ProductChange{},
+		&models.ProductMiss{},
 		&models.Scan{},
+		&models.ScanImage{},
+		&models.ScanUpload{},
+		&models.ScanJob{},
+		&models.ScanReparse{},
 		&models.Correction{},
+		&models.APIClient{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.IdempotencyKey{},
+		&models.StorageReconciliation{},
+		&models.StorageUsage{},
+		&models.JobLease{},
 	); err != nil {
 		logger.Error("failed to run migrations", "error", err)
 		panic(err)
 	}
+
+	// Expression and trigram indexes are beyond what AutoMigrate creates
+	for _, statement := range repositories.ProductSearchIndexes {
+		if err := db.Exec(statement).Error; err != nil {
+			logger.Error("failed to create product search indexes", "error", err)
+			panic(err)
+		}
+	}
 }
@@ -0,0 +1,69 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/config"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+	"github.com/habbazettt/nutrisnap-server/pkg/storage"
+)
+
+// InitStorage connects the configured storage backend. A process that
+// cannot store images is of no use, so it fails fast like the database.
+func InitStorage(cfg *config.Config) storage.ObjectStore {
+	store, err := newObjectStore(cfg)
+	if err != nil {
+		logger.Error("failed to initialize storage", "backend", cfg.Storage.Backend, "error", err)
+		panic(err)
+	}
+
+	logger.Info("storage initialized", "backend", cfg.Storage.Backend)
+	return store
+}
+
+func newObjectStore(cfg *config.Config) (storage.ObjectStore, error) {
+	switch cfg.Storage.Backend {
+	case storage.BackendCloudinary:
+		return storage.NewCloudinaryClient(storage.CloudinaryConfig{
+			CloudName: cfg.Cloudinary.CloudName,
+			APIKey:    cfg.Cloudinary.APIKey,
+			APISecret: cfg.Cloudinary.APISecret,
+			URL:       cfg.Cloudinary.URL,
+			Folder:    cfg.Cloudinary.Folder,
+		})
+
+	case storage.BackendS3:
+		store, err := storage.NewS3Store(storage.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			PublicURL: cfg.S3.PublicURL,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			Bucket:    cfg.S3.Bucket,
+			UseSSL:    cfg.S3.UseSSL,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := store.EnsureBucket(ctx); err != nil {
+			return nil, err
+		}
+		return store, nil
+
+	case storage.BackendLocal:
+		return storage.NewLocalStore(storage.LocalConfig{
+			Dir: cfg.Storage.LocalDir,
+			// Served by SetupStorageRoutes
+			BaseURL:    strings.TrimSuffix(cfg.Server.BaseURL, "/") + "/storage",
+			SigningKey: cfg.Storage.SigningKey,
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+}
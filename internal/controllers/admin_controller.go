@@ -1,12 +1,15 @@
 package controllers
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/middleware"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
 	"github.com/habbazettt/nutrisnap-server/internal/services"
 	"github.com/habbazettt/nutrisnap-server/pkg/response"
 )
@@ -175,6 +178,316 @@ func (c *AdminController) DeleteUser(ctx *fiber.Ctx) error {
 	})
 }
 
+// GetDeadLetterScans godoc
+// @Summary		List dead-lettered scans
+// @Description	Get paginated list of scans that failed on every retry (admin only)
+// @Tags		Admin
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		page	query	int	false	"Page number"	default(1)
+// @Param		limit	query	int	false	"Items per page"	default(10)
+// @Success		200		{object}	dto.PaginatedDeadLetterScansResponse
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Failure		403		{object}	response.ErrorEnvelope
+// @Router		/admin/scans/dead-letter [get]
+func (c *AdminController) GetDeadLetterScans(ctx *fiber.Ctx) error {
+	page, _ := strconv.Atoi(ctx.Query("page", "1"))
+	limit, _ := strconv.Atoi(ctx.Query("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	jobs, total, err := c.adminService.GetDeadLetterScans(page, limit)
+	if err != nil {
+		return response.InternalError(ctx, "Failed to get dead-lettered scans")
+	}
+
+	scanResponses := make([]dto.DeadLetterScanResponse, len(jobs))
+	for i, job := range jobs {
+		scanResponses[i] = c.toDeadLetterScanResponse(&job)
+	}
+
+	totalPages := int(total) / limit
+	if int(total)%limit > 0 {
+		totalPages++
+	}
+
+	return response.Success(ctx, dto.PaginatedDeadLetterScansResponse{
+		Scans:      scanResponses,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+	})
+}
+
+// RequeueScan godoc
+// @Summary		Requeue a dead-lettered scan
+// @Description	Give a dead-lettered scan a fresh set of attempts (admin only)
+// @Tags		Admin
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		id	path	string	true	"Scan ID"
+// @Success		200	{object}	dto.MessageResponse
+// @Failure		400	{object}	response.ErrorEnvelope
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		403	{object}	response.ErrorEnvelope
+// @Failure		404	{object}	response.ErrorEnvelope
+// @Failure		409	{object}	response.ErrorEnvelope
+// @Router		/admin/scans/{id}/requeue [post]
+func (c *AdminController) RequeueScan(ctx *fiber.Ctx) error {
+	scanID := ctx.Params("id")
+	if _, err := dto.ParseUUID(scanID); err != nil {
+		return response.BadRequest(ctx, "Invalid scan ID")
+	}
+
+	if err := c.adminService.RequeueScan(scanID); err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrScanJobNotFound):
+			return response.NotFound(ctx, "No dead-lettered job for this scan")
+		case errors.Is(err, repositories.ErrScanJobActive):
+			return response.Error(ctx, fiber.StatusConflict, "Scan is already queued")
+		}
+		return response.InternalError(ctx, "Failed to requeue scan")
+	}
+
+	return response.Success(ctx, dto.MessageResponse{
+		Message: "Scan requeued successfully",
+	})
+}
+
+// GetStorageReconciliations godoc
+// @Summary		List storage reconciliation reports
+// @Description	Get reports of the job comparing stored scan images with scans, newest first (admin only)
+// @Tags		Admin
+// @Produce		json
+// @Security	BearerAuth
+// @Param		page	query	int	false	"Page number"	default(1)
+// @Param		limit	query	int	false	"Items per page"	default(10)
+// @Success		200		{object}	dto.PaginatedStorageReconciliationsResponse
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Failure		403		{object}	response.ErrorEnvelope
+// @Router		/admin/storage/reconciliations [get]
+func (c *AdminController) GetStorageReconciliations(ctx *fiber.Ctx) error {
+	page, _ := strconv.Atoi(ctx.Query("page", "1"))
+	limit, _ := strconv.Atoi(ctx.Query("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	reports, total, err := c.adminService.GetStorageReconciliations(page, limit)
+	if err != nil {
+		return response.InternalError(ctx, "Failed to get storage reconciliation reports")
+	}
+
+	reportResponses := make([]dto.StorageReconciliationResponse, len(reports))
+	for i := range reports {
+		reportResponses[i] = dto.ToStorageReconciliationResponse(&reports[i])
+	}
+
+	totalPages := int(total) / limit
+	if int(total)%limit > 0 {
+		totalPages++
+	}
+
+	return response.Success(ctx, dto.PaginatedStorageReconciliationsResponse{
+		Reports:    reportResponses,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+	})
+}
+
+// GetStorageUsers godoc
+// @Summary		List users by storage usage
+// @Description	Get users with stored scan images, heaviest first (admin only)
+// @Tags		Admin
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		page	query	int	false	"Page number"	default(1)
+// @Param		limit	query	int	false	"Items per page"	default(10)
+// @Success		200		{object}	dto.PaginatedStorageUsersResponse
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Failure		403		{object}	response.ErrorEnvelope
+// @Router		/admin/storage/users [get]
+func (c *AdminController) GetStorageUsers(ctx *fiber.Ctx) error {
+	page, _ := strconv.Atoi(ctx.Query("page", "1"))
+	limit, _ := strconv.Atoi(ctx.Query("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	usages, total, err := c.adminService.GetStorageUsers(page, limit)
+	if err != nil {
+		return response.InternalError(ctx, "Failed to get storage usage")
+	}
+
+	userResponses := make([]dto.StorageUserResponse, len(usages))
+	for i := range usages {
+		userResponses[i] = dto.ToStorageUserResponse(&usages[i])
+	}
+
+	totalPages := int(total) / limit
+	if int(total)%limit > 0 {
+		totalPages++
+	}
+
+	return response.Success(ctx, dto.PaginatedStorageUsersResponse{
+		Users:      userResponses,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+	})
+}
+
+// ReparseScans godoc
+// @Summary		Re-parse historical scans
+// @Description	Queue a run of the current parser and scoring over stored OCR text of historical scans (admin only). A worker reads the matching scans oldest first in the background; poll the run for progress and its report of which nutrient values and grades change. Runs as a dry run unless dry_run is false.
+// @Tags		Admin
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		body	body	dto.ReparseScansRequest	true	"Scan filters"
+// @Success		201		{object}	dto.ScanReparseResponse
+// @Failure		400		{object}	response.ErrorEnvelope
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Failure		403		{object}	response.ErrorEnvelope
+// @Router		/admin/scans/reparse [post]
+func (c *AdminController) ReparseScans(ctx *fiber.Ctx) error {
+	var req dto.ReparseScansRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid JSON format")
+	}
+
+	if err := c.validate.Struct(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid filters. Statuses must be 'completed' or 'failed' and limit at least 1")
+	}
+
+	run, err := c.adminService.ReparseScans(req, middleware.GetUserID(ctx))
+	if err != nil {
+		return response.InternalError(ctx, "Failed to queue re-parse")
+	}
+
+	return response.Created(ctx, dto.ToScanReparseResponse(run))
+}
+
+// GetScanReparses godoc
+// @Summary		List re-parse runs
+// @Description	Get bulk re-parse runs with their progress, newest first, without their reports (admin only)
+// @Tags		Admin
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		page	query	int	false	"Page number"	default(1)
+// @Param		limit	query	int	false	"Items per page"	default(10)
+// @Success		200		{object}	dto.PaginatedScanReparsesResponse
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Failure		403		{object}	response.ErrorEnvelope
+// @Router		/admin/scans/reparse [get]
+func (c *AdminController) GetScanReparses(ctx *fiber.Ctx) error {
+	page, _ := strconv.Atoi(ctx.Query("page", "1"))
+	limit, _ := strconv.Atoi(ctx.Query("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	runs, total, err := c.adminService.GetScanReparses(page, limit)
+	if err != nil {
+		return response.InternalError(ctx, "Failed to get re-parse runs")
+	}
+
+	runResponses := make([]dto.ScanReparseResponse, len(runs))
+	for i := range runs {
+		runResponses[i] = dto.ToScanReparseResponse(&runs[i])
+	}
+
+	totalPages := int(total) / limit
+	if int(total)%limit > 0 {
+		totalPages++
+	}
+
+	return response.Success(ctx, dto.PaginatedScanReparsesResponse{
+		Runs:       runResponses,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+	})
+}
+
+// GetScanReparse godoc
+// @Summary		Get a re-parse run
+// @Description	Get a bulk re-parse run with its progress and report of changed and failed scans (admin only)
+// @Tags		Admin
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		id	path	string	true	"Run ID"
+// @Success		200		{object}	dto.ScanReparseResponse
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Failure		403		{object}	response.ErrorEnvelope
+// @Failure		404		{object}	response.ErrorEnvelope
+// @Router		/admin/scans/reparse/{id} [get]
+func (c *AdminController) GetScanReparse(ctx *fiber.Ctx) error {
+	run, err := c.adminService.GetScanReparse(ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, repositories.ErrScanReparseNotFound) {
+			return response.NotFound(ctx, "Re-parse run not found")
+		}
+		return response.InternalError(ctx, "Failed to get re-parse run")
+	}
+
+	return response.Success(ctx, dto.ToScanReparseResponse(run))
+}
+
+func (c *AdminController) toDeadLetterScanResponse(job *models.ScanJob) dto.DeadLetterScanResponse {
+	resp := dto.DeadLetterScanResponse{
+		JobID:     job.ID.String(),
+		ScanID:    job.ScanID.String(),
+		Attempts:  job.Attempts,
+		LastError: job.LastError,
+		CreatedAt: job.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	if job.CompletedAt != nil {
+		t := job.CompletedAt.Format("2006-01-02T15:04:05Z07:00")
+		resp.FailedAt = &t
+	}
+
+	if job.Scan != nil {
+		if job.Scan.UserID != nil {
+			userID := job.Scan.UserID.String()
+			resp.UserID = &userID
+		}
+		resp.Status = job.Scan.Status
+		resp.ErrorCode = job.Scan.ErrorCode
+		resp.ErrorMessage = job.Scan.ErrorMessage
+	}
+
+	return resp
+}
+
 func (c *AdminController) toAdminUserResponse(user *models.User) dto.AdminUserResponse {
 	var emailVerifiedAt *string
 	if user.EmailVerifiedAt != nil {
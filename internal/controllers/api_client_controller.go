@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/middleware"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/internal/services"
+	"github.com/habbazettt/nutrisnap-server/pkg/response"
+)
+
+type APIClientController struct {
+	clientService services.APIClientService
+	validate      *validator.Validate
+}
+
+func NewAPIClientController(clientService services.APIClientService) *APIClientController {
+	return &APIClientController{
+		clientService: clientService,
+		validate:      validator.New(),
+	}
+}
+
+// CreateClient godoc
+// @Summary		Create API client
+// @Description	Register a partner backend. Its API key goes in the X-API-Key header of webhook requests and is only returned here.
+// @Tags		API Client
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		body	body		dto.CreateAPIClientRequest	true	"API client"
+// @Success		201		{object}	dto.APIClientCreatedResponse
+// @Failure		400		{object}	response.ErrorEnvelope
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Router		/api-clients [post]
+func (c *APIClientController) CreateClient(ctx *fiber.Ctx) error {
+	userID := middleware.GetUserID(ctx)
+	if userID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	var req dto.CreateAPIClientRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid JSON format")
+	}
+	if err := c.validate.Struct(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid API client. Provide a name of at most 100 characters")
+	}
+
+	client, err := c.clientService.CreateClient(userID, req)
+	if err != nil {
+		return response.InternalError(ctx, "Failed to create API client")
+	}
+
+	return response.Created(ctx, client)
+}
+
+// GetClients godoc
+// @Summary		List API clients
+// @Description	Get the current user's API clients, without their keys
+// @Tags		API Client
+// @Produce		json
+// @Security	BearerAuth
+// @Success		200	{array}		dto.APIClientResponse
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Router		/api-clients [get]
+func (c *APIClientController) GetClients(ctx *fiber.Ctx) error {
+	userID := middleware.GetUserID(ctx)
+	if userID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	clients, err := c.clientService.GetClients(userID)
+	if err != nil {
+		return response.InternalError(ctx, "Failed to get API clients")
+	}
+
+	return response.Success(ctx, clients)
+}
+
+// DeleteClient godoc
+// @Summary		Delete API client
+// @Description	Revoke an API client's key and delete the webhooks it created
+// @Tags		API Client
+// @Produce		json
+// @Security	BearerAuth
+// @Param		id	path		string	true	"API client ID"
+// @Success		200	{object}	dto.MessageResponse
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		403	{object}	response.ErrorEnvelope
+// @Failure		404	{object}	response.ErrorEnvelope
+// @Router		/api-clients/{id} [delete]
+func (c *APIClientController) DeleteClient(ctx *fiber.Ctx) error {
+	userID := middleware.GetUserID(ctx)
+	if userID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	err := c.clientService.DeleteClient(ctx.Params("id"), userID)
+	switch {
+	case err == nil:
+		return response.Success(ctx, dto.MessageResponse{
+			Message: "API client deleted successfully",
+		})
+	case errors.Is(err, repositories.ErrAPIClientNotFound):
+		return response.NotFound(ctx, "API client not found")
+	case errors.Is(err, services.ErrAPIClientNotOwned):
+		return response.Forbidden(ctx, "You don't have permission to access this API client")
+	default:
+		return response.InternalError(ctx, "Failed to delete API client")
+	}
+}
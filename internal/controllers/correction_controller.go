@@ -45,6 +45,7 @@ type CorrectionResult struct {
 // @Security	BearerAuth
 // @Param		id		path		string					true	"Scan ID"
 // @Param		body	body		CreateCorrectionRequest	true	"Correction data"
+// @Param		Idempotency-Key	header	string	false	"Unique key to make retries of this request safe"
 // @Success		201		{object}	CorrectionResult
 // @Failure		400		{object}	response.ErrorEnvelope
 // @Failure		401		{object}	response.ErrorEnvelope
@@ -2,21 +2,29 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"github.com/habbazettt/nutrisnap-server/internal/repositories"
 	"github.com/habbazettt/nutrisnap-server/internal/services"
+	"github.com/habbazettt/nutrisnap-server/pkg/openfoodfacts"
 	"github.com/habbazettt/nutrisnap-server/pkg/response"
 )
 
 type ProductController struct {
 	productService services.ProductService
+	validate       *validator.Validate
 }
 
 func NewProductController(productService services.ProductService) *ProductController {
 	return &ProductController{
 		productService: productService,
+		validate:       validator.New(),
 	}
 }
 
@@ -30,6 +38,7 @@ func NewProductController(productService services.ProductService) *ProductContro
 // @Success		200		{object}	dto.ProductResponse
 // @Failure		404		{object}	response.ErrorEnvelope
 // @Failure		500		{object}	response.ErrorEnvelope
+// @Failure		503		{object}	response.ErrorEnvelope
 // @Router		/product/{barcode} [get]
 func (c *ProductController) GetProduct(ctx *fiber.Ctx) error {
 	barcode := ctx.Params("barcode")
@@ -39,8 +48,101 @@ func (c *ProductController) GetProduct(ctx *fiber.Ctx) error {
 		if errors.Is(err, repositories.ErrProductNotFound) {
 			return response.NotFound(ctx, "Product not found")
 		}
+		if errors.Is(err, openfoodfacts.ErrUnavailable) {
+			return response.Error(ctx, fiber.StatusServiceUnavailable, "Product lookup is temporarily unavailable, try again later")
+		}
 		return response.InternalError(ctx, "Failed to get product")
 	}
 
 	return response.Success(ctx, dto.ToProductResponse(product))
 }
+
+// SearchProducts godoc
+// @Summary		Search products
+// @Description	Search products by name and brand, matching whole words and similar spellings, and filter by Nutri-Score grade, source and nutrients per 100g. Nutrient ranges are given as <nutrient>_min and <nutrient>_max, such as sugar_g_max=5. Pages continue from next_cursor.
+// @Tags		Product
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		q			query	string	false	"Name or brand"
+// @Param		nutri_score	query	string	false	"Nutri-Score grades, comma separated"	example(A,B)
+// @Param		source		query	string	false	"openfoodfacts or manual, comma separated. Products read from scans are private and never searched"	example(openfoodfacts)
+// @Param		sort		query	string	false	"relevance (default with q), name (default otherwise), nutri_score or newest"
+// @Param		cursor		query	string	false	"next_cursor of the previous page"
+// @Param		limit		query	int		false	"Items per page"	default(20)
+// @Success		200		{object}	dto.ProductSearchResponse
+// @Failure		400		{object}	response.ErrorEnvelope
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Router		/products/search [get]
+func (c *ProductController) SearchProducts(ctx *fiber.Ctx) error {
+	var req dto.ProductSearchRequest
+	if err := ctx.QueryParser(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid query parameters")
+	}
+	req.Query = strings.TrimSpace(req.Query)
+	req.NutriScore = splitList(req.NutriScore)
+	req.Source = splitList(req.Source)
+
+	if err := c.validate.Struct(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid search. Nutri-Score grades must be A to E, sources openfoodfacts or manual, sort relevance, name, nutri_score or newest and limit between 1 and 100")
+	}
+
+	nutrients, err := nutrientRanges(ctx)
+	if err != nil {
+		return response.BadRequest(ctx, err.Error())
+	}
+	req.Nutrients = nutrients
+
+	result, err := c.productService.SearchProducts(ctx.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			return response.BadRequest(ctx, "Invalid cursor, start again without one")
+		}
+		return response.InternalError(ctx, "Failed to search products")
+	}
+
+	return response.Success(ctx, result)
+}
+
+// splitList accepts list parameters both repeated and comma separated
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// nutrientRanges reads the <nutrient>_min and <nutrient>_max parameters
+func nutrientRanges(ctx *fiber.Ctx) (map[string]dto.NutrientRange, error) {
+	ranges := make(map[string]dto.NutrientRange)
+	for _, key := range models.NutrientKeys() {
+		var r dto.NutrientRange
+		for _, bound := range []struct {
+			suffix string
+			value  **float64
+		}{{"_min", &r.Min}, {"_max", &r.Max}} {
+			raw := ctx.Query(key + bound.suffix)
+			if raw == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s%s must be a number", key, bound.suffix)
+			}
+			*bound.value = &value
+		}
+		if r.Min == nil && r.Max == nil {
+			continue
+		}
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return nil, fmt.Errorf("%s_min must not be above %s_max", key, key)
+		}
+		ranges[key] = r
+	}
+	return ranges, nil
+}
@@ -1,40 +1,56 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"mime/multipart"
 	"strconv"
 	"strings"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/habbazettt/nutrisnap-server/internal/dto"
 	"github.com/habbazettt/nutrisnap-server/internal/middleware"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"github.com/habbazettt/nutrisnap-server/internal/repositories"
 	"github.com/habbazettt/nutrisnap-server/internal/services"
+	"github.com/habbazettt/nutrisnap-server/pkg/constants"
+	"github.com/habbazettt/nutrisnap-server/pkg/imageguard"
+	"github.com/habbazettt/nutrisnap-server/pkg/imagequality"
 	"github.com/habbazettt/nutrisnap-server/pkg/response"
 )
 
 type ScanController struct {
 	scanService services.ScanService
+	validate    *validator.Validate
 }
 
 func NewScanController(scanService services.ScanService) *ScanController {
 	return &ScanController{
 		scanService: scanService,
+		validate:    validator.New(),
 	}
 }
 
 // Upload godoc
-// @Summary		Upload nutrition image for scanning
-// @Description	Upload an image of nutrition facts to create a new scan
+// @Summary		Upload nutrition images for scanning
+// @Description	Upload one or more typed images (front of pack, nutrition panel, ingredient list) to create a new scan
 // @Tags		Scan
 // @Accept		multipart/form-data
 // @Produce		json
 // @Security	BearerAuth
-// @Param		image		formData	file	true	"Nutrition facts image"
+// @Param		image		formData	file	false	"Nutrition facts image (same as nutrition)"
+// @Param		nutrition	formData	file	false	"Nutrition panel image"
+// @Param		front		formData	file	false	"Front of pack image, used for product name and brand"
+// @Param		ingredients	formData	file	false	"Ingredient list image"
 // @Param		store_image	formData	bool	false	"Whether to store the image (default: false)"
 // @Param		barcode		formData	string	false	"Barcode if available"
+// @Param		Idempotency-Key	header	string	false	"Unique key to make retries of this request safe"
 // @Success		201			{object}	dto.ScanUploadResponse
 // @Failure		400			{object}	response.ErrorEnvelope
 // @Failure		401			{object}	response.ErrorEnvelope
+// @Failure		413			{object}	response.ErrorEnvelope	"Storage quota exceeded"
+// @Failure		422			{object}	response.ErrorEnvelope	"Image rejected by the quality gate, with a retake hint"
 // @Router		/scan [post]
 func (c *ScanController) Upload(ctx *fiber.Ctx) error {
 	// Get user ID from context
@@ -43,29 +59,12 @@ func (c *ScanController) Upload(ctx *fiber.Ctx) error {
 		return response.Unauthorized(ctx, "User not authenticated")
 	}
 
-	// Get uploaded file
-	fileHeader, err := ctx.FormFile("image")
+	// Get uploaded files
+	images, closeFiles, err := readImageUploads(ctx)
 	if err != nil {
-		return response.BadRequest(ctx, "Image file is required")
+		return response.BadRequest(ctx, err.Error())
 	}
-
-	// Validate file size
-	if fileHeader.Size > dto.MaxImageSize {
-		return response.BadRequest(ctx, "Image size exceeds maximum allowed (10MB)")
-	}
-
-	// Validate content type
-	contentType := fileHeader.Header.Get("Content-Type")
-	if !dto.IsAllowedMimeType(contentType) {
-		return response.BadRequest(ctx, "Invalid file type. Allowed: JPEG, PNG, WebP")
-	}
-
-	// Open file
-	file, err := fileHeader.Open()
-	if err != nil {
-		return response.InternalError(ctx, "Failed to read uploaded file")
-	}
-	defer file.Close()
+	defer closeFiles()
 
 	// Get form values
 	storeImage := ctx.FormValue("store_image") == "true"
@@ -79,20 +78,327 @@ func (c *ScanController) Upload(ctx *fiber.Ctx) error {
 	result, err := c.scanService.CreateScan(
 		ctx.Context(),
 		userID,
-		file,
-		fileHeader.Filename,
-		fileHeader.Size,
-		contentType,
+		images,
 		storeImage,
 		barcodePtr,
 	)
 	if err != nil {
+		if handled, resp := imageRejection(ctx, err); handled {
+			return resp
+		}
 		return response.InternalError(ctx, "Failed to create scan: "+err.Error())
 	}
 
 	return response.Created(ctx, result)
 }
 
+// CreateUploadURL godoc
+// @Summary		Get a direct upload target
+// @Description	Reserve a scan ID and get a presigned target to upload one image straight to storage, then call commit. Available with the S3 and Cloudinary backends.
+// @Tags		Scan
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		body	body		dto.CreateUploadURLRequest	true	"Declared image"
+// @Param		Idempotency-Key	header	string	false	"Unique key to make retries of this request safe"
+// @Success		201		{object}	dto.UploadURLResponse
+// @Failure		400		{object}	response.ErrorEnvelope
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Failure		413		{object}	response.ErrorEnvelope	"Storage quota exceeded"
+// @Failure		501		{object}	response.ErrorEnvelope	"Storage backend does not support direct uploads"
+// @Router		/scan/upload-url [post]
+func (c *ScanController) CreateUploadURL(ctx *fiber.Ctx) error {
+	userID := middleware.GetUserID(ctx)
+	if userID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	var req dto.CreateUploadURLRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid JSON format")
+	}
+	if err := c.validate.Struct(&req); err != nil {
+		return response.BadRequest(ctx, fmt.Sprintf("Invalid upload. Provide a content_type of %s, a size of at most %d bytes and a hex SHA-256 checksum", dto.AllowedImageTypes, dto.MaxImageSize))
+	}
+
+	result, err := c.scanService.CreateUploadURL(ctx.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrDirectUploadUnsupported) {
+			return response.Error(ctx, fiber.StatusNotImplemented, "Direct uploads are not supported by this server, upload through POST /scan instead")
+		}
+		if handled, resp := imageRejection(ctx, err); handled {
+			return resp
+		}
+		return response.InternalError(ctx, "Failed to create upload URL")
+	}
+
+	return response.Created(ctx, result)
+}
+
+// CommitUpload godoc
+// @Summary		Commit a direct upload
+// @Description	Verify the image uploaded to a direct upload target against its declared size, type and checksum, then create the scan and queue it for processing. A rejected image is deleted so it can be uploaded again.
+// @Tags		Scan
+// @Produce		json
+// @Security	BearerAuth
+// @Param		id	path	string	true	"Scan ID from the upload URL response"
+// @Param		Idempotency-Key	header	string	false	"Unique key to make retries of this request safe"
+// @Success		201	{object}	dto.ScanUploadResponse
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		403	{object}	response.ErrorEnvelope
+// @Failure		404	{object}	response.ErrorEnvelope
+// @Failure		409	{object}	response.ErrorEnvelope	"Nothing uploaded yet, or already committed"
+// @Failure		410	{object}	response.ErrorEnvelope	"Upload target has expired"
+// @Failure		413	{object}	response.ErrorEnvelope	"Storage quota exceeded"
+// @Failure		422	{object}	response.ErrorEnvelope	"Upload does not match what was declared, or was rejected by the quality gate"
+// @Failure		501	{object}	response.ErrorEnvelope
+// @Router		/scan/{id}/commit [post]
+func (c *ScanController) CommitUpload(ctx *fiber.Ctx) error {
+	userID := middleware.GetUserID(ctx)
+	if userID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	result, err := c.scanService.CommitUpload(ctx.Context(), ctx.Params("id"), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDirectUploadUnsupported):
+			return response.Error(ctx, fiber.StatusNotImplemented, "Direct uploads are not supported by this server")
+		case errors.Is(err, repositories.ErrScanUploadNotFound):
+			return response.NotFound(ctx, "Upload not found")
+		case errors.Is(err, services.ErrScanNotOwned):
+			return response.Forbidden(ctx, "You don't have permission to modify this scan")
+		case errors.Is(err, services.ErrUploadMissing):
+			return response.Error(ctx, fiber.StatusConflict, "Nothing has been uploaded for this scan yet")
+		case errors.Is(err, repositories.ErrUploadCommitted):
+			return response.Error(ctx, fiber.StatusConflict, "Upload has already been committed")
+		case errors.Is(err, services.ErrUploadExpired):
+			return response.Error(ctx, fiber.StatusGone, "Upload has expired, request a new upload URL")
+		case errors.Is(err, services.ErrUploadMismatch):
+			return response.Error(ctx, fiber.StatusUnprocessableEntity, "Upload rejected: "+err.Error())
+		}
+		if handled, resp := imageRejection(ctx, err); handled {
+			return resp
+		}
+		return response.InternalError(ctx, "Failed to commit upload")
+	}
+
+	return response.Created(ctx, result)
+}
+
+// AttachImages godoc
+// @Summary		Attach images to an existing scan
+// @Description	Add front of pack, nutrition panel or ingredient list images to a scan and reprocess it
+// @Tags		Scan
+// @Accept		multipart/form-data
+// @Produce		json
+// @Security	BearerAuth
+// @Param		id			path		string	true	"Scan ID"
+// @Param		nutrition	formData	file	false	"Nutrition panel image"
+// @Param		front		formData	file	false	"Front of pack image"
+// @Param		ingredients	formData	file	false	"Ingredient list image"
+// @Param		Idempotency-Key	header	string	false	"Unique key to make retries of this request safe"
+// @Success		200			{object}	dto.ScanResponse
+// @Failure		400			{object}	response.ErrorEnvelope
+// @Failure		401			{object}	response.ErrorEnvelope
+// @Failure		403			{object}	response.ErrorEnvelope
+// @Failure		404			{object}	response.ErrorEnvelope
+// @Failure		409			{object}	response.ErrorEnvelope
+// @Failure		413			{object}	response.ErrorEnvelope	"Storage quota exceeded"
+// @Router		/scan/{id}/images [post]
+func (c *ScanController) AttachImages(ctx *fiber.Ctx) error {
+	userID := middleware.GetUserID(ctx)
+	if userID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	images, closeFiles, err := readImageUploads(ctx)
+	if err != nil {
+		return response.BadRequest(ctx, err.Error())
+	}
+	defer closeFiles()
+
+	result, err := c.scanService.AttachImages(ctx.Context(), ctx.Params("id"), userID, images)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrScanNotFound):
+			return response.NotFound(ctx, "Scan not found")
+		case errors.Is(err, services.ErrScanNotOwned):
+			return response.Forbidden(ctx, "You don't have permission to modify this scan")
+		case errors.Is(err, services.ErrScanProcessing):
+			return response.Error(ctx, fiber.StatusConflict, "Scan is still processing")
+		case errors.Is(err, services.ErrTooManyImages):
+			return response.BadRequest(ctx, fmt.Sprintf("A scan can hold at most %d images", dto.MaxImagesPerScan))
+		}
+		if handled, resp := imageRejection(ctx, err); handled {
+			return resp
+		}
+		return response.InternalError(ctx, "Failed to attach images: "+err.Error())
+	}
+
+	return response.Success(ctx, result)
+}
+
+// ReprocessScan godoc
+// @Summary		Reprocess a scan
+// @Description	Read a scan again with the current pipeline. Scans whose images are still stored are queued for OCR, otherwise the stored OCR text is re-parsed immediately.
+// @Tags		Scan
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Param		id	path	string	true	"Scan ID"
+// @Param		Idempotency-Key	header	string	false	"Unique key to make retries of this request safe"
+// @Success		200	{object}	dto.ScanReprocessResponse
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		403	{object}	response.ErrorEnvelope
+// @Failure		404	{object}	response.ErrorEnvelope
+// @Failure		409	{object}	response.ErrorEnvelope
+// @Failure		422	{object}	response.ErrorEnvelope
+// @Router		/scan/{id}/reprocess [post]
+func (c *ScanController) ReprocessScan(ctx *fiber.Ctx) error {
+	userID := middleware.GetUserID(ctx)
+	if userID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	result, err := c.scanService.ReprocessScan(ctx.Context(), ctx.Params("id"), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrScanNotFound):
+			return response.NotFound(ctx, "Scan not found")
+		case errors.Is(err, services.ErrScanNotOwned):
+			return response.Forbidden(ctx, "You don't have permission to modify this scan")
+		case errors.Is(err, services.ErrScanProcessing):
+			return response.Error(ctx, fiber.StatusConflict, "Scan is still processing")
+		case errors.Is(err, services.ErrNoOCRText):
+			return response.Error(ctx, fiber.StatusUnprocessableEntity, "Scan has no stored image or OCR text to reprocess")
+		}
+		return response.InternalError(ctx, "Failed to reprocess scan")
+	}
+
+	return response.Success(ctx, result)
+}
+
+// qualityStatusCodes maps quality issues to API status codes
+var qualityStatusCodes = map[imagequality.Issue]int{
+	imagequality.IssueTooSmall: constants.StatusImageTooSmall,
+	imagequality.IssueBlurry:   constants.StatusImageBlurry,
+	imagequality.IssueGlare:    constants.StatusImageGlare,
+	imagequality.IssueNoText:   constants.StatusImageNoText,
+}
+
+// guardStatusCodes maps upload check rejections to API status codes
+var guardStatusCodes = map[imageguard.Reason]int{
+	imageguard.ReasonUnsupported:   constants.StatusImageUnsupported,
+	imageguard.ReasonCorrupt:       constants.StatusImageCorrupt,
+	imageguard.ReasonPolyglot:      constants.StatusImagePolyglot,
+	imageguard.ReasonTooSmall:      constants.StatusImageTooSmall,
+	imageguard.ReasonTooLarge:      constants.StatusImageDimensionsTooLarge,
+	imageguard.ReasonTooManyPixels: constants.StatusImageTooManyPixels,
+}
+
+// imageRejection writes the response for images refused by the upload
+// checks, the quality gate or the user's storage quota
+func imageRejection(ctx *fiber.Ctx, err error) (bool, error) {
+	var guardErr *imageguard.Error
+	if errors.As(err, &guardErr) {
+		code := guardStatusCodes[guardErr.Reason]
+		return true, response.Error(ctx,
+			constants.GetHTTPStatus(code),
+			constants.GetStatusMessage(code)+": "+guardErr.Detail,
+		)
+	}
+
+	var qualityErr *imagequality.Error
+	if errors.As(err, &qualityErr) {
+		code := qualityStatusCodes[qualityErr.Issue()]
+		return true, response.ErrorWithHint(ctx,
+			constants.GetHTTPStatus(code),
+			code,
+			constants.GetStatusMessage(code),
+			imagequality.RetakeHint(qualityErr.Issue()),
+		)
+	}
+
+	if errors.Is(err, services.ErrInvalidImage) {
+		return true, response.Error(ctx,
+			constants.GetHTTPStatus(constants.StatusInvalidImage),
+			constants.GetStatusMessage(constants.StatusInvalidImage),
+		)
+	}
+
+	if errors.Is(err, services.ErrStorageQuotaExceeded) {
+		return true, response.Error(ctx, fiber.StatusRequestEntityTooLarge, "Storage quota exceeded, delete old scans to make room")
+	}
+
+	return false, nil
+}
+
+// imageFields maps multipart field names to the image kind they carry
+var imageFields = []struct {
+	field string
+	kind  models.ScanImageKind
+}{
+	{"image", models.ScanImageNutrition},
+	{"nutrition", models.ScanImageNutrition},
+	{"front", models.ScanImageFront},
+	{"ingredients", models.ScanImageIngredients},
+}
+
+// readImageUploads validates and opens every image file in the request.
+// The returned func closes the opened files.
+func readImageUploads(ctx *fiber.Ctx) ([]services.ImageUpload, func(), error) {
+	var images []services.ImageUpload
+	var files []multipart.File
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		return nil, closeFiles, errors.New("Image file is required")
+	}
+
+	for _, f := range imageFields {
+		for _, fileHeader := range form.File[f.field] {
+			// Validate file size
+			if fileHeader.Size > dto.MaxImageSize {
+				closeFiles()
+				return nil, func() {}, errors.New("Image size exceeds maximum allowed (10MB)")
+			}
+
+			// The declared content type is not trusted, the scan service
+			// sniffs the format from the file itself
+			// Open file
+			file, err := fileHeader.Open()
+			if err != nil {
+				closeFiles()
+				return nil, func() {}, errors.New("Failed to read uploaded file")
+			}
+			files = append(files, file)
+
+			images = append(images, services.ImageUpload{
+				Kind:        f.kind,
+				File:        file,
+				Size:        fileHeader.Size,
+				ContentType: fileHeader.Header.Get("Content-Type"),
+			})
+		}
+	}
+
+	if len(images) == 0 {
+		return nil, closeFiles, errors.New("Image file is required")
+	}
+	if len(images) > dto.MaxImagesPerScan {
+		closeFiles()
+		return nil, func() {}, fmt.Errorf("At most %d images can be uploaded per scan", dto.MaxImagesPerScan)
+	}
+
+	return images, closeFiles, nil
+}
+
 // GetScan godoc
 // @Summary		Get scan by ID
 // @Description	Get a specific scan by its ID
@@ -103,13 +409,17 @@ func (c *ScanController) Upload(ctx *fiber.Ctx) error {
 // @Param		id	path	string	true	"Scan ID"
 // @Success		200	{object}	dto.ScanResponse
 // @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		403	{object}	response.ErrorEnvelope
 // @Failure		404	{object}	response.ErrorEnvelope
 // @Router		/scan/{id} [get]
 func (c *ScanController) GetScan(ctx *fiber.Ctx) error {
 	scanID := ctx.Params("id")
 
-	result, err := c.scanService.GetScanByID(ctx.Context(), scanID)
+	result, err := c.scanService.GetScanByID(ctx.Context(), scanID, middleware.GetUserID(ctx))
 	if err != nil {
+		if errors.Is(err, services.ErrScanNotOwned) {
+			return response.Forbidden(ctx, "You don't have permission to view this scan")
+		}
 		if strings.Contains(err.Error(), "not found") {
 			return response.NotFound(ctx, "Scan not found")
 		}
@@ -193,33 +503,33 @@ func (c *ScanController) DeleteScan(ctx *fiber.Ctx) error {
 }
 
 // GetScanImageURL godoc
-// @Summary		Get presigned URL for scan image
-// @Description	Get a temporary presigned URL to access the scan image
+// @Summary		Get signed URL for scan image
+// @Description	Get a short-lived signed URL to the scan's image. Only the scan's owner may request one.
 // @Tags		Scan
 // @Accept		json
 // @Produce		json
 // @Security	BearerAuth
 // @Param		id	path	string	true	"Scan ID"
-// @Success		200	{object}	map[string]string
+// @Success		200	{object}	dto.ScanImageURLResponse
 // @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		403	{object}	response.ErrorEnvelope
 // @Failure		404	{object}	response.ErrorEnvelope
 // @Router		/scan/{id}/image [get]
 func (c *ScanController) GetScanImageURL(ctx *fiber.Ctx) error {
 	scanID := ctx.Params("id")
 
-	url, err := c.scanService.GetScanImageURL(ctx.Context(), scanID)
+	result, err := c.scanService.GetScanImageURL(ctx.Context(), scanID, middleware.GetUserID(ctx))
 	if err != nil {
-		if err == repositories.ErrScanNotFound {
+		switch {
+		case errors.Is(err, repositories.ErrScanNotFound):
 			return response.NotFound(ctx, "Scan not found")
-		}
-		if strings.Contains(err.Error(), "no image") {
+		case errors.Is(err, services.ErrScanNotOwned):
+			return response.Forbidden(ctx, "You don't have permission to view this scan")
+		case errors.Is(err, services.ErrNoStoredImage):
 			return response.NotFound(ctx, "No image stored for this scan")
 		}
 		return response.InternalError(ctx, "Failed to get image URL")
 	}
 
-	return response.Success(ctx, fiber.Map{
-		"image_url":  url,
-		"expires_in": "15 minutes",
-	})
+	return response.Success(ctx, result)
 }
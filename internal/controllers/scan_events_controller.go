@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/middleware"
+	"github.com/habbazettt/nutrisnap-server/internal/realtime"
+	"github.com/habbazettt/nutrisnap-server/internal/services"
+	"github.com/habbazettt/nutrisnap-server/pkg/response"
+)
+
+const (
+	// heartbeatInterval keeps idle streams from being closed by proxies
+	heartbeatInterval = 15 * time.Second
+	// wsWriteWait bounds how long a write to a WebSocket client may block
+	wsWriteWait = 10 * time.Second
+)
+
+// ScanEventSubscriber delivers scan events as they are published
+type ScanEventSubscriber interface {
+	Subscribe(filter realtime.Subscription) (<-chan dto.ScanEvent, func())
+}
+
+// ScanEventsController pushes scan status changes to clients so they do not
+// have to poll GET /scan/:id
+type ScanEventsController struct {
+	scanService services.ScanService
+	events      ScanEventSubscriber
+}
+
+func NewScanEventsController(scanService services.ScanService, events ScanEventSubscriber) *ScanEventsController {
+	return &ScanEventsController{
+		scanService: scanService,
+		events:      events,
+	}
+}
+
+// StreamScanEvents godoc
+// @Summary		Stream scan status
+// @Description	Server-Sent Events stream of a scan's status: queued, processing with the pipeline stage, completed with a summary, or failed with the reason. The current status is sent first and the stream ends once the scan completes or fails.
+// @Tags		Scan
+// @Produce		text/event-stream
+// @Security	BearerAuth
+// @Param		id	path	string	true	"Scan ID"
+// @Success		200	{object}	dto.ScanEvent
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		403	{object}	response.ErrorEnvelope
+// @Failure		404	{object}	response.ErrorEnvelope
+// @Router		/scan/{id}/events [get]
+func (c *ScanEventsController) StreamScanEvents(ctx *fiber.Ctx) error {
+	userID := middleware.GetUserID(ctx)
+	if userID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+	scanID := ctx.Params("id")
+
+	// Subscribe before reading the scan so no transition falls in between
+	events, unsubscribe := c.events.Subscribe(realtime.Subscription{ScanID: scanID, UserID: userID})
+
+	scan, err := c.scanService.GetScanByID(ctx.Context(), scanID, userID)
+	if err != nil {
+		unsubscribe()
+		if errors.Is(err, services.ErrScanNotOwned) {
+			return response.Forbidden(ctx, "You don't have permission to view this scan")
+		}
+		if strings.Contains(err.Error(), "not found") {
+			return response.NotFound(ctx, "Scan not found")
+		}
+		return response.InternalError(ctx, "Failed to get scan")
+	}
+	if scan.UserID == nil || *scan.UserID != userID {
+		unsubscribe()
+		return response.Forbidden(ctx, "You don't have permission to view this scan")
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+
+	current := dto.NewScanEvent(scan)
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		if err := writeServerSentEvent(w, current); err != nil || current.Terminal() {
+			return
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := writeServerSentEvent(w, event); err != nil || event.Terminal() {
+					return
+				}
+			case <-heartbeat.C:
+				// A failed flush is how a disconnected client is noticed
+				if _, err := w.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+func writeServerSentEvent(w *bufio.Writer, event dto.ScanEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// RequireWebSocket rejects plain HTTP requests to a WebSocket endpoint
+func (c *ScanEventsController) RequireWebSocket(ctx *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(ctx) {
+		return response.Error(ctx, fiber.StatusUpgradeRequired, "WebSocket upgrade required")
+	}
+	return ctx.Next()
+}
+
+// StreamUserEvents godoc
+// @Summary		Scan status WebSocket
+// @Description	WebSocket that pushes status events for all of the user's scans as JSON messages. Browsers may pass the access token in the access_token query parameter.
+// @Tags		Scan
+// @Security	BearerAuth
+// @Param		access_token	query	string	false	"Access token, when the Authorization header cannot be set"
+// @Success		101	{object}	dto.ScanEvent
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		426	{object}	response.ErrorEnvelope
+// @Router		/scan/ws [get]
+func (c *ScanEventsController) StreamUserEvents(conn *websocket.Conn) {
+	userID, _ := conn.Locals(middleware.UserIDKey).(string)
+	if userID == "" {
+		return
+	}
+
+	events, unsubscribe := c.events.Subscribe(realtime.Subscription{UserID: userID})
+	defer unsubscribe()
+
+	// Clients send nothing, but reading is what notices a close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(heartbeatInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
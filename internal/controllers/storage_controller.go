@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/pkg/response"
+	"github.com/habbazettt/nutrisnap-server/pkg/storage"
+)
+
+// StorageController serves files of the local storage backend through the
+// signed URLs it hands out. Cloud backends serve their own files.
+type StorageController struct {
+	store *storage.LocalStore
+}
+
+func NewStorageController(store *storage.LocalStore) *StorageController {
+	return &StorageController{store: store}
+}
+
+// ServeFile returns a stored file when its URL signature is valid and unexpired
+func (c *StorageController) ServeFile(ctx *fiber.Ctx) error {
+	key, err := url.PathUnescape(ctx.Params("*"))
+	if err != nil {
+		return response.NotFound(ctx, "File not found")
+	}
+
+	if err := c.store.VerifySignedURL(key, ctx.Query("expires"), ctx.Query("signature")); err != nil {
+		return response.Forbidden(ctx, "Invalid or expired file URL")
+	}
+
+	reader, err := c.store.Get(ctx.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return response.NotFound(ctx, "File not found")
+		}
+		return response.InternalError(ctx, "Failed to read file")
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return response.InternalError(ctx, "Failed to read file")
+	}
+
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		ctx.Set(fiber.HeaderContentType, contentType)
+	}
+	ctx.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return ctx.Send(data)
+}
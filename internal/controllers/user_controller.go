@@ -12,14 +12,16 @@ import (
 )
 
 type UserController struct {
-	userService services.UserService
-	validate    *validator.Validate
+	userService  services.UserService
+	quotaService services.StorageQuotaService
+	validate     *validator.Validate
 }
 
-func NewUserController(userService services.UserService) *UserController {
+func NewUserController(userService services.UserService, quotaService services.StorageQuotaService) *UserController {
 	return &UserController{
-		userService: userService,
-		validate:    validator.New(),
+		userService:  userService,
+		quotaService: quotaService,
+		validate:     validator.New(),
 	}
 }
 
@@ -135,3 +137,27 @@ func (c *UserController) ChangePassword(ctx *fiber.Ctx) error {
 		Message: "Password changed successfully",
 	})
 }
+
+// GetStorageUsage godoc
+// @Summary		Get storage usage
+// @Description	Get how much the current user's stored scan images take up, against their quota
+// @Tags		User
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Success		200	{object}	dto.StorageUsageResponse
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Router		/me/storage [get]
+func (c *UserController) GetStorageUsage(ctx *fiber.Ctx) error {
+	userID := middleware.GetUserID(ctx)
+	if userID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	usage, err := c.quotaService.GetUsage(userID)
+	if err != nil {
+		return response.InternalError(ctx, "Failed to get storage usage")
+	}
+
+	return response.Success(ctx, usage)
+}
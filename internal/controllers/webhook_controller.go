@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/middleware"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/internal/services"
+	"github.com/habbazettt/nutrisnap-server/pkg/response"
+)
+
+type WebhookController struct {
+	webhookService services.WebhookService
+	validate       *validator.Validate
+}
+
+func NewWebhookController(webhookService services.WebhookService) *WebhookController {
+	return &WebhookController{
+		webhookService: webhookService,
+		validate:       validator.New(),
+	}
+}
+
+// CreateWebhook godoc
+// @Summary		Create webhook
+// @Description	Register a URL to receive signed event deliveries. The signing secret is only returned here.
+// @Tags		Webhook
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Security	APIKeyAuth
+// @Param		body	body		dto.CreateWebhookRequest	true	"Webhook"
+// @Param		Idempotency-Key	header	string	false	"Unique key to make retries of this request safe"
+// @Success		201		{object}	dto.WebhookCreatedResponse
+// @Failure		400		{object}	response.ErrorEnvelope
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Router		/webhooks [post]
+func (c *WebhookController) CreateWebhook(ctx *fiber.Ctx) error {
+	owner := webhookOwner(ctx)
+	if owner.UserID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	var req dto.CreateWebhookRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid JSON format")
+	}
+	if err := c.validate.Struct(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid webhook. Provide a url and at least one supported event")
+	}
+
+	webhook, err := c.webhookService.CreateWebhook(owner, req)
+	if err != nil {
+		return c.handleError(ctx, err, "Failed to create webhook")
+	}
+
+	return response.Created(ctx, webhook)
+}
+
+// GetWebhooks godoc
+// @Summary		List webhooks
+// @Description	Get the webhooks of the current user, or of the API client making the request
+// @Tags		Webhook
+// @Produce		json
+// @Security	BearerAuth
+// @Security	APIKeyAuth
+// @Success		200	{array}		dto.WebhookResponse
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Router		/webhooks [get]
+func (c *WebhookController) GetWebhooks(ctx *fiber.Ctx) error {
+	owner := webhookOwner(ctx)
+	if owner.UserID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	webhooks, err := c.webhookService.GetWebhooks(owner)
+	if err != nil {
+		return response.InternalError(ctx, "Failed to get webhooks")
+	}
+
+	return response.Success(ctx, webhooks)
+}
+
+// GetWebhook godoc
+// @Summary		Get webhook
+// @Description	Get a webhook, including whether it was disabled after failures
+// @Tags		Webhook
+// @Produce		json
+// @Security	BearerAuth
+// @Security	APIKeyAuth
+// @Param		id	path		string	true	"Webhook ID"
+// @Success		200	{object}	dto.WebhookResponse
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		403	{object}	response.ErrorEnvelope
+// @Failure		404	{object}	response.ErrorEnvelope
+// @Router		/webhooks/{id} [get]
+func (c *WebhookController) GetWebhook(ctx *fiber.Ctx) error {
+	owner := webhookOwner(ctx)
+	if owner.UserID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	webhook, err := c.webhookService.GetWebhook(ctx.Params("id"), owner)
+	if err != nil {
+		return c.handleError(ctx, err, "Failed to get webhook")
+	}
+
+	return response.Success(ctx, webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary		Update webhook
+// @Description	Change a webhook's URL, events or description. Setting active to true re-enables a webhook disabled after repeated failures.
+// @Tags		Webhook
+// @Accept		json
+// @Produce		json
+// @Security	BearerAuth
+// @Security	APIKeyAuth
+// @Param		id		path		string						true	"Webhook ID"
+// @Param		body	body		dto.UpdateWebhookRequest	true	"Changes"
+// @Success		200		{object}	dto.WebhookResponse
+// @Failure		400		{object}	response.ErrorEnvelope
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Failure		403		{object}	response.ErrorEnvelope
+// @Failure		404		{object}	response.ErrorEnvelope
+// @Router		/webhooks/{id} [put]
+func (c *WebhookController) UpdateWebhook(ctx *fiber.Ctx) error {
+	owner := webhookOwner(ctx)
+	if owner.UserID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	var req dto.UpdateWebhookRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid JSON format")
+	}
+	if err := c.validate.Struct(&req); err != nil {
+		return response.BadRequest(ctx, "Invalid webhook. Check the url and events")
+	}
+
+	webhook, err := c.webhookService.UpdateWebhook(ctx.Params("id"), owner, req)
+	if err != nil {
+		return c.handleError(ctx, err, "Failed to update webhook")
+	}
+
+	return response.Success(ctx, webhook)
+}
+
+// DeleteWebhook godoc
+// @Summary		Delete webhook
+// @Description	Delete a webhook and its delivery log
+// @Tags		Webhook
+// @Produce		json
+// @Security	BearerAuth
+// @Security	APIKeyAuth
+// @Param		id	path		string	true	"Webhook ID"
+// @Success		200	{object}	dto.MessageResponse
+// @Failure		401	{object}	response.ErrorEnvelope
+// @Failure		403	{object}	response.ErrorEnvelope
+// @Failure		404	{object}	response.ErrorEnvelope
+// @Router		/webhooks/{id} [delete]
+func (c *WebhookController) DeleteWebhook(ctx *fiber.Ctx) error {
+	owner := webhookOwner(ctx)
+	if owner.UserID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	if err := c.webhookService.DeleteWebhook(ctx.Params("id"), owner); err != nil {
+		return c.handleError(ctx, err, "Failed to delete webhook")
+	}
+
+	return response.Success(ctx, dto.MessageResponse{
+		Message: "Webhook deleted successfully",
+	})
+}
+
+// GetDeliveries godoc
+// @Summary		List webhook deliveries
+// @Description	Get a webhook's delivery log, newest first, with the status code and response of the last attempt
+// @Tags		Webhook
+// @Produce		json
+// @Security	BearerAuth
+// @Security	APIKeyAuth
+// @Param		id		path	string	true	"Webhook ID"
+// @Param		page	query	int		false	"Page number"		default(1)
+// @Param		limit	query	int		false	"Items per page"	default(20)
+// @Success		200		{object}	dto.PaginatedWebhookDeliveriesResponse
+// @Failure		401		{object}	response.ErrorEnvelope
+// @Failure		403		{object}	response.ErrorEnvelope
+// @Failure		404		{object}	response.ErrorEnvelope
+// @Router		/webhooks/{id}/deliveries [get]
+func (c *WebhookController) GetDeliveries(ctx *fiber.Ctx) error {
+	owner := webhookOwner(ctx)
+	if owner.UserID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	page, _ := strconv.Atoi(ctx.Query("page", "1"))
+	limit, _ := strconv.Atoi(ctx.Query("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	deliveries, err := c.webhookService.GetDeliveries(ctx.Params("id"), owner, page, limit)
+	if err != nil {
+		return c.handleError(ctx, err, "Failed to get deliveries")
+	}
+
+	return response.Success(ctx, deliveries)
+}
+
+// Redeliver godoc
+// @Summary		Redeliver webhook event
+// @Description	Queue the event of a past delivery again. The new delivery keeps the event ID so receivers can deduplicate it.
+// @Tags		Webhook
+// @Produce		json
+// @Security	BearerAuth
+// @Security	APIKeyAuth
+// @Param		id			path		string	true	"Webhook ID"
+// @Param		deliveryId	path		string	true	"Delivery ID"
+// @Success		201			{object}	dto.WebhookDeliveryResponse
+// @Failure		401			{object}	response.ErrorEnvelope
+// @Failure		403			{object}	response.ErrorEnvelope
+// @Failure		404			{object}	response.ErrorEnvelope
+// @Failure		409			{object}	response.ErrorEnvelope
+// @Router		/webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func (c *WebhookController) Redeliver(ctx *fiber.Ctx) error {
+	owner := webhookOwner(ctx)
+	if owner.UserID == "" {
+		return response.Unauthorized(ctx, "User not authenticated")
+	}
+
+	delivery, err := c.webhookService.Redeliver(ctx.Params("id"), ctx.Params("deliveryId"), owner)
+	if err != nil {
+		return c.handleError(ctx, err, "Failed to redeliver event")
+	}
+
+	return response.Created(ctx, delivery)
+}
+
+// webhookOwner returns who manages webhooks in this request, the user or
+// one of their API clients
+func webhookOwner(ctx *fiber.Ctx) services.WebhookOwner {
+	return services.WebhookOwner{
+		UserID:   middleware.GetUserID(ctx),
+		ClientID: middleware.GetAPIClientID(ctx),
+	}
+}
+
+func (c *WebhookController) handleError(ctx *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, repositories.ErrWebhookNotFound):
+		return response.NotFound(ctx, "Webhook not found")
+	case errors.Is(err, repositories.ErrWebhookDeliveryNotFound):
+		return response.NotFound(ctx, "Delivery not found")
+	case errors.Is(err, services.ErrWebhookNotOwned):
+		return response.Forbidden(ctx, "You don't have permission to access this webhook")
+	case errors.Is(err, services.ErrWebhookDisabled):
+		return response.Error(ctx, fiber.StatusConflict, "Webhook is disabled. Enable it before redelivering")
+	case errors.Is(err, services.ErrInvalidWebhookURL):
+		return response.BadRequest(ctx, "Webhook url must be an absolute http(s) URL with a resolvable host, and https in production")
+	case errors.Is(err, services.ErrWebhookTargetForbidden):
+		return response.BadRequest(ctx, "Webhook url must resolve to a public address, not a loopback, private or link-local one")
+	default:
+		return response.InternalError(ctx, fallback)
+	}
+}
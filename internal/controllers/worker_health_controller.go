@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/pkg/response"
+)
+
+// readinessTimeout bounds how long the readiness checks may take together
+const readinessTimeout = 2 * time.Second
+
+// WorkerStatus reports how many OCR workers are taking jobs
+type WorkerStatus interface {
+	Running() int
+}
+
+// WorkerHealthController serves health checks for worker-only processes,
+// which have no API routes of their own
+type WorkerHealthController struct {
+	worker WorkerStatus
+	pingDB func(ctx context.Context) error
+}
+
+func NewWorkerHealthController(worker WorkerStatus, pingDB func(ctx context.Context) error) *WorkerHealthController {
+	return &WorkerHealthController{
+		worker: worker,
+		pingDB: pingDB,
+	}
+}
+
+// Liveness reports that the process is up
+func (c *WorkerHealthController) Liveness(ctx *fiber.Ctx) error {
+	return response.Success(ctx, dto.NewWorkerHealthResponse())
+}
+
+// Readiness reports whether the process can take scan jobs, which needs
+// the database reachable and workers running
+func (c *WorkerHealthController) Readiness(ctx *fiber.Ctx) error {
+	checkCtx, cancel := context.WithTimeout(ctx.Context(), readinessTimeout)
+	defer cancel()
+
+	resp := dto.WorkerReadinessResponse{
+		HealthResponse: dto.NewWorkerHealthResponse(),
+		Workers:        c.worker.Running(),
+		Checks:         map[string]string{"database": "ok", "workers": "ok"},
+	}
+
+	var failed []string
+	if err := c.pingDB(checkCtx); err != nil {
+		resp.Checks["database"] = err.Error()
+		failed = append(failed, "database: "+err.Error())
+	}
+	if resp.Workers == 0 {
+		resp.Checks["workers"] = "not running"
+		failed = append(failed, "workers: not running")
+	}
+
+	if len(failed) > 0 {
+		return response.Error(ctx, fiber.StatusServiceUnavailable, "Worker not ready: "+strings.Join(failed, "; "))
+	}
+	return response.Success(ctx, resp)
+}
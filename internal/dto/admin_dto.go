@@ -1,6 +1,11 @@
 package dto
 
-import "github.com/habbazettt/nutrisnap-server/internal/models"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+)
 
 // =============== ADMIN REQUEST DTOs ===============
 
@@ -9,6 +14,20 @@ type UpdateUserRoleRequest struct {
 	Role string `json:"role" validate:"required,oneof=user admin" example:"admin"`
 }
 
+// ReparseScansRequest selects historical scans to re-parse with the current parser
+type ReparseScansRequest struct {
+	From          *time.Time `json:"from,omitempty" example:"2026-01-01T00:00:00Z"`
+	To            *time.Time `json:"to,omitempty" example:"2026-06-30T23:59:59Z"`
+	Statuses      []string   `json:"statuses,omitempty" validate:"dive,oneof=completed failed" example:"completed"`
+	ParserVersion *string    `json:"parser_version,omitempty" example:"1"`
+	// Outdated only selects scans not yet read by the current parser version
+	Outdated bool `json:"outdated"`
+	// DryRun reports the changes without saving them. Defaults to true.
+	DryRun *bool `json:"dry_run,omitempty"`
+	// Limit caps how many scans are read, every matching scan when unset
+	Limit int `json:"limit,omitempty" validate:"omitempty,min=1" example:"5000"`
+}
+
 // =============== ADMIN RESPONSE DTOs ===============
 
 // AdminStatsResponse represents the admin dashboard stats
@@ -39,3 +58,164 @@ type PaginatedUsersResponse struct {
 	Limit      int                 `json:"limit"`
 	TotalPages int                 `json:"total_pages"`
 }
+
+// DeadLetterScanResponse represents a scan whose job ran out of attempts
+type DeadLetterScanResponse struct {
+	JobID        string            `json:"job_id"`
+	ScanID       string            `json:"scan_id"`
+	UserID       *string           `json:"user_id,omitempty"`
+	Status       models.ScanStatus `json:"status,omitempty"`
+	Attempts     int               `json:"attempts"`
+	ErrorCode    *string           `json:"error_code,omitempty"`
+	ErrorMessage *string           `json:"error_message,omitempty"`
+	LastError    *string           `json:"last_error,omitempty"`
+	FailedAt     *string           `json:"failed_at,omitempty"`
+	CreatedAt    string            `json:"created_at"`
+}
+
+// PaginatedDeadLetterScansResponse represents paginated dead-lettered scans
+type PaginatedDeadLetterScansResponse struct {
+	Scans      []DeadLetterScanResponse `json:"scans"`
+	Total      int64                    `json:"total"`
+	Page       int                      `json:"page"`
+	Limit      int                      `json:"limit"`
+	TotalPages int                      `json:"total_pages"`
+}
+
+// ScanReparseResponse is a bulk re-parse run and its progress. Report
+// lists the scans whose nutrients or grade changed and the scans that
+// failed, and is left out of listings.
+type ScanReparseResponse struct {
+	ID            string               `json:"id"`
+	Status        models.ReparseStatus `json:"status"`
+	DryRun        bool                 `json:"dry_run"`
+	ParserVersion string               `json:"parser_version"`
+	Filter        json.RawMessage      `json:"filter" swaggertype:"object"`
+	Limit         int                  `json:"limit,omitempty"`
+	Processed     int                  `json:"processed"`
+	Changed       int                  `json:"changed"`
+	Unchanged     int                  `json:"unchanged"`
+	Failed        int                  `json:"failed"`
+	Report        json.RawMessage      `json:"report,omitempty" swaggertype:"object"`
+	Error         *string              `json:"error,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	StartedAt     *time.Time           `json:"started_at,omitempty"`
+	FinishedAt    *time.Time           `json:"finished_at,omitempty"`
+}
+
+// PaginatedScanReparsesResponse represents a page of re-parse runs
+type PaginatedScanReparsesResponse struct {
+	Runs       []ScanReparseResponse `json:"runs"`
+	Total      int64                 `json:"total"`
+	Page       int                   `json:"page"`
+	Limit      int                   `json:"limit"`
+	TotalPages int                   `json:"total_pages"`
+}
+
+func ToScanReparseResponse(run *models.ScanReparse) ScanReparseResponse {
+	return ScanReparseResponse{
+		ID:            run.ID.String(),
+		Status:        run.Status,
+		DryRun:        run.DryRun,
+		ParserVersion: run.ParserVersion,
+		Filter:        json.RawMessage(run.FilterJSON),
+		Limit:         run.Limit,
+		Processed:     run.Processed,
+		Changed:       run.Changed,
+		Unchanged:     run.Unchanged,
+		Failed:        run.Failed,
+		Report:        json.RawMessage(run.ReportJSON),
+		Error:         run.Error,
+		CreatedAt:     run.CreatedAt,
+		StartedAt:     run.StartedAt,
+		FinishedAt:    run.FinishedAt,
+	}
+}
+
+// ReparseError is a scan that could not be re-parsed
+type ReparseError struct {
+	ScanID string `json:"scan_id"`
+	Error  string `json:"error"`
+}
+
+// StorageReconciliationResponse is the report of one storage reconciliation run
+type StorageReconciliationResponse struct {
+	ID               string                      `json:"id"`
+	Status           models.ReconciliationStatus `json:"status"`
+	StartedAt        time.Time                   `json:"started_at"`
+	FinishedAt       *time.Time                  `json:"finished_at,omitempty"`
+	ObjectsListed    int                         `json:"objects_listed"`
+	OrphansDeleted   int                         `json:"orphans_deleted"`
+	OrphansPending   int                         `json:"orphans_pending"`
+	ScansChecked     int                         `json:"scans_checked"`
+	ScansMissing     int                         `json:"scans_missing"`
+	ScansRestored    int                         `json:"scans_restored"`
+	VariantsRestored int                         `json:"variants_restored"`
+	Errors           int                         `json:"errors"`
+	Error            *string                     `json:"error,omitempty"`
+	Details          json.RawMessage             `json:"details,omitempty" swaggertype:"object"`
+}
+
+// PaginatedStorageReconciliationsResponse represents a page of reconciliation reports
+type PaginatedStorageReconciliationsResponse struct {
+	Reports    []StorageReconciliationResponse `json:"reports"`
+	Total      int64                           `json:"total"`
+	Page       int                             `json:"page"`
+	Limit      int                             `json:"limit"`
+	TotalPages int                             `json:"total_pages"`
+}
+
+func ToStorageReconciliationResponse(report *models.StorageReconciliation) StorageReconciliationResponse {
+	return StorageReconciliationResponse{
+		ID:               report.ID.String(),
+		Status:           report.Status,
+		StartedAt:        report.StartedAt,
+		FinishedAt:       report.FinishedAt,
+		ObjectsListed:    report.ObjectsListed,
+		OrphansDeleted:   report.OrphansDeleted,
+		OrphansPending:   report.OrphansPending,
+		ScansChecked:     report.ScansChecked,
+		ScansMissing:     report.ScansMissing,
+		ScansRestored:    report.ScansRestored,
+		VariantsRestored: report.VariantsRestored,
+		Errors:           report.Errors,
+		Error:            report.Error,
+		Details:          json.RawMessage(report.DetailsJSON),
+	}
+}
+
+// StorageUserResponse represents the storage usage of one user
+type StorageUserResponse struct {
+	UserID      string          `json:"user_id"`
+	Email       string          `json:"email,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	Role        models.UserRole `json:"role,omitempty"`
+	UsedBytes   int64           `json:"used_bytes"`
+	UsedObjects int             `json:"used_objects"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// PaginatedStorageUsersResponse represents a page of users by storage usage
+type PaginatedStorageUsersResponse struct {
+	Users      []StorageUserResponse `json:"users"`
+	Total      int64                 `json:"total"`
+	Page       int                   `json:"page"`
+	Limit      int                   `json:"limit"`
+	TotalPages int                   `json:"total_pages"`
+}
+
+func ToStorageUserResponse(usage *models.StorageUsage) StorageUserResponse {
+	resp := StorageUserResponse{
+		UserID:      usage.UserID.String(),
+		UsedBytes:   usage.Bytes,
+		UsedObjects: usage.Objects,
+		UpdatedAt:   usage.UpdatedAt,
+	}
+	// Deleted users are not loaded
+	if usage.User != nil {
+		resp.Email = usage.User.Email
+		resp.Name = usage.User.Name
+		resp.Role = usage.User.Role
+	}
+	return resp
+}
@@ -0,0 +1,43 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+)
+
+// =============== API CLIENT REQUEST DTOs ===============
+
+// CreateAPIClientRequest registers a partner backend
+type CreateAPIClientRequest struct {
+	Name string `json:"name" validate:"required,max=100" example:"Partner backend"`
+}
+
+// =============== API CLIENT RESPONSE DTOs ===============
+
+// APIClientResponse represents an API client without its key
+type APIClientResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"key_prefix" example:"nsk_3f2a9c1e"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APIClientCreatedResponse includes the API key, which is only shown once
+type APIClientCreatedResponse struct {
+	APIClientResponse
+	Key string `json:"key" example:"nsk_3f2a9c1e..."`
+}
+
+// =============== HELPER FUNCTIONS ===============
+
+func ToAPIClientResponse(client *models.APIClient) APIClientResponse {
+	return APIClientResponse{
+		ID:         client.ID.String(),
+		Name:       client.Name,
+		KeyPrefix:  client.KeyPrefix,
+		LastUsedAt: client.LastUsedAt,
+		CreatedAt:  client.CreatedAt,
+	}
+}
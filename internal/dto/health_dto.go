@@ -11,11 +11,27 @@ type HealthResponse struct {
 	Timestamp string `json:"timestamp" example:"2024-12-07T10:00:00Z"`
 }
 
+// WorkerReadinessResponse reports the checks a worker process must pass
+// before it can take scan jobs
+type WorkerReadinessResponse struct {
+	HealthResponse
+	Workers int               `json:"workers" example:"5"`
+	Checks  map[string]string `json:"checks"`
+}
+
 func NewHealthResponse() HealthResponse {
+	return newHealthResponse("nutrisnap-api")
+}
+
+func NewWorkerHealthResponse() HealthResponse {
+	return newHealthResponse("nutrisnap-worker")
+}
+
+func newHealthResponse(service string) HealthResponse {
 	loc, _ := time.LoadLocation("Asia/Jakarta")
 	return HealthResponse{
 		Status:    "healthy",
-		Service:   "nutrisnap-api",
+		Service:   service,
 		Version:   "1.0.0",
 		Timestamp: time.Now().In(loc).Format(time.RFC3339),
 	}
@@ -31,3 +31,29 @@ func ToProductResponse(p *models.Product) ProductResponse {
 		NutriScoreValue: p.NutriScoreValue,
 	}
 }
+
+// ProductSearchRequest holds the query parameters of a product search.
+// Nutrient ranges come from <nutrient>_min and <nutrient>_max parameters,
+// such as sugar_g_max=5, and are parsed separately.
+type ProductSearchRequest struct {
+	Query      string                   `query:"q" validate:"max=200"`
+	NutriScore []string                 `query:"nutri_score" validate:"dive,oneof=A B C D E a b c d e"`
+	Source     []string                 `query:"source" validate:"dive,oneof=openfoodfacts manual"`
+	Sort       string                   `query:"sort" validate:"omitempty,oneof=relevance name nutri_score newest"`
+	Cursor     string                   `query:"cursor"`
+	Limit      int                      `query:"limit" validate:"omitempty,min=1,max=100"`
+	Nutrients  map[string]NutrientRange `query:"-"`
+}
+
+// NutrientRange bounds a nutrient per 100g
+type NutrientRange struct {
+	Min *float64
+	Max *float64
+}
+
+// ProductSearchResponse is one page of search results
+type ProductSearchResponse struct {
+	Products []ProductResponse `json:"products"`
+	// NextCursor fetches the next page, and is absent on the last one
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
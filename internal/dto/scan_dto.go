@@ -6,6 +6,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/pkg/nutrition"
+	"github.com/habbazettt/nutrisnap-server/pkg/storage"
 )
 
 // =============== SCAN REQUEST DTOs ===============
@@ -16,6 +18,16 @@ type CreateScanRequest struct {
 	StoreImage bool    `form:"store_image" json:"store_image"`
 }
 
+// CreateUploadURLRequest declares an image a client will upload straight
+// to storage
+type CreateUploadURLRequest struct {
+	Kind           string  `json:"kind,omitempty" validate:"omitempty,oneof=front nutrition ingredients" example:"nutrition"`
+	ContentType    string  `json:"content_type" validate:"required,oneof=image/jpeg image/png image/webp" example:"image/jpeg"`
+	Size           int64   `json:"size" validate:"required,gt=0,lte=10485760" example:"2483112"`
+	ChecksumSHA256 string  `json:"checksum_sha256" validate:"required,len=64,hexadecimal"`
+	Barcode        *string `json:"barcode,omitempty"`
+}
+
 // =============== SCAN RESPONSE DTOs ===============
 
 // ScanResponse represents a scan result
@@ -25,6 +37,8 @@ type ScanResponse struct {
 	Barcode          *string                    `json:"barcode,omitempty"`
 	Status           models.ScanStatus          `json:"status"`
 	ImageURL         *string                    `json:"image_url,omitempty"`
+	ImageVariants    map[string]string          `json:"image_variants,omitempty"` // Downscaled renditions of ImageURL by variant name
+	ImageMissing     bool                       `json:"image_missing,omitempty"`  // A stored image was found gone from storage
 	ServingSize      *string                    `json:"serving_size,omitempty"`
 	NutriScore       *string                    `json:"nutri_score,omitempty"`
 	NutriScoreValue  *int                       `json:"nutri_score_value,omitempty"`
@@ -32,18 +46,93 @@ type ScanResponse struct {
 	Highlights       []models.NutrientHighlight `json:"highlights,omitempty"`
 	Insights         []models.Insight           `json:"insights,omitempty"`
 	ProcessingTimeMs *int                       `json:"processing_time_ms,omitempty"`
+	Trace            json.RawMessage            `json:"trace,omitempty" swaggertype:"object"`
+	ErrorCode        *string                    `json:"error_code,omitempty"`
 	ErrorMessage     *string                    `json:"error_message,omitempty"`
+	OCRStrategy      *string                    `json:"ocr_strategy,omitempty"`
+	OCRConfidence    *float64                   `json:"ocr_confidence,omitempty"`
+	ProductName      *string                    `json:"product_name,omitempty"`
+	Brand            *string                    `json:"brand,omitempty"`
+	Ingredients      *string                    `json:"ingredients,omitempty"`
+	Images           []ScanImageResponse        `json:"images,omitempty"`
+	Queue            *QueuePosition             `json:"queue,omitempty"`
 	CreatedAt        time.Time                  `json:"created_at"`
 	OCRRaw           *string                    `json:"ocr_raw,omitempty"` // Debugging field
 }
 
+// QueuePosition describes where a pending scan stands in the processing queue
+type QueuePosition struct {
+	Position             int    `json:"position" example:"3"`
+	Priority             string `json:"priority" example:"interactive"`
+	EstimatedWaitSeconds *int   `json:"estimated_wait_seconds,omitempty" example:"12"`
+}
+
+// ScanImageResponse represents one typed image attached to a scan
+type ScanImageResponse struct {
+	ID       string               `json:"id"`
+	Kind     models.ScanImageKind `json:"kind"`
+	ImageURL string               `json:"image_url"`
+	// Variants holds downscaled renditions by variant name, e.g. thumbnail
+	Variants  map[string]string `json:"variants,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// ScanImageURLResponse is a short-lived URL to a scan's primary image
+type ScanImageURLResponse struct {
+	ImageURL  string    `json:"image_url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// ExpiresIn is the URL's lifetime in seconds
+	ExpiresIn int `json:"expires_in" example:"900"`
+}
+
+// UploadURLResponse tells a client where to upload an image and which scan
+// it becomes once committed
+type UploadURLResponse struct {
+	ScanID string `json:"scan_id"`
+	// Method is PUT for a raw body or POST for a multipart form
+	Method  string            `json:"method" example:"PUT"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Fields and FileField describe the form of POST uploads
+	Fields    map[string]string `json:"fields,omitempty"`
+	FileField string            `json:"file_field,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
 // ScanUploadResponse represents the upload response
 type ScanUploadResponse struct {
-	ID        string            `json:"id"`
-	Status    models.ScanStatus `json:"status"`
-	ImageURL  *string           `json:"image_url,omitempty"`
-	Message   string            `json:"message"`
-	CreatedAt time.Time         `json:"created_at"`
+	ID        string              `json:"id"`
+	Status    models.ScanStatus   `json:"status"`
+	ImageURL  *string             `json:"image_url,omitempty"`
+	Images    []ScanImageResponse `json:"images,omitempty"`
+	Message   string              `json:"message"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// ScanReparseResult describes what re-parsing a scan changed, or would change on a dry run
+type ScanReparseResult struct {
+	ScanID    string                     `json:"scan_id"`
+	Changed   bool                       `json:"changed"`
+	Applied   bool                       `json:"applied"`
+	Nutrients []nutrition.NutrientChange `json:"nutrients,omitempty"`
+	// Fields lists the other product fields that change: name, brand,
+	// ingredients and serving_size
+	Fields             []string `json:"fields,omitempty"`
+	OldNutriScore      *string  `json:"old_nutri_score,omitempty"`
+	NewNutriScore      *string  `json:"new_nutri_score,omitempty"`
+	OldNutriScoreValue *int     `json:"old_nutri_score_value,omitempty"`
+	NewNutriScoreValue *int     `json:"new_nutri_score_value,omitempty"`
+	OldParserVersion   *string  `json:"old_parser_version,omitempty"`
+	NewParserVersion   string   `json:"new_parser_version"`
+}
+
+// ScanReprocessResponse represents the reprocess response
+type ScanReprocessResponse struct {
+	ID      string             `json:"id"`
+	Mode    string             `json:"mode" example:"ocr"` // "ocr" when queued, "reparse" when stored text was re-parsed
+	Status  models.ScanStatus  `json:"status"`
+	Reparse *ScanReparseResult `json:"reparse,omitempty"`
+	Message string             `json:"message"`
 }
 
 // PaginatedScansResponse represents paginated scans list
@@ -70,14 +159,35 @@ func ToScanResponse(scan *models.Scan, imageURL *string) ScanResponse {
 		Barcode:          scan.Barcode,
 		Status:           scan.Status,
 		ImageURL:         imageURL,
+		ImageMissing:     scan.ImageMissingAt != nil,
 		NutriScore:       scan.NutriScore,
 		NutriScoreValue:  scan.NutriScoreValue,
 		ProcessingTimeMs: scan.ProcessingTimeMs,
+		Trace:            json.RawMessage(scan.TraceJSON),
+		ErrorCode:        scan.ErrorCode,
 		ErrorMessage:     scan.ErrorMessage,
+		OCRStrategy:      scan.OCRStrategy,
+		OCRConfidence:    scan.OCRConfidence,
 		CreatedAt:        scan.CreatedAt,
 		OCRRaw:           scan.OCRRaw, // Debugging
 	}
 
+	resp.Images = ToScanImageResponses(scan.Images)
+	if imageURL != nil {
+		for _, image := range scan.Images {
+			if image.ImageRef == *imageURL && image.HasVariants {
+				resp.ImageVariants = variantRefs(image.ImageRef)
+				break
+			}
+		}
+	}
+
+	if scan.Product != nil {
+		resp.ProductName = &scan.Product.Name
+		resp.Brand = scan.Product.Brand
+		resp.Ingredients = scan.Product.Ingredients
+	}
+
 	// 1. Populate Nutrients from Product (preferred) or Scan
 	var nutrientsJSON []byte
 	if scan.Product != nil && len(scan.Product.NutrientsJSON) > 0 {
@@ -134,15 +244,47 @@ func ToScanUploadResponse(scan *models.Scan, imageURL *string) ScanUploadRespons
 		ID:        scan.ID.String(),
 		Status:    scan.Status,
 		ImageURL:  imageURL,
+		Images:    ToScanImageResponses(scan.Images),
 		Message:   "Scan created successfully",
 		CreatedAt: scan.CreatedAt,
 	}
 }
 
+func ToScanImageResponses(images []models.ScanImage) []ScanImageResponse {
+	if len(images) == 0 {
+		return nil
+	}
+
+	resp := make([]ScanImageResponse, len(images))
+	for i, image := range images {
+		resp[i] = ScanImageResponse{
+			ID:        image.ID.String(),
+			Kind:      image.Kind,
+			ImageURL:  image.ImageRef,
+			CreatedAt: image.CreatedAt,
+		}
+		if image.HasVariants {
+			resp[i].Variants = variantRefs(image.ImageRef)
+		}
+	}
+	return resp
+}
+
+// variantRefs maps every variant name to the ref of the image it is
+// rendered from, until the refs are replaced with URLs
+func variantRefs(ref string) map[string]string {
+	variants := make(map[string]string, len(storage.Variants))
+	for _, variant := range storage.Variants {
+		variants[string(variant)] = ref
+	}
+	return variants
+}
+
 // =============== VALIDATION CONSTANTS ===============
 
 const (
 	MaxImageSize      = 10 * 1024 * 1024 // 10MB
+	MaxImagesPerScan  = 6
 	MinImageWidth     = 200
 	MinImageHeight    = 200
 	AllowedImageTypes = "image/jpeg,image/png,image/webp"
@@ -171,3 +313,76 @@ func ParseUUID(s string) (*uuid.UUID, error) {
 	}
 	return &id, nil
 }
+
+// =============== SCAN EVENT DTOs ===============
+
+// ScanEventType is the status transition a scan event reports
+type ScanEventType string
+
+const (
+	ScanEventQueued     ScanEventType = "queued"
+	ScanEventProcessing ScanEventType = "processing"
+	ScanEventCompleted  ScanEventType = "completed"
+	ScanEventFailed     ScanEventType = "failed"
+)
+
+// ScanEvent is pushed to clients over SSE and WebSocket when a scan changes status
+type ScanEvent struct {
+	Type         ScanEventType     `json:"type" example:"processing"`
+	ScanID       string            `json:"scan_id"`
+	UserID       string            `json:"user_id,omitempty"`
+	Stage        string            `json:"stage,omitempty" example:"ocr"` // Pipeline stage while processing
+	Summary      *ScanEventSummary `json:"summary,omitempty"`
+	ErrorCode    *string           `json:"error_code,omitempty"`
+	ErrorMessage *string           `json:"error_message,omitempty"`
+	RetryAt      *time.Time        `json:"retry_at,omitempty"` // Set when a failed attempt is queued again
+	At           time.Time         `json:"at"`
+}
+
+// ScanEventSummary is the result of a completed scan
+type ScanEventSummary struct {
+	ProductName      *string `json:"product_name,omitempty"`
+	Brand            *string `json:"brand,omitempty"`
+	NutriScore       *string `json:"nutri_score,omitempty"`
+	NutriScoreValue  *int    `json:"nutri_score_value,omitempty"`
+	ProcessingTimeMs *int    `json:"processing_time_ms,omitempty"`
+}
+
+// Terminal reports whether no further events follow for the scan
+func (e ScanEvent) Terminal() bool {
+	return e.Type == ScanEventCompleted || e.Type == ScanEventFailed
+}
+
+// NewScanEvent describes the current state of a scan as an event, used as
+// the first message of a stream and when a scan settles
+func NewScanEvent(scan *ScanResponse) ScanEvent {
+	event := ScanEvent{
+		ScanID: scan.ID,
+		At:     time.Now(),
+	}
+	if scan.UserID != nil {
+		event.UserID = *scan.UserID
+	}
+
+	switch scan.Status {
+	case models.ScanStatusProcessing:
+		event.Type = ScanEventProcessing
+	case models.ScanStatusCompleted:
+		event.Type = ScanEventCompleted
+		event.Summary = &ScanEventSummary{
+			ProductName:      scan.ProductName,
+			Brand:            scan.Brand,
+			NutriScore:       scan.NutriScore,
+			NutriScoreValue:  scan.NutriScoreValue,
+			ProcessingTimeMs: scan.ProcessingTimeMs,
+		}
+	case models.ScanStatusFailed:
+		event.Type = ScanEventFailed
+		event.ErrorCode = scan.ErrorCode
+		event.ErrorMessage = scan.ErrorMessage
+	default:
+		event.Type = ScanEventQueued
+	}
+
+	return event
+}
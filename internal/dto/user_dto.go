@@ -31,3 +31,14 @@ type ProfileResponse struct {
 type MessageResponse struct {
 	Message string `json:"message" example:"Operation successful"`
 }
+
+// StorageUsageResponse represents what a user stores against their quota.
+// A missing quota means unlimited.
+type StorageUsageResponse struct {
+	UsedBytes    int64  `json:"used_bytes" example:"10485760"`
+	UsedObjects  int    `json:"used_objects" example:"12"`
+	QuotaBytes   *int64 `json:"quota_bytes,omitempty" example:"524288000"`
+	QuotaObjects *int   `json:"quota_objects,omitempty"`
+	// Eviction is set when old images are deleted to make room for new ones
+	Eviction bool `json:"eviction"`
+}
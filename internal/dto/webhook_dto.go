@@ -0,0 +1,132 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+)
+
+// =============== WEBHOOK REQUEST DTOs ===============
+
+// CreateWebhookRequest registers a URL for event deliveries
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,url,max=2000" example:"https://partner.example.com/nutrisnap/webhook"`
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=scan.completed scan.failed correction.created product.updated" example:"scan.completed"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=255" example:"Sync scans to our backend"`
+}
+
+// UpdateWebhookRequest changes a webhook. Setting active to true re-enables
+// a webhook that was disabled after repeated failures.
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url,omitempty" validate:"omitempty,url,max=2000"`
+	Events      []string `json:"events,omitempty" validate:"omitempty,min=1,dive,oneof=scan.completed scan.failed correction.created product.updated"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=255"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// =============== WEBHOOK RESPONSE DTOs ===============
+
+// WebhookResponse represents a webhook subscription
+type WebhookResponse struct {
+	ID                  string                    `json:"id"`
+	ClientID            *string                   `json:"client_id,omitempty"`
+	URL                 string                    `json:"url"`
+	Description         *string                   `json:"description,omitempty"`
+	Events              []models.WebhookEventType `json:"events"`
+	Active              bool                      `json:"active"`
+	ConsecutiveFailures int                       `json:"consecutive_failures"`
+	DisabledAt          *time.Time                `json:"disabled_at,omitempty"`
+	DisabledReason      *string                   `json:"disabled_reason,omitempty"`
+	LastDeliveryAt      *time.Time                `json:"last_delivery_at,omitempty"`
+	CreatedAt           time.Time                 `json:"created_at"`
+}
+
+// WebhookCreatedResponse includes the signing secret, which is only shown once
+type WebhookCreatedResponse struct {
+	WebhookResponse
+	Secret string `json:"secret" example:"whsec_3f2a..."`
+}
+
+// WebhookDeliveryResponse represents one entry of a webhook's delivery log
+type WebhookDeliveryResponse struct {
+	ID             string                       `json:"id"`
+	EventID        string                       `json:"event_id"`
+	EventType      models.WebhookEventType      `json:"event_type"`
+	Status         models.WebhookDeliveryStatus `json:"status"`
+	Attempts       int                          `json:"attempts"`
+	MaxAttempts    int                          `json:"max_attempts"`
+	NextAttemptAt  *time.Time                   `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int                         `json:"last_status_code,omitempty"`
+	LastError      *string                      `json:"last_error,omitempty"`
+	ResponseBody   *string                      `json:"response_body,omitempty"`
+	DurationMs     *int                         `json:"duration_ms,omitempty"`
+	DeliveredAt    *time.Time                   `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage              `json:"payload" swaggertype:"object"`
+	CreatedAt      time.Time                    `json:"created_at"`
+}
+
+// PaginatedWebhookDeliveriesResponse represents a page of the delivery log
+type PaginatedWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Total      int64                     `json:"total"`
+	Page       int                       `json:"page"`
+	Limit      int                       `json:"limit"`
+	TotalPages int                       `json:"total_pages"`
+}
+
+// WebhookEvent is the body POSTed to a webhook URL
+type WebhookEvent struct {
+	ID        uuid.UUID               `json:"id"`
+	Type      models.WebhookEventType `json:"type" example:"scan.completed"`
+	CreatedAt time.Time               `json:"created_at"`
+	Data      interface{}             `json:"data"`
+}
+
+// =============== HELPER FUNCTIONS ===============
+
+func ToWebhookResponse(webhook *models.WebhookSubscription) WebhookResponse {
+	resp := WebhookResponse{
+		ID:                  webhook.ID.String(),
+		URL:                 webhook.URL,
+		Description:         webhook.Description,
+		Events:              webhook.Events(),
+		Active:              webhook.Active,
+		ConsecutiveFailures: webhook.ConsecutiveFailures,
+		DisabledAt:          webhook.DisabledAt,
+		DisabledReason:      webhook.DisabledReason,
+		LastDeliveryAt:      webhook.LastDeliveryAt,
+		CreatedAt:           webhook.CreatedAt,
+	}
+	if webhook.ClientID != nil {
+		clientID := webhook.ClientID.String()
+		resp.ClientID = &clientID
+	}
+	return resp
+}
+
+func ToWebhookDeliveryResponse(delivery *models.WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:             delivery.ID.String(),
+		EventID:        delivery.EventID.String(),
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		MaxAttempts:    delivery.MaxAttempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		ResponseBody:   delivery.ResponseBody,
+		DurationMs:     delivery.DurationMs,
+		DeliveredAt:    delivery.DeliveredAt,
+		Payload:        json.RawMessage(delivery.Payload),
+		CreatedAt:      delivery.CreatedAt,
+	}
+
+	// Only a delivery still waiting has a next attempt worth showing
+	if delivery.Status == models.WebhookDeliveryPending {
+		resp.NextAttemptAt = &delivery.NextAttemptAt
+	}
+
+	return resp
+}
@@ -3,8 +3,10 @@ package jobs
 import (
 	"context"
 	"log"
+	"sync"
 	"time"
 
+	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"github.com/habbazettt/nutrisnap-server/internal/repositories"
 	"github.com/habbazettt/nutrisnap-server/pkg/storage"
 )
@@ -30,20 +32,23 @@ func DefaultCleanupConfig() CleanupConfig {
 
 // CleanupJob handles periodic cleanup of old images
 type CleanupJob struct {
-	config        CleanupConfig
-	scanRepo      repositories.ScanRepository
-	storageClient *storage.CloudinaryClient
-	stopChan      chan struct{}
-	isRunning     bool
+	config    CleanupConfig
+	scanRepo  repositories.ScanRepository
+	usageRepo repositories.StorageUsageRepository
+	store     storage.ObjectStore
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	isRunning bool
 }
 
 // NewCleanupJob creates a new cleanup job
-func NewCleanupJob(config CleanupConfig, scanRepo repositories.ScanRepository, storageClient *storage.CloudinaryClient) *CleanupJob {
+func NewCleanupJob(config CleanupConfig, scanRepo repositories.ScanRepository, usageRepo repositories.StorageUsageRepository, store storage.ObjectStore) *CleanupJob {
 	return &CleanupJob{
-		config:        config,
-		scanRepo:      scanRepo,
-		storageClient: storageClient,
-		stopChan:      make(chan struct{}),
+		config:    config,
+		scanRepo:  scanRepo,
+		usageRepo: usageRepo,
+		store:     store,
 	}
 }
 
@@ -53,8 +58,12 @@ func (j *CleanupJob) Start() {
 		return
 	}
 	j.isRunning = true
+	j.ctx, j.cancel = context.WithCancel(context.Background())
 
+	j.wg.Add(1)
 	go func() {
+		defer j.wg.Done()
+
 		// Run immediately on start
 		j.runCleanup()
 
@@ -65,7 +74,7 @@ func (j *CleanupJob) Start() {
 			select {
 			case <-ticker.C:
 				j.runCleanup()
-			case <-j.stopChan:
+			case <-j.ctx.Done():
 				log.Println("Cleanup job stopped")
 				return
 			}
@@ -75,18 +84,32 @@ func (j *CleanupJob) Start() {
 	log.Printf("Cleanup job started (retention: %d days, interval: %s)", j.config.RetentionDays, j.config.Interval)
 }
 
-// Stop stops the cleanup job
-func (j *CleanupJob) Stop() {
+// Shutdown stops the scheduler and waits for a cleanup in progress to
+// finish the image it is working on, or for ctx to expire
+func (j *CleanupJob) Shutdown(ctx context.Context) error {
 	if !j.isRunning {
-		return
+		return nil
 	}
-	close(j.stopChan)
+	j.cancel()
 	j.isRunning = false
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // runCleanup performs the actual cleanup
 func (j *CleanupJob) runCleanup() {
-	ctx := context.Background()
+	ctx := j.ctx
 	cutoffDate := time.Now().AddDate(0, 0, -j.config.RetentionDays)
 
 	log.Printf("Running cleanup for images older than %s", cutoffDate.Format("2006-01-02"))
@@ -107,33 +130,65 @@ func (j *CleanupJob) runCleanup() {
 	failedCount := 0
 
 	for _, scan := range scans {
+		if ctx.Err() != nil {
+			log.Println("Cleanup interrupted by shutdown")
+			break
+		}
+
 		if scan.ImageRef == nil || !scan.ImageStored {
 			continue
 		}
 
-		// Delete image from Cloudinary
-		if err := j.storageClient.Delete(ctx, *scan.ImageRef); err != nil {
-			log.Printf("Failed to delete image %s: %v", *scan.ImageRef, err)
+		// Delete every image of the scan and their variants from storage
+		if err := j.deleteImages(ctx, &scan); err != nil {
+			log.Printf("Failed to delete images of scan %s: %v", scan.ID, err)
 			failedCount++
 			continue
 		}
 
 		// Update scan record - mark image as not stored
-		scan.ImageStored = false
-		scan.ImageRef = nil
-		if err := j.scanRepo.Update(&scan); err != nil {
+		if err := j.scanRepo.ReleaseImages(scan.ID); err != nil {
 			log.Printf("Failed to update scan %s: %v", scan.ID, err)
 			failedCount++
 			continue
 		}
 
+		if scan.UserID != nil {
+			bytes, objects := scan.StoredUsage()
+			if err := j.usageRepo.Add(*scan.UserID, -bytes, -objects); err != nil {
+				log.Printf("Failed to update storage usage of user %s: %v", *scan.UserID, err)
+			}
+		}
+
 		deletedCount++
 	}
 
 	log.Printf("Cleanup completed: %d deleted, %d failed", deletedCount, failedCount)
 }
 
+// deleteImages deletes each stored image of a scan once
+func (j *CleanupJob) deleteImages(ctx context.Context, scan *models.Scan) error {
+	seen := make(map[string]bool)
+	for _, ref := range scanImageRefs(scan) {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		if err := storage.DeleteImage(ctx, j.store, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // RunNow runs cleanup immediately (for manual trigger)
 func (j *CleanupJob) RunNow() {
-	go j.runCleanup()
+	if !j.isRunning {
+		return
+	}
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		j.runCleanup()
+	}()
 }
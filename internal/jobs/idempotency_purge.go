@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+)
+
+const (
+	// idempotencyPurgeInterval is how often expired keys are deleted
+	idempotencyPurgeInterval = time.Hour
+	// idempotencyPurgeBatch bounds each delete so it never holds locks long
+	idempotencyPurgeBatch = 1000
+)
+
+// IdempotencyPurgeJob deletes expired idempotency keys. Expired keys are
+// already ignored when a request arrives, this only keeps the table small.
+type IdempotencyPurgeJob struct {
+	repo   repositories.IdempotencyRepository
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewIdempotencyPurgeJob creates a new purge job
+func NewIdempotencyPurgeJob(repo repositories.IdempotencyRepository) *IdempotencyPurgeJob {
+	return &IdempotencyPurgeJob{repo: repo}
+}
+
+// Start starts the purge scheduler
+func (j *IdempotencyPurgeJob) Start() {
+	if j.cancel != nil {
+		return
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+
+		ticker := time.NewTicker(idempotencyPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.purge()
+			case <-j.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the scheduler and waits for a purge in progress to finish
+// its batch, or for ctx to expire
+func (j *IdempotencyPurgeJob) Shutdown(ctx context.Context) error {
+	if j.cancel == nil {
+		return nil
+	}
+	j.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (j *IdempotencyPurgeJob) purge() {
+	var total int64
+	for j.ctx.Err() == nil {
+		deleted, err := j.repo.DeleteExpired(idempotencyPurgeBatch)
+		if err != nil {
+			log.Printf("Failed to purge expired idempotency keys: %v", err)
+			return
+		}
+		total += deleted
+		if deleted < idempotencyPurgeBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("Purged %d expired idempotency key(s)", total)
+	}
+}
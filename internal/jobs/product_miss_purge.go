@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+)
+
+const (
+	// productMissPurgeInterval is how often expired misses are deleted
+	productMissPurgeInterval = time.Hour
+	// productMissPurgeBatch bounds each delete so it never holds locks long
+	productMissPurgeBatch = 1000
+)
+
+// ProductMissPurgeJob deletes expired product misses. Expired misses are
+// already ignored by lookups, this only keeps the table small.
+type ProductMissPurgeJob struct {
+	repo   repositories.ProductRepository
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewProductMissPurgeJob creates a new purge job
+func NewProductMissPurgeJob(repo repositories.ProductRepository) *ProductMissPurgeJob {
+	return &ProductMissPurgeJob{repo: repo}
+}
+
+// Start starts the purge scheduler
+func (j *ProductMissPurgeJob) Start() {
+	if j.cancel != nil {
+		return
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+
+		ticker := time.NewTicker(productMissPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.purge()
+			case <-j.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the scheduler and waits for a purge in progress to finish
+// its batch, or for ctx to expire
+func (j *ProductMissPurgeJob) Shutdown(ctx context.Context) error {
+	if j.cancel == nil {
+		return nil
+	}
+	j.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (j *ProductMissPurgeJob) purge() {
+	var total int64
+	for j.ctx.Err() == nil {
+		deleted, err := j.repo.DeleteExpiredMisses(productMissPurgeBatch)
+		if err != nil {
+			log.Printf("Failed to purge expired product misses: %v", err)
+			return
+		}
+		total += deleted
+		if deleted < productMissPurgeBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("Purged %d expired product miss(es)", total)
+	}
+}
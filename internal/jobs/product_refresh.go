@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/openfoodfacts"
+)
+
+const (
+	// productRefreshTimeout bounds the refresh of one product
+	productRefreshTimeout = 30 * time.Second
+	// productRefreshPause spaces out requests to Open Food Facts, which
+	// asks clients to keep product reads low
+	productRefreshPause = time.Second
+	// productRefreshLease names the lease that keeps the refresh to one
+	// instance at a time
+	productRefreshLease = "product_refresh"
+)
+
+// ProductRefresher refreshes one cached product from Open Food Facts
+type ProductRefresher interface {
+	RefreshProduct(ctx context.Context, product *models.Product) (bool, error)
+}
+
+// ProductRefreshConfig holds product refresh configuration
+type ProductRefreshConfig struct {
+	// Interval is how often to run the refresh
+	Interval time.Duration
+	// StaleAfter is the age a fetched product must reach to be refreshed
+	StaleAfter time.Duration
+	// Batch is how many products one run refreshes
+	Batch int
+}
+
+// ProductRefreshJob refreshes stale Open Food Facts products, most scanned
+// first, so popular products pick up reformulations before anyone looks
+// them up. Lookups refresh the rest when they find them stale. Only one
+// instance refreshes at a time.
+type ProductRefreshJob struct {
+	config      ProductRefreshConfig
+	productRepo repositories.ProductRepository
+	leaseRepo   repositories.JobLeaseRepository
+	refresher   ProductRefresher
+	holder      string // identifies this instance as the lease holder
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// NewProductRefreshJob creates a new product refresh job
+func NewProductRefreshJob(config ProductRefreshConfig, productRepo repositories.ProductRepository, leaseRepo repositories.JobLeaseRepository, refresher ProductRefresher) *ProductRefreshJob {
+	return &ProductRefreshJob{
+		config:      config,
+		productRepo: productRepo,
+		leaseRepo:   leaseRepo,
+		refresher:   refresher,
+		holder:      uuid.NewString(),
+	}
+}
+
+// Start starts the refresh scheduler
+func (j *ProductRefreshJob) Start() {
+	if j.cancel != nil {
+		return
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+
+		ticker := time.NewTicker(j.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.refresh()
+			case <-j.ctx.Done():
+				log.Println("Product refresh job stopped")
+				return
+			}
+		}
+	}()
+
+	log.Printf("Product refresh job started (batch: %d, stale after: %s, interval: %s)", j.config.Batch, j.config.StaleAfter, j.config.Interval)
+}
+
+// Shutdown stops the scheduler and waits for a run in progress to stop at
+// the next product, or for ctx to expire
+func (j *ProductRefreshJob) Shutdown(ctx context.Context) error {
+	if j.cancel == nil {
+		return nil
+	}
+	j.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (j *ProductRefreshJob) refresh() {
+	// A run outliving the interval is assumed to have died with its process
+	started, err := j.leaseRepo.TryAcquire(productRefreshLease, j.holder, j.config.Interval)
+	if err != nil {
+		log.Printf("Failed to start product refresh: %v", err)
+		return
+	}
+	if !started {
+		log.Println("Product refresh already running on another instance")
+		return
+	}
+	defer func() {
+		if err := j.leaseRepo.Release(productRefreshLease, j.holder); err != nil {
+			log.Printf("Failed to release the product refresh lease: %v", err)
+		}
+	}()
+
+	products, err := j.productRepo.FindRefreshCandidates(time.Now().Add(-j.config.StaleAfter), j.config.Batch)
+	if err != nil {
+		log.Printf("Failed to find products to refresh: %v", err)
+		return
+	}
+	if len(products) == 0 {
+		return
+	}
+
+	var refreshed, changed, failed int
+	for i := range products {
+		if i > 0 {
+			select {
+			case <-time.After(productRefreshPause):
+			case <-j.ctx.Done():
+			}
+		}
+		if j.ctx.Err() != nil {
+			break
+		}
+
+		ctx, cancel := context.WithTimeout(j.ctx, productRefreshTimeout)
+		updated, err := j.refresher.RefreshProduct(ctx, &products[i])
+		cancel()
+		if errors.Is(err, openfoodfacts.ErrUnavailable) {
+			log.Println("Open Food Facts is unavailable, stopping the product refresh until the next run")
+			break
+		}
+		if err != nil {
+			log.Printf("Failed to refresh product %s: %v", products[i].Barcode, err)
+			failed++
+			continue
+		}
+		refreshed++
+		if updated {
+			changed++
+		}
+	}
+
+	log.Printf("Product refresh: %d refreshed, %d with changed nutrients or grade, %d failed", refreshed, changed, failed)
+}
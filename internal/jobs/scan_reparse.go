@@ -0,0 +1,228 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+)
+
+const (
+	// reparsePage is the number of scans read and saved per step of a run
+	reparsePage = 100
+	// reparsePollInterval is how often idle workers look for queued runs
+	reparsePollInterval = 10 * time.Second
+	// reparseVisibility is how long a run stays locked without progress
+	// before another worker takes it over
+	reparseVisibility = 5 * time.Minute
+	// maxReparseResults bounds the changed scans kept in a report
+	maxReparseResults = 1000
+	// maxReparseErrors bounds the errors kept in a report
+	maxReparseErrors = 100
+)
+
+// ScanReparser runs the current parser over the stored OCR text of a scan
+type ScanReparser interface {
+	ReparseScan(ctx context.Context, scan *models.Scan, dryRun bool) (*dto.ScanReparseResult, error)
+}
+
+// ReparseJob works through bulk re-parse runs queued by admins, one at a
+// time per worker process. Runs are shared between instances through SKIP
+// LOCKED, like scan jobs.
+type ReparseJob struct {
+	scanRepo    repositories.ScanRepository
+	reparseRepo repositories.ScanReparseRepository
+	reparser    ScanReparser
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// NewReparseJob creates a new bulk re-parse job
+func NewReparseJob(scanRepo repositories.ScanRepository, reparseRepo repositories.ScanReparseRepository, reparser ScanReparser) *ReparseJob {
+	return &ReparseJob{
+		scanRepo:    scanRepo,
+		reparseRepo: reparseRepo,
+		reparser:    reparser,
+	}
+}
+
+// Start starts polling for queued runs
+func (j *ReparseJob) Start() {
+	if j.cancel != nil {
+		return
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+
+		for {
+			run, err := j.reparseRepo.Claim(reparseVisibility)
+			if err == nil {
+				j.process(run)
+				continue
+			}
+			if !errors.Is(err, repositories.ErrNoScanReparse) {
+				log.Printf("Failed to claim scan reparse: %v", err)
+			}
+
+			select {
+			case <-time.After(reparsePollInterval):
+			case <-j.ctx.Done():
+				log.Println("Scan reparse job stopped")
+				return
+			}
+		}
+	}()
+
+	log.Printf("Scan reparse job started (page: %d, poll interval: %s)", reparsePage, reparsePollInterval)
+}
+
+// Shutdown stops taking runs and waits for the current one to stop at the
+// next scan, or for ctx to expire. A run cut short is picked up again by
+// any worker once its lock expires.
+func (j *ReparseJob) Shutdown(ctx context.Context) error {
+	if j.cancel == nil {
+		return nil
+	}
+	j.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reparseReport is stored with a run to show what it changed
+type reparseReport struct {
+	Results []dto.ScanReparseResult `json:"results"`
+	Errors  []dto.ReparseError      `json:"errors,omitempty"`
+	// Truncated is set when a list was cut at its cap
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// process reads the run's scans page by page from its cursor, saving
+// progress after each page
+func (j *ReparseJob) process(run *models.ScanReparse) {
+	log.Printf("Scan reparse %s: started from %d scans processed (dry run: %t)", run.ID, run.Processed, run.DryRun)
+
+	var filter repositories.ScanFilter
+	if err := json.Unmarshal(run.FilterJSON, &filter); err != nil {
+		j.finish(run, fmt.Errorf("invalid filter: %w", err))
+		return
+	}
+	report := reparseReport{Results: []dto.ScanReparseResult{}}
+	if len(run.ReportJSON) > 0 {
+		if err := json.Unmarshal(run.ReportJSON, &report); err != nil {
+			j.finish(run, fmt.Errorf("invalid report: %w", err))
+			return
+		}
+	}
+
+	for run.Limit == 0 || run.Processed < run.Limit {
+		if j.ctx.Err() != nil {
+			return
+		}
+
+		var after *repositories.ScanCursor
+		if run.CursorID != nil && run.CursorCreatedAt != nil {
+			after = &repositories.ScanCursor{CreatedAt: *run.CursorCreatedAt, ID: *run.CursorID}
+		}
+		page := reparsePage
+		if run.Limit > 0 {
+			page = min(page, run.Limit-run.Processed)
+		}
+		scans, err := j.scanRepo.FindWithOCRText(filter, after, page)
+		if err != nil {
+			j.finish(run, fmt.Errorf("failed to find scans: %w", err))
+			return
+		}
+		if len(scans) == 0 {
+			break
+		}
+
+		for i := range scans {
+			if j.ctx.Err() != nil {
+				// The page is read again from the saved cursor
+				return
+			}
+			j.reparse(run, &report, &scans[i])
+		}
+
+		last := scans[len(scans)-1]
+		run.CursorCreatedAt = &last.CreatedAt
+		run.CursorID = &last.ID
+		run.ReportJSON, _ = json.Marshal(report)
+		if err := j.reparseRepo.SaveProgress(run, reparseVisibility); err != nil {
+			log.Printf("Scan reparse %s: failed to save progress, leaving the run: %v", run.ID, err)
+			return
+		}
+		if len(scans) < page {
+			break
+		}
+	}
+
+	j.finish(run, nil)
+}
+
+// reparse runs one scan and counts its outcome
+func (j *ReparseJob) reparse(run *models.ScanReparse, report *reparseReport, scan *models.Scan) {
+	run.Processed++
+	result, err := j.reparser.ReparseScan(j.ctx, scan, run.DryRun)
+	if err != nil {
+		run.Failed++
+		if len(report.Errors) < maxReparseErrors {
+			report.Errors = append(report.Errors, dto.ReparseError{ScanID: scan.ID.String(), Error: err.Error()})
+		} else {
+			report.Truncated = true
+		}
+		return
+	}
+
+	if !result.Changed {
+		run.Unchanged++
+		return
+	}
+	run.Changed++
+	if len(report.Results) < maxReparseResults {
+		report.Results = append(report.Results, *result)
+	} else {
+		report.Truncated = true
+	}
+}
+
+// finish records how a run ended, failed when err is set
+func (j *ReparseJob) finish(run *models.ScanReparse, err error) {
+	now := time.Now()
+	run.Status = models.ReparseCompleted
+	run.FinishedAt = &now
+	if err != nil {
+		message := err.Error()
+		run.Status = models.ReparseFailed
+		run.Error = &message
+	}
+
+	if err := j.reparseRepo.Finish(run); err != nil {
+		log.Printf("Scan reparse %s: failed to save result: %v", run.ID, err)
+		return
+	}
+	log.Printf("Scan reparse %s: %s with %d scans processed, %d changed, %d failed",
+		run.ID, run.Status, run.Processed, run.Changed, run.Failed)
+}
@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+)
+
+// orderedScanRepo serves scans oldest first from a cursor, like postgres
+type orderedScanRepo struct {
+	repositories.ScanRepository
+	scans []models.Scan
+}
+
+func (r *orderedScanRepo) FindWithOCRText(filter repositories.ScanFilter, after *repositories.ScanCursor, limit int) ([]models.Scan, error) {
+	var found []models.Scan
+	for _, scan := range r.scans {
+		if after != nil && !scan.CreatedAt.After(after.CreatedAt) {
+			continue
+		}
+		found = append(found, scan)
+		if len(found) == limit {
+			break
+		}
+	}
+	return found, nil
+}
+
+// savedReparseRepo keeps the last saved state of a run. After failAfter
+// saves it reports the lock as lost, as when another worker took the run.
+type savedReparseRepo struct {
+	repositories.ScanReparseRepository
+	saved     models.ScanReparse
+	saves     int
+	failAfter int
+}
+
+func (r *savedReparseRepo) SaveProgress(run *models.ScanReparse, visibility time.Duration) error {
+	if r.failAfter > 0 && r.saves == r.failAfter {
+		return repositories.ErrScanReparseLockLost
+	}
+	r.saves++
+	r.saved = *run
+	return nil
+}
+
+func (r *savedReparseRepo) Finish(run *models.ScanReparse) error {
+	r.saved = *run
+	return nil
+}
+
+// countingReparser changes every third scan and fails every seventh
+type countingReparser struct {
+	seen map[uuid.UUID]int
+}
+
+func (r *countingReparser) ReparseScan(ctx context.Context, scan *models.Scan, dryRun bool) (*dto.ScanReparseResult, error) {
+	r.seen[scan.ID]++
+	n := scan.CreatedAt.Minute()
+	if n%7 == 0 {
+		return nil, errors.New("no OCR text")
+	}
+	return &dto.ScanReparseResult{ScanID: scan.ID.String(), Changed: n%3 == 0}, nil
+}
+
+func newReparseTest(count int) (*ReparseJob, *orderedScanRepo, *savedReparseRepo, *countingReparser) {
+	scans := &orderedScanRepo{}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		scan := models.Scan{}
+		scan.ID = uuid.New()
+		scan.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		scans.scans = append(scans.scans, scan)
+	}
+	runs := &savedReparseRepo{}
+	reparser := &countingReparser{seen: make(map[uuid.UUID]int)}
+
+	job := NewReparseJob(scans, runs, reparser)
+	job.ctx, job.cancel = context.WithCancel(context.Background())
+	return job, scans, runs, reparser
+}
+
+func newRun(limit int) *models.ScanReparse {
+	run := &models.ScanReparse{
+		Status:     models.ReparseRunning,
+		DryRun:     true,
+		FilterJSON: models.JSON(`{}`),
+		Limit:      limit,
+	}
+	run.ID = uuid.New()
+	return run
+}
+
+func TestReparseJobReadsEveryScanOnce(t *testing.T) {
+	job, scans, runs, reparser := newReparseTest(250)
+	job.process(newRun(0))
+
+	run := runs.saved
+	if run.Status != models.ReparseCompleted {
+		t.Fatalf("status = %s, want completed (error: %v)", run.Status, run.Error)
+	}
+	if run.Processed != 250 || run.Changed+run.Unchanged+run.Failed != 250 {
+		t.Errorf("processed %d (%d changed, %d unchanged, %d failed), want 250",
+			run.Processed, run.Changed, run.Unchanged, run.Failed)
+	}
+	for _, scan := range scans.scans {
+		if reparser.seen[scan.ID] != 1 {
+			t.Fatalf("scan %s read %d times, want once", scan.ID, reparser.seen[scan.ID])
+		}
+	}
+
+	var report reparseReport
+	if err := json.Unmarshal(run.ReportJSON, &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if len(report.Results) != run.Changed || len(report.Errors) != run.Failed {
+		t.Errorf("report has %d results and %d errors, want %d and %d",
+			len(report.Results), len(report.Errors), run.Changed, run.Failed)
+	}
+}
+
+func TestReparseJobLimit(t *testing.T) {
+	job, _, runs, reparser := newReparseTest(250)
+	job.process(newRun(150))
+
+	if runs.saved.Status != models.ReparseCompleted || runs.saved.Processed != 150 {
+		t.Errorf("status %s with %d processed, want completed with 150", runs.saved.Status, runs.saved.Processed)
+	}
+	if len(reparser.seen) != 150 {
+		t.Errorf("%d scans read, want 150", len(reparser.seen))
+	}
+}
+
+func TestReparseJobResumesFromCursor(t *testing.T) {
+	job, scans, runs, reparser := newReparseTest(250)
+	runs.failAfter = 1
+	job.process(newRun(0))
+
+	if runs.saved.Processed != reparsePage || runs.saved.IsFinished() {
+		t.Fatalf("saved %d processed (finished: %t), want %d and running",
+			runs.saved.Processed, runs.saved.IsFinished(), reparsePage)
+	}
+
+	// Another worker takes the run over from its last save
+	runs.failAfter = 0
+	resumed := runs.saved
+	job.process(&resumed)
+
+	if runs.saved.Status != models.ReparseCompleted || runs.saved.Processed != 250 {
+		t.Fatalf("status %s with %d processed, want completed with 250", runs.saved.Status, runs.saved.Processed)
+	}
+	// Only the page whose progress was lost is read again
+	for i, scan := range scans.scans {
+		want := 1
+		if i >= reparsePage && i < 2*reparsePage {
+			want = 2
+		}
+		if reparser.seen[scan.ID] != want {
+			t.Fatalf("scan %d read %d times, want %d", i, reparser.seen[scan.ID], want)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/storage"
+)
+
+const (
+	// scanImagePrefix is the key prefix scan images are uploaded under
+	scanImagePrefix = "scans/"
+	// reconcileBatch is the number of scans compared per query
+	reconcileBatch = 200
+	// maxReconcileDetails bounds each list kept in a report
+	maxReconcileDetails = 100
+)
+
+// ReconcileConfig holds storage reconciliation configuration
+type ReconcileConfig struct {
+	// Interval is how often to run the reconciliation
+	Interval time.Duration
+	// GracePeriod keeps unreferenced objects this young, since an upload
+	// is stored before the scan that references it
+	GracePeriod time.Duration
+}
+
+// ReconcileJob compares stored scan images with the scans referencing
+// them. Objects no scan references are deleted once past the grace period,
+// scans whose images are gone are flagged, and missing variants are
+// rendered again. Every run leaves a report and recalculates per-user
+// storage usage.
+type ReconcileJob struct {
+	config     ReconcileConfig
+	scanRepo   repositories.ScanRepository
+	reportRepo repositories.StorageReconciliationRepository
+	usageRepo  repositories.StorageUsageRepository
+	store      storage.ObjectStore
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// reconcileDetails is stored with a report to show what a run acted on
+type reconcileDetails struct {
+	DeletedOrphans []string `json:"deleted_orphans,omitempty"`
+	MissingScans   []string `json:"missing_scans,omitempty"`
+	Errors         []string `json:"errors,omitempty"`
+	// Truncated is set when a list was cut at maxReconcileDetails
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (d *reconcileDetails) add(list *[]string, item string) {
+	if len(*list) >= maxReconcileDetails {
+		d.Truncated = true
+		return
+	}
+	*list = append(*list, item)
+}
+
+// NewReconcileJob creates a new storage reconciliation job
+func NewReconcileJob(config ReconcileConfig, scanRepo repositories.ScanRepository, reportRepo repositories.StorageReconciliationRepository, usageRepo repositories.StorageUsageRepository, store storage.ObjectStore) *ReconcileJob {
+	return &ReconcileJob{
+		config:     config,
+		scanRepo:   scanRepo,
+		reportRepo: reportRepo,
+		usageRepo:  usageRepo,
+		store:      store,
+	}
+}
+
+// Start starts the reconciliation scheduler
+func (j *ReconcileJob) Start() {
+	if j.cancel != nil {
+		return
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+
+		// Run immediately on start
+		j.reconcile()
+
+		ticker := time.NewTicker(j.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.reconcile()
+			case <-j.ctx.Done():
+				log.Println("Storage reconciliation job stopped")
+				return
+			}
+		}
+	}()
+
+	log.Printf("Storage reconciliation job started (grace period: %s, interval: %s)", j.config.GracePeriod, j.config.Interval)
+}
+
+// Shutdown stops the scheduler and waits for a run in progress to stop at
+// the next object or batch, or for ctx to expire
+func (j *ReconcileJob) Shutdown(ctx context.Context) error {
+	if j.cancel == nil {
+		return nil
+	}
+	j.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reconcile performs one run and stores its report
+func (j *ReconcileJob) reconcile() {
+	report := &models.StorageReconciliation{
+		Status:    models.ReconciliationRunning,
+		StartedAt: time.Now(),
+	}
+	started, err := j.reportRepo.TryStart(report, j.config.Interval)
+	if err != nil {
+		log.Printf("Failed to start storage reconciliation: %v", err)
+		return
+	}
+	if !started {
+		log.Println("Storage reconciliation already running on another instance")
+		return
+	}
+
+	var details reconcileDetails
+	err = j.run(j.ctx, report, &details)
+
+	finishedAt := time.Now()
+	report.FinishedAt = &finishedAt
+	report.Status = models.ReconciliationCompleted
+	if err != nil {
+		message := err.Error()
+		report.Status = models.ReconciliationFailed
+		report.Error = &message
+	}
+	report.DetailsJSON, _ = json.Marshal(details)
+
+	// Record the outcome even when the run was cut short by shutdown
+	if err := j.reportRepo.Update(report); err != nil {
+		log.Printf("Failed to save storage reconciliation report %s: %v", report.ID, err)
+	}
+
+	log.Printf("Storage reconciliation %s: %d objects, %d orphans deleted, %d within grace period, %d of %d scans missing images, %d variants restored, %d errors",
+		report.Status, report.ObjectsListed, report.OrphansDeleted, report.OrphansPending,
+		report.ScansMissing, report.ScansChecked, report.VariantsRestored, report.Errors)
+}
+
+func (j *ReconcileJob) run(ctx context.Context, report *models.StorageReconciliation, details *reconcileDetails) error {
+	objects, err := j.store.List(ctx, scanImagePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list stored objects: %w", err)
+	}
+	report.ObjectsListed = len(objects)
+
+	listed := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		listed[object.Ref] = true
+	}
+
+	referenced, err := j.checkScans(ctx, report, details, listed)
+	if err != nil {
+		return err
+	}
+
+	cutoff := report.StartedAt.Add(-j.config.GracePeriod)
+	for _, object := range objects {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if referenced[object.Ref] {
+			continue
+		}
+		if object.LastModified.After(cutoff) {
+			report.OrphansPending++
+			continue
+		}
+
+		if err := j.store.Delete(ctx, object.Ref); err != nil {
+			report.Errors++
+			details.add(&details.Errors, fmt.Sprintf("delete %s: %v", object.Ref, err))
+			continue
+		}
+		report.OrphansDeleted++
+		details.add(&details.DeletedOrphans, object.Ref)
+	}
+
+	// Corrects usage drifted by updates that failed after images changed
+	if err := j.usageRepo.Recalculate(); err != nil {
+		return fmt.Errorf("failed to recalculate storage usage: %w", err)
+	}
+
+	return nil
+}
+
+// checkScans walks every scan with images, flagging those whose stored
+// images are gone and clearing the flag on those found again. It returns
+// every ref a scan still points at, including the variants stored with
+// its images.
+func (j *ReconcileJob) checkScans(ctx context.Context, report *models.StorageReconciliation, details *reconcileDetails, listed map[string]bool) (map[string]bool, error) {
+	referenced := make(map[string]bool)
+
+	var after uuid.UUID
+	for {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		// Scans newer than the listing may point at objects it did not see
+		scans, err := j.scanRepo.FindWithImages(after, report.StartedAt, reconcileBatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load scans: %w", err)
+		}
+		if len(scans) == 0 {
+			return referenced, nil
+		}
+		after = scans[len(scans)-1].ID
+
+		var flag, clear []uuid.UUID
+		for i := range scans {
+			scan := &scans[i]
+			report.ScansChecked++
+
+			refs := scanImageRefs(scan)
+			for _, ref := range refs {
+				referenced[ref] = true
+				for _, variant := range storage.StoredVariantRefs(j.store, ref) {
+					referenced[variant] = true
+				}
+			}
+			// The cleanup job removed these images on purpose
+			if !scan.ImageStored {
+				continue
+			}
+			j.restoreVariants(ctx, scan, report, details, listed)
+
+			missing, err := j.anyMissing(ctx, refs, listed)
+			if err != nil {
+				report.Errors++
+				details.add(&details.Errors, fmt.Sprintf("check scan %s: %v", scan.ID, err))
+				continue
+			}
+
+			switch {
+			case missing:
+				report.ScansMissing++
+				details.add(&details.MissingScans, scan.ID.String())
+				if scan.ImageMissingAt == nil {
+					flag = append(flag, scan.ID)
+				}
+			case scan.ImageMissingAt != nil:
+				clear = append(clear, scan.ID)
+			}
+		}
+
+		if err := j.scanRepo.SetImageMissing(flag, &report.StartedAt); err != nil {
+			return nil, fmt.Errorf("failed to flag scans: %w", err)
+		}
+		if err := j.scanRepo.SetImageMissing(clear, nil); err != nil {
+			return nil, fmt.Errorf("failed to clear scan flags: %w", err)
+		}
+		report.ScansRestored += len(clear)
+	}
+}
+
+// restoreVariants renders the variants of a scan's images again where
+// they are missing, such as for Cloudinary images uploaded while it
+// resized images on delivery
+func (j *ReconcileJob) restoreVariants(ctx context.Context, scan *models.Scan, report *models.StorageReconciliation, details *reconcileDetails, listed map[string]bool) {
+	for _, image := range scan.Images {
+		if !image.HasVariants || !listed[image.ImageRef] {
+			continue
+		}
+
+		missing := false
+		for _, variant := range storage.StoredVariantRefs(j.store, image.ImageRef) {
+			missing = missing || !listed[variant]
+		}
+		if !missing {
+			continue
+		}
+
+		if err := storage.RestoreVariants(ctx, j.store, image.ImageRef); err != nil {
+			report.Errors++
+			details.add(&details.Errors, fmt.Sprintf("restore variants of %s: %v", image.ImageRef, err))
+			continue
+		}
+		report.VariantsRestored++
+	}
+}
+
+// anyMissing reports whether any ref is no longer stored. Refs outside the
+// listing, such as images uploaded under an older key layout, are looked
+// up one by one.
+func (j *ReconcileJob) anyMissing(ctx context.Context, refs []string, listed map[string]bool) (bool, error) {
+	for _, ref := range refs {
+		if listed[ref] {
+			continue
+		}
+		exists, err := j.store.Exists(ctx, ref)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// scanImageRefs returns every ref a scan points at, stored or not
+func scanImageRefs(scan *models.Scan) []string {
+	var refs []string
+	if scan.ImageRef != nil {
+		refs = append(refs, *scan.ImageRef)
+	}
+	for _, image := range scan.Images {
+		refs = append(refs, image.ImageRef)
+	}
+	return refs
+}
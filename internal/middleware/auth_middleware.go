@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"github.com/habbazettt/nutrisnap-server/pkg/constants"
 	"github.com/habbazettt/nutrisnap-server/pkg/jwt"
 	"github.com/habbazettt/nutrisnap-server/pkg/response"
@@ -14,18 +15,53 @@ const (
 	UserIDKey    = "user_id"
 	UserEmailKey = "user_email"
 	UserRoleKey  = "user_role"
+	// APIClientIDKey is set when an API client made the request
+	APIClientIDKey = "api_client_id"
 )
 
+// APIKeyHeader carries the API key of a partner backend
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator returns the client an API key belongs to, or an
+// error for keys that cannot be used
+type APIKeyAuthenticator interface {
+	Authenticate(key string) (*models.APIClient, error)
+}
+
 // AuthConfig holds authentication middleware configuration
 type AuthConfig struct {
 	JWTManager *jwt.Manager
+	// QueryParam, when set, accepts the access token from this query
+	// parameter on WebSocket upgrades, since browsers cannot set headers there
+	QueryParam string
+	// APIClients, when set, also accepts an API key in APIKeyHeader. The
+	// request then acts for the user who registered the client.
+	APIClients APIKeyAuthenticator
 }
 
 // JWTAuth creates a JWT authentication middleware
 func JWTAuth(config AuthConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if key := c.Get(APIKeyHeader); key != "" && config.APIClients != nil {
+			client, err := config.APIClients.Authenticate(key)
+			if err != nil {
+				return response.Error(c,
+					constants.GetHTTPStatus(constants.StatusUnauthorized),
+					"Invalid API key",
+				)
+			}
+			c.Locals(UserIDKey, client.UserID.String())
+			c.Locals(APIClientIDKey, client.ID.String())
+			return c.Next()
+		}
+
 		// Get Authorization header
 		authHeader := c.Get("Authorization")
+		if authHeader == "" && config.QueryParam != "" && isWebSocketUpgrade(c) {
+			if token := c.Query(config.QueryParam); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
 		if authHeader == "" {
 			return response.Error(c,
 				constants.GetHTTPStatus(constants.StatusUnauthorized),
@@ -75,6 +111,10 @@ func JWTAuth(config AuthConfig) fiber.Handler {
 	}
 }
 
+func isWebSocketUpgrade(c *fiber.Ctx) bool {
+	return strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket")
+}
+
 // GetUserID retrieves the user ID from context
 func GetUserID(c *fiber.Ctx) string {
 	if id, ok := c.Locals(UserIDKey).(string); ok {
@@ -83,6 +123,15 @@ func GetUserID(c *fiber.Ctx) string {
 	return ""
 }
 
+// GetAPIClientID retrieves the API client ID from context, which is empty
+// for requests made by the user
+func GetAPIClientID(c *fiber.Ctx) string {
+	if id, ok := c.Locals(APIClientIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
 // GetUserEmail retrieves the user email from context
 func GetUserEmail(c *fiber.Ctx) string {
 	if email, ok := c.Locals(UserEmailKey).(string); ok {
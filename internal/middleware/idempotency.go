@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+	"github.com/habbazettt/nutrisnap-server/pkg/response"
+)
+
+const (
+	// HeaderIdempotencyKey is sent by clients that may retry a request
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed marks a response returned from a stored key
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyStore keeps idempotency keys and the responses they produced
+type IdempotencyStore interface {
+	Acquire(key *models.IdempotencyKey, staleAfter time.Duration) (*models.IdempotencyKey, error)
+	Complete(key *models.IdempotencyKey) error
+	Release(key *models.IdempotencyKey) error
+}
+
+// IdempotencyConfig holds idempotency middleware configuration
+type IdempotencyConfig struct {
+	Store IdempotencyStore
+	// TTL is how long a key is remembered
+	TTL time.Duration
+	// StaleAfter is how long a request may hold a key before it is assumed
+	// lost and the key is handed to a retry
+	StaleAfter time.Duration
+}
+
+// Idempotency replays the stored response when a request is retried with
+// the same Idempotency-Key, and rejects a key reused for a different
+// request. Requests without the header pass through. It must run after
+// JWTAuth, since keys are scoped to the user and API client.
+func Idempotency(config IdempotencyConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return response.BadRequest(c, "Idempotency-Key must be at most 255 characters")
+		}
+
+		userID, err := uuid.Parse(GetUserID(c))
+		if err != nil {
+			return c.Next()
+		}
+		// API clients act for their user but must not be replayed the
+		// user's responses. Hashing keeps the scoped key within its column.
+		if clientID := GetAPIClientID(c); clientID != "" {
+			sum := sha256.Sum256([]byte(key))
+			key = clientID + ":" + hex.EncodeToString(sum[:])
+		}
+
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			return response.BadRequest(c, "Invalid request body")
+		}
+
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Method:      c.Method(),
+			Path:        c.Path(),
+			Fingerprint: fingerprint,
+			Status:      models.IdempotencyStatusProcessing,
+			ExpiresAt:   time.Now().Add(config.TTL),
+		}
+		existing, err := config.Store.Acquire(record, config.StaleAfter)
+		if err != nil {
+			logger.Error("failed to acquire idempotency key", "error", err)
+			return response.InternalError(c, "Failed to process request")
+		}
+		if existing != nil {
+			return replay(c, existing, fingerprint)
+		}
+
+		if err := c.Next(); err != nil {
+			// Let the error handler respond, and let the client retry
+			release(config.Store, record)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError || status == fiber.StatusTooManyRequests {
+			// Not a result of the request itself, so a retry may succeed
+			release(config.Store, record)
+			return nil
+		}
+
+		record.StatusCode = &status
+		record.ContentType = string(c.Response().Header.ContentType())
+		record.ResponseBody = append([]byte(nil), c.Response().Body()...)
+		if err := config.Store.Complete(record); err != nil {
+			logger.Error("failed to store idempotent response", "key", key, "error", err)
+		}
+		return nil
+	}
+}
+
+// replay answers a request whose key is already held
+func replay(c *fiber.Ctx, existing *models.IdempotencyKey, fingerprint string) error {
+	if existing.Fingerprint != fingerprint {
+		return response.Error(c, fiber.StatusUnprocessableEntity,
+			"Idempotency-Key was already used for a different request")
+	}
+	if existing.Status != models.IdempotencyStatusCompleted || existing.StatusCode == nil {
+		return response.Error(c, fiber.StatusConflict,
+			"A request with this Idempotency-Key is still being processed")
+	}
+
+	c.Set(HeaderIdempotentReplayed, "true")
+	if existing.ContentType != "" {
+		c.Set(fiber.HeaderContentType, existing.ContentType)
+	}
+	return c.Status(*existing.StatusCode).Send(existing.ResponseBody)
+}
+
+func release(store IdempotencyStore, record *models.IdempotencyKey) {
+	if err := store.Release(record); err != nil {
+		logger.Error("failed to release idempotency key", "key", record.Key, "error", err)
+	}
+}
+
+// requestFingerprint hashes what makes a request distinct. Multipart
+// bodies are hashed by their fields and file contents rather than raw
+// bytes, since clients pick a new boundary when they rebuild a request.
+func requestFingerprint(c *fiber.Ctx) (string, error) {
+	h := sha256.New()
+	writeField(h, c.Method())
+	writeField(h, c.Path())
+
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		h.Write(c.Body())
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(form.Value)) {
+		writeField(h, name)
+		for _, value := range form.Value[name] {
+			writeField(h, value)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(form.File)) {
+		writeField(h, name)
+		for _, fh := range form.File[name] {
+			writeField(h, fh.Filename)
+			writeField(h, strconv.FormatInt(fh.Size, 10))
+			f, err := fh.Open()
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeField writes a length-prefixed value so adjacent fields cannot run
+// into each other
+func writeField(h hash.Hash, value string) {
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	h.Write(size[:])
+	h.Write([]byte(value))
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIClient is a partner backend acting for the user who registered it.
+// It authenticates with an API key, of which only a hash is kept.
+type APIClient struct {
+	BaseWithoutSoftDelete
+	UserID uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name   string    `gorm:"size:100;not null" json:"name"`
+	// KeyPrefix is the start of the key, to tell keys apart in listings
+	KeyPrefix  string     `gorm:"size:20;not null" json:"key_prefix"`
+	KeyHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (APIClient) TableName() string {
+	return "api_clients"
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type IdempotencyStatus string
+
+const (
+	IdempotencyStatusProcessing IdempotencyStatus = "processing"
+	IdempotencyStatusCompleted  IdempotencyStatus = "completed"
+)
+
+// IdempotencyKey remembers a mutating request sent with an Idempotency-Key
+// header, so a client retrying it gets the original response instead of a
+// second scan. Keys are scoped to the user who sent them.
+type IdempotencyKey struct {
+	BaseWithoutSoftDelete
+	UserID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_keys_user_key,priority:1" json:"user_id"`
+	Key    string    `gorm:"size:255;not null;uniqueIndex:idx_idempotency_keys_user_key,priority:2" json:"key"`
+	Method string    `gorm:"size:10;not null" json:"method"`
+	Path   string    `gorm:"type:text;not null" json:"path"`
+	// Fingerprint is a hash of the method, path and payload, used to reject
+	// a key reused for a different request
+	Fingerprint string            `gorm:"size:64;not null" json:"fingerprint"`
+	Status      IdempotencyStatus `gorm:"type:varchar(20);not null;default:processing" json:"status"`
+
+	// The stored response, set once the request completes
+	StatusCode   *int   `json:"status_code,omitempty"`
+	ContentType  string `gorm:"size:100" json:"content_type,omitempty"`
+	ResponseBody []byte `gorm:"type:bytea" json:"-"`
+
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+// Expired reports whether the key may be used for a new request
+func (k *IdempotencyKey) Expired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
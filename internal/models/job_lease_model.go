@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// JobLease lets one worker process run a scheduled job until the lease
+// expires or is released, so the job does not run on every instance
+type JobLease struct {
+	Name      string    `gorm:"primary_key;size:100" json:"name"`
+	Holder    string    `gorm:"size:64;not null" json:"holder"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+}
+
+func (JobLease) TableName() string {
+	return "job_leases"
+}
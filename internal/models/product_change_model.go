@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// NutrientChange is the old and new value of one nutrient per 100g. A nil
+// value means the nutrient was missing.
+type NutrientChange struct {
+	Old *float64 `json:"old"`
+	New *float64 `json:"new"`
+}
+
+// ProductChange records what a refresh from Open Food Facts changed in a
+// cached product's nutrients or grade
+type ProductChange struct {
+	BaseWithoutSoftDelete
+	ProductID uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
+	// NutriScoreBefore and NutriScoreAfter are set when the grade changed
+	NutriScoreBefore *string `gorm:"size:1" json:"nutri_score_before,omitempty"`
+	NutriScoreAfter  *string `gorm:"size:1" json:"nutri_score_after,omitempty"`
+	// NutrientsJSON maps each changed nutrient key to a NutrientChange
+	NutrientsJSON JSON `gorm:"type:jsonb" json:"nutrients,omitempty"`
+
+	// Relations
+	Product *Product `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (ProductChange) TableName() string {
+	return "product_changes"
+}
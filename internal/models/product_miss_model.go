@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductMiss remembers a barcode Open Food Facts did not know, so lookups
+// answer not found without asking OFF again until the entry expires
+type ProductMiss struct {
+	Barcode   string    `gorm:"primary_key;size:50" json:"barcode"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func (ProductMiss) TableName() string {
+	return "product_misses"
+}
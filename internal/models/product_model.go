@@ -2,6 +2,9 @@ package models
 
 import (
 	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
 )
 
 type ProductSource string
@@ -31,6 +34,18 @@ type Nutrients struct {
 	PotassiumMg   *float64 `json:"potassium_mg,omitempty"`
 }
 
+// NutrientKeys returns the JSON keys of Nutrients, which are also the
+// keys stored in a product's NutrientsJSON
+func NutrientKeys() []string {
+	t := reflect.TypeOf(Nutrients{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		keys = append(keys, name)
+	}
+	return keys
+}
+
 type Product struct {
 	BaseWithoutSoftDelete
 	Barcode              string        `gorm:"uniqueIndex;size:50" json:"barcode"`
@@ -40,11 +55,18 @@ type Product struct {
 	Source               ProductSource `gorm:"type:varchar(50);default:manual" json:"source"`
 	NutrientsJSON        JSON          `gorm:"type:jsonb" json:"nutrients"`
 	ServingSize          *string       `gorm:"size:100" json:"serving_size,omitempty"`
+	Ingredients          *string       `gorm:"type:text" json:"ingredients,omitempty"`
 	ServingNutrientsJSON JSON          `gorm:"type:jsonb" json:"serving_nutrients,omitempty"`
 	NutriScore           *string       `gorm:"size:1" json:"nutri_score,omitempty"`
 	NutriScoreValue      *int          `json:"nutri_score_value,omitempty"`
 	HighlightsJSON       JSON          `gorm:"type:jsonb" json:"highlights,omitempty"`
 	InsightsJSON         JSON          `gorm:"type:jsonb" json:"insights,omitempty"`
+	// FetchedAt is when an Open Food Facts product was last fetched or
+	// confirmed unchanged, nil for other sources and never-refreshed rows
+	FetchedAt *time.Time `gorm:"index" json:"fetched_at,omitempty"`
+	// ETag is the Open Food Facts version of the product, sent back to
+	// make refreshes conditional
+	ETag *string `gorm:"column:etag;size:255" json:"-"`
 
 	// Relations
 	Scans []Scan `gorm:"foreignKey:ProductID" json:"scans,omitempty"`
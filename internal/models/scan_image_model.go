@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+type ScanImageKind string
+
+const (
+	ScanImageFront       ScanImageKind = "front"
+	ScanImageNutrition   ScanImageKind = "nutrition"
+	ScanImageIngredients ScanImageKind = "ingredients"
+)
+
+// ScanImageKinds lists the accepted image kinds in processing order
+func ScanImageKinds() []ScanImageKind {
+	return []ScanImageKind{ScanImageNutrition, ScanImageFront, ScanImageIngredients}
+}
+
+// IsValidScanImageKind checks if the kind is one of the accepted kinds
+func IsValidScanImageKind(kind string) bool {
+	for _, k := range ScanImageKinds() {
+		if string(k) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+type ScanImage struct {
+	BaseWithoutSoftDelete
+	ScanID      uuid.UUID     `gorm:"type:uuid;not null;index" json:"scan_id"`
+	Kind        ScanImageKind `gorm:"type:varchar(20);not null" json:"kind"`
+	ImageRef    string        `gorm:"size:500;not null" json:"image_ref"`
+	ContentType string        `gorm:"size:50" json:"content_type"`
+	SizeBytes   int64         `json:"size_bytes"`
+	// HasVariants is set once thumbnail and medium renditions are available
+	HasVariants bool    `gorm:"not null;default:false" json:"has_variants"`
+	QualityJSON JSON    `gorm:"type:jsonb" json:"quality,omitempty"`
+	OCRRaw      *string `gorm:"type:text" json:"ocr_raw,omitempty"`
+	OCRStrategy *string `gorm:"size:100" json:"ocr_strategy,omitempty"`
+}
+
+func (ScanImage) TableName() string {
+	return "scan_images"
+}
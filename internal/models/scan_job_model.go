@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ScanJobStatus string
+
+const (
+	ScanJobStatusQueued     ScanJobStatus = "queued"
+	ScanJobStatusProcessing ScanJobStatus = "processing"
+	ScanJobStatusDone       ScanJobStatus = "done"
+	ScanJobStatusFailed     ScanJobStatus = "failed"
+	// ScanJobStatusDeadLetter holds jobs that kept failing with retryable
+	// errors until they ran out of attempts, waiting for an admin to requeue
+	ScanJobStatusDeadLetter ScanJobStatus = "dead_letter"
+)
+
+// ScanPriority is the class of a scan job. Lower values are claimed first.
+type ScanPriority int
+
+const (
+	// ScanPriorityInteractive is for uploads a user is waiting on
+	ScanPriorityInteractive ScanPriority = 0
+	// ScanPriorityReprocess is for scans read again on request
+	ScanPriorityReprocess ScanPriority = 10
+	// ScanPriorityBatch is for bulk imports and backfills
+	ScanPriorityBatch ScanPriority = 20
+)
+
+func (p ScanPriority) String() string {
+	switch p {
+	case ScanPriorityInteractive:
+		return "interactive"
+	case ScanPriorityReprocess:
+		return "reprocess"
+	case ScanPriorityBatch:
+		return "batch"
+	default:
+		return "custom"
+	}
+}
+
+// ScanJob is a durable unit of OCR work for a scan. Workers claim jobs with
+// SELECT ... FOR UPDATE SKIP LOCKED and hold them until LockedUntil, after
+// which another worker may take the job over.
+//
+// Jobs are claimed by priority, then by FairSeq, a per-user sequence that
+// interleaves users round-robin so one bulk upload cannot starve others.
+type ScanJob struct {
+	BaseWithoutSoftDelete
+	// Only one queued or processing job may exist per scan
+	ScanID      uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_scan_jobs_active,where:status = 'queued' OR status = 'processing'" json:"scan_id"`
+	UserID      *uuid.UUID    `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Status      ScanJobStatus `gorm:"type:varchar(20);not null;default:queued;index:idx_scan_jobs_claim,priority:1" json:"status"`
+	Priority    ScanPriority  `gorm:"not null;default:0;index:idx_scan_jobs_claim,priority:2" json:"priority"`
+	FairSeq     int64         `gorm:"not null;default:0;index:idx_scan_jobs_claim,priority:3" json:"fair_seq"`
+	RunAt       time.Time     `gorm:"not null;index:idx_scan_jobs_claim,priority:4" json:"run_at"`
+	Attempts    int           `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int           `gorm:"not null;default:3" json:"max_attempts"`
+	LockedBy    *string       `gorm:"size:100" json:"locked_by,omitempty"`
+	LockedUntil *time.Time    `json:"locked_until,omitempty"`
+	LastError   *string       `gorm:"type:text" json:"last_error,omitempty"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+
+	// Relations
+	Scan *Scan `gorm:"foreignKey:ScanID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (ScanJob) TableName() string {
+	return "scan_jobs"
+}
+
+// Exhausted reports whether the job has used all of its attempts
+func (j *ScanJob) Exhausted() bool {
+	return j.Attempts >= j.MaxAttempts
+}
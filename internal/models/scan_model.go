@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -23,19 +25,27 @@ type Scan struct {
 	Status           ScanStatus `gorm:"type:varchar(20);default:pending;index" json:"status"`
 	OCRRaw           *string    `gorm:"type:text" json:"ocr_raw,omitempty"`
 	OCRConfidence    *float64   `json:"ocr_confidence,omitempty"`
+	OCRStrategy      *string    `gorm:"size:100" json:"ocr_strategy,omitempty"`
+	ParserVersion    *string    `gorm:"size:20;index" json:"parser_version,omitempty"`
 	ParsedJSON       JSON       `gorm:"type:jsonb" json:"parsed,omitempty"`
 	NormalizedJSON   JSON       `gorm:"type:jsonb" json:"normalized,omitempty"`
 	NutriScore       *string    `gorm:"size:1" json:"nutri_score,omitempty"`
 	NutriScoreValue  *int       `json:"nutri_score_value,omitempty"`
 	HighlightsJSON   JSON       `gorm:"type:jsonb" json:"highlights,omitempty"`
 	InsightsJSON     JSON       `gorm:"type:jsonb" json:"insights,omitempty"`
+	QualityJSON      JSON       `gorm:"type:jsonb" json:"quality,omitempty"`
 	ProcessingTimeMs *int       `json:"processing_time_ms,omitempty"`
+	TraceJSON        JSON       `gorm:"type:jsonb" json:"trace,omitempty"`
+	ErrorCode        *string    `gorm:"size:50" json:"error_code,omitempty"`
 	ErrorMessage     *string    `gorm:"type:text" json:"error_message,omitempty"`
+	// ImageMissingAt is set by storage reconciliation when a stored image is gone
+	ImageMissingAt *time.Time `gorm:"index" json:"image_missing_at,omitempty"`
 
 	// Relations
 	User        *User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Product     *Product     `gorm:"foreignKey:ProductID" json:"product,omitempty"`
 	Corrections []Correction `gorm:"foreignKey:ScanID" json:"corrections,omitempty"`
+	Images      []ScanImage  `gorm:"foreignKey:ScanID" json:"images,omitempty"`
 }
 
 func (Scan) TableName() string {
@@ -54,6 +64,24 @@ func (s *Scan) IsProcessing() bool {
 	return s.Status == ScanStatusProcessing
 }
 
+// HasImages reports whether the scan has anything for OCR to read
+func (s *Scan) HasImages() bool {
+	return len(s.Images) > 0 || (s.ImageStored && s.ImageRef != nil)
+}
+
+// StoredUsage returns the bytes and number of images the scan counts
+// towards its owner's storage usage
+func (s *Scan) StoredUsage() (int64, int) {
+	if !s.ImageStored {
+		return 0, 0
+	}
+	var bytes int64
+	for _, image := range s.Images {
+		bytes += image.SizeBytes
+	}
+	return bytes, len(s.Images)
+}
+
 type NutrientHighlight struct {
 	Nutrient string  `json:"nutrient"`
 	Level    string  `json:"level"`
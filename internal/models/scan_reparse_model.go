@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReparseStatus string
+
+const (
+	ReparseQueued    ReparseStatus = "queued"
+	ReparseRunning   ReparseStatus = "running"
+	ReparseCompleted ReparseStatus = "completed"
+	ReparseFailed    ReparseStatus = "failed"
+)
+
+// ScanReparse is a bulk re-parse of historical scans, run in the background
+// by a worker. It reads the matching scans oldest first and saves its
+// cursor and counts after every page, so a run whose worker died is picked
+// up where it stopped.
+type ScanReparse struct {
+	BaseWithoutSoftDelete
+	Status        ReparseStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	DryRun        bool          `gorm:"not null" json:"dry_run"`
+	ParserVersion string        `gorm:"size:20;not null" json:"parser_version"`
+	FilterJSON    JSON          `gorm:"column:filter;type:jsonb;not null" json:"filter"`
+	// Limit caps how many scans the run reads, zero for every match
+	Limit       int        `gorm:"not null;default:0" json:"limit"`
+	RequestedBy *uuid.UUID `gorm:"type:uuid" json:"requested_by,omitempty"`
+
+	// CursorCreatedAt and CursorID are the last scan read
+	CursorCreatedAt *time.Time `json:"-"`
+	CursorID        *uuid.UUID `gorm:"type:uuid" json:"-"`
+	Processed       int        `json:"processed"`
+	Changed         int        `json:"changed"`
+	Unchanged       int        `json:"unchanged"`
+	Failed          int        `json:"failed"`
+	// ReportJSON lists changed scans and errors, capped in size
+	ReportJSON JSON    `gorm:"column:report;type:jsonb" json:"report,omitempty"`
+	Error      *string `gorm:"type:text" json:"error,omitempty"`
+
+	// Claims counts the times a worker took the run, so a worker whose
+	// lock expired cannot save over the one that took it over
+	Claims      int        `gorm:"not null;default:0" json:"-"`
+	LockedUntil *time.Time `json:"-"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+func (ScanReparse) TableName() string {
+	return "scan_reparses"
+}
+
+// IsFinished reports whether the run has stopped for good
+func (r *ScanReparse) IsFinished() bool {
+	return r.Status == ReparseCompleted || r.Status == ReparseFailed
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScanUpload is an image a client was handed a direct upload target for.
+// Its ID is reserved for the scan created when the upload is committed.
+type ScanUpload struct {
+	BaseWithoutSoftDelete
+	UserID      uuid.UUID     `gorm:"type:uuid;not null;index" json:"user_id"`
+	Kind        ScanImageKind `gorm:"type:varchar(20);not null" json:"kind"`
+	ObjectKey   string        `gorm:"size:500;not null" json:"object_key"`
+	ContentType string        `gorm:"size:50;not null" json:"content_type"`
+	SizeBytes   int64         `gorm:"not null" json:"size_bytes"`
+	// ChecksumSHA256 is the hex digest the client declared for the file
+	ChecksumSHA256 string     `gorm:"size:64;not null" json:"checksum_sha256"`
+	Barcode        *string    `gorm:"size:50" json:"barcode,omitempty"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	CommittedAt    *time.Time `json:"committed_at,omitempty"`
+}
+
+func (ScanUpload) TableName() string {
+	return "scan_uploads"
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+type ReconciliationStatus string
+
+const (
+	ReconciliationRunning   ReconciliationStatus = "running"
+	ReconciliationCompleted ReconciliationStatus = "completed"
+	ReconciliationFailed    ReconciliationStatus = "failed"
+)
+
+// StorageReconciliation is the report of one comparison of stored scan
+// images with the scans referencing them
+type StorageReconciliation struct {
+	BaseWithoutSoftDelete
+	Status     ReconciliationStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	StartedAt  time.Time            `gorm:"not null;index" json:"started_at"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+	// ObjectsListed counts the objects found under the scans prefix
+	ObjectsListed int `json:"objects_listed"`
+	// OrphansDeleted counts objects no scan referenced, older than the grace period
+	OrphansDeleted int `json:"orphans_deleted"`
+	// OrphansPending counts unreferenced objects still inside the grace period
+	OrphansPending int `json:"orphans_pending"`
+	ScansChecked   int `json:"scans_checked"`
+	// ScansMissing counts scans flagged because a stored image is gone
+	ScansMissing int `json:"scans_missing"`
+	// ScansRestored counts flagged scans whose images were found again
+	ScansRestored int `json:"scans_restored"`
+	// VariantsRestored counts images whose missing variants were rendered again
+	VariantsRestored int     `json:"variants_restored"`
+	Errors           int     `json:"errors"`
+	Error            *string `gorm:"type:text" json:"error,omitempty"`
+	// DetailsJSON lists deleted refs, flagged scans and errors, capped in size
+	DetailsJSON JSON `gorm:"type:jsonb" json:"details,omitempty"`
+}
+
+func (StorageReconciliation) TableName() string {
+	return "storage_reconciliations"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StorageUsage counts the scan images a user has stored. Variants are not
+// counted, only the images users uploaded.
+type StorageUsage struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primary_key" json:"user_id"`
+	Bytes     int64     `gorm:"not null;default:0;index" json:"bytes"`
+	Objects   int       `gorm:"not null;default:0" json:"objects"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relations
+	User *User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (StorageUsage) TableName() string {
+	return "storage_usages"
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookEventScanCompleted     WebhookEventType = "scan.completed"
+	WebhookEventScanFailed        WebhookEventType = "scan.failed"
+	WebhookEventCorrectionCreated WebhookEventType = "correction.created"
+	WebhookEventProductUpdated    WebhookEventType = "product.updated"
+)
+
+// WebhookEventTypes lists every event a webhook can subscribe to
+func WebhookEventTypes() []WebhookEventType {
+	return []WebhookEventType{
+		WebhookEventScanCompleted,
+		WebhookEventScanFailed,
+		WebhookEventCorrectionCreated,
+		WebhookEventProductUpdated,
+	}
+}
+
+// WebhookSubscription is a URL that receives signed event deliveries. It
+// is managed by its user, or by one of the user's API clients when
+// ClientID is set, and receives the user's events either way.
+// Scan and correction events, and product.updated for products read from a
+// scan, go to the owner's webhooks only. Open Food Facts products are
+// shared, so their product.updated goes to every webhook subscribed to it.
+type WebhookSubscription struct {
+	BaseWithoutSoftDelete
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	ClientID    *uuid.UUID `gorm:"type:uuid;index" json:"client_id,omitempty"`
+	URL         string     `gorm:"type:text;not null" json:"url"`
+	Description *string    `gorm:"size:255" json:"description,omitempty"`
+	EventsJSON  JSON       `gorm:"column:events;type:jsonb;not null" json:"events"`
+	Secret      string     `gorm:"size:100;not null" json:"-"`
+	Active      bool       `gorm:"default:true;index" json:"active"`
+
+	// ConsecutiveFailures counts failed attempts since the last success.
+	// The webhook is disabled once it reaches the configured limit.
+	ConsecutiveFailures int        `gorm:"default:0" json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      *string    `gorm:"type:text" json:"disabled_reason,omitempty"`
+	LastDeliveryAt      *time.Time `json:"last_delivery_at,omitempty"`
+
+	// Relations
+	User   User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Client *APIClient `gorm:"foreignKey:ClientID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// Events returns the event types the webhook is subscribed to
+func (w *WebhookSubscription) Events() []WebhookEventType {
+	var events []WebhookEventType
+	_ = json.Unmarshal(w.EventsJSON, &events)
+	return events
+}
+
+// Subscribes reports whether the webhook wants the event type
+func (w *WebhookSubscription) Subscribes(event WebhookEventType) bool {
+	return slices.Contains(w.Events(), event)
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending    WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivering WebhookDeliveryStatus = "delivering"
+	WebhookDeliverySucceeded  WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed     WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one event sent to one webhook, kept as a delivery log.
+// A redelivery is a new row with the same EventID, so receivers can tell
+// it apart from a new event.
+type WebhookDelivery struct {
+	BaseWithoutSoftDelete
+	SubscriptionID uuid.UUID             `gorm:"type:uuid;not null;index" json:"subscription_id"`
+	EventID        uuid.UUID             `gorm:"type:uuid;not null;index" json:"event_id"`
+	EventType      WebhookEventType      `gorm:"size:50;not null" json:"event_type"`
+	Payload        JSON                  `gorm:"type:jsonb;not null" json:"payload"`
+	Status         WebhookDeliveryStatus `gorm:"type:varchar(20);not null;default:pending;index:idx_webhook_deliveries_due,priority:1" json:"status"`
+	NextAttemptAt  time.Time             `gorm:"not null;index:idx_webhook_deliveries_due,priority:2" json:"next_attempt_at"`
+	Attempts       int                   `gorm:"default:0" json:"attempts"`
+	MaxAttempts    int                   `gorm:"not null" json:"max_attempts"`
+	LockedUntil    *time.Time            `json:"-"`
+	LastStatusCode *int                  `json:"last_status_code,omitempty"`
+	LastError      *string               `gorm:"type:text" json:"last_error,omitempty"`
+	ResponseBody   *string               `gorm:"type:text" json:"response_body,omitempty"`
+	DurationMs     *int                  `json:"duration_ms,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+
+	// Relations
+	Subscription *WebhookSubscription `gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// Exhausted reports whether no attempts are left
+func (d *WebhookDelivery) Exhausted() bool {
+	return d.Attempts >= d.MaxAttempts
+}
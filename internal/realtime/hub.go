@@ -0,0 +1,197 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// subscriberBuffer is how many events a slow client may fall behind
+	// before further events are dropped for it
+	subscriberBuffer = 16
+	// maxReconnectDelay caps the backoff after the listen connection drops
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Subscription filters the events a client receives. Empty fields match
+// any value.
+type Subscription struct {
+	ScanID string
+	UserID string
+}
+
+func (s Subscription) matches(event dto.ScanEvent) bool {
+	return (s.ScanID == "" || s.ScanID == event.ScanID) &&
+		(s.UserID == "" || s.UserID == event.UserID)
+}
+
+type subscriber struct {
+	filter Subscription
+	events chan dto.ScanEvent
+}
+
+// Hub listens for scan events on a dedicated Postgres connection and fans
+// them out to the clients of this instance. Events published while the
+// connection is down are lost; clients resync from the scan itself.
+type Hub struct {
+	dsn string
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	closed      bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewHub(dsn string) *Hub {
+	return &Hub{
+		dsn:         dsn,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Start begins listening in the background, reconnecting when the
+// connection drops
+func (h *Hub) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	h.wg.Add(1)
+	go h.run(ctx)
+}
+
+// Shutdown stops listening and closes every subscription, which ends the
+// streams of connected clients so the HTTP server can drain
+func (h *Hub) Shutdown(ctx context.Context) error {
+	if h.cancel == nil {
+		return nil
+	}
+	h.cancel()
+
+	h.mu.Lock()
+	h.closed = true
+	for sub := range h.subscribers {
+		close(sub.events)
+		delete(h.subscribers, sub)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("scan event hub stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe returns a channel of events matching the filter and the func
+// that ends the subscription. The channel is closed when the hub shuts down.
+func (h *Hub) Subscribe(filter Subscription) (<-chan dto.ScanEvent, func()) {
+	sub := &subscriber{
+		filter: filter,
+		events: make(chan dto.ScanEvent, subscriberBuffer),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		close(sub.events)
+	} else {
+		h.subscribers[sub] = struct{}{}
+	}
+	h.mu.Unlock()
+	subscribersActive.Inc()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			if _, ok := h.subscribers[sub]; ok {
+				delete(h.subscribers, sub)
+				close(sub.events)
+			}
+			h.mu.Unlock()
+			subscribersActive.Dec()
+		})
+	}
+
+	return sub.events, unsubscribe
+}
+
+func (h *Hub) broadcast(event dto.ScanEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		if !sub.filter.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			eventsDropped.Inc()
+		}
+	}
+}
+
+func (h *Hub) run(ctx context.Context) {
+	defer h.wg.Done()
+
+	delay := time.Second
+	for {
+		err := h.listen(ctx, func() { delay = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.Warn("scan event listener disconnected", "error", err, "retry_in", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// listen holds one LISTEN connection until it fails or ctx is cancelled
+func (h *Hub) listen(ctx context.Context, onReady func()) error {
+	conn, err := pgx.Connect(ctx, h.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{ScanEventsChannel}.Sanitize()); err != nil {
+		return err
+	}
+	onReady()
+	logger.Info("listening for scan events", "channel", ScanEventsChannel)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event dto.ScanEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			logger.Warn("ignoring malformed scan event", "error", err)
+			continue
+		}
+		h.broadcast(event)
+	}
+}
@@ -0,0 +1,18 @@
+package realtime
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	subscribersActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "nutrisnap_scan_event_subscribers",
+		Help: "Number of clients streaming scan events from this instance",
+	})
+
+	eventsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nutrisnap_scan_events_dropped_total",
+		Help: "Scan events dropped because a client fell too far behind",
+	})
+)
@@ -0,0 +1,48 @@
+package realtime
+
+import (
+	"encoding/json"
+
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// ScanEventsChannel is the Postgres NOTIFY channel scan events are sent on
+const ScanEventsChannel = "scan_events"
+
+// maxMessageLength keeps payloads well under the 8000 byte NOTIFY limit
+const maxMessageLength = 500
+
+// Publisher sends scan events to every API instance through Postgres
+// NOTIFY, so a client gets them whichever instance it is connected to
+type Publisher struct {
+	db *gorm.DB
+}
+
+func NewPublisher(db *gorm.DB) *Publisher {
+	return &Publisher{db: db}
+}
+
+// PublishScanEvent sends the event. Events are best effort: a failure is
+// logged and never fails the scan, since clients can still poll for status.
+func (p *Publisher) PublishScanEvent(event dto.ScanEvent) {
+	if event.ErrorMessage != nil && len(*event.ErrorMessage) > maxMessageLength {
+		message := (*event.ErrorMessage)[:maxMessageLength]
+		event.ErrorMessage = &message
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("failed to encode scan event", "scan_id", event.ScanID, "error", err)
+		return
+	}
+
+	if err := p.db.Exec("SELECT pg_notify(?, ?)", ScanEventsChannel, string(payload)).Error; err != nil {
+		logger.Warn("failed to publish scan event",
+			"scan_id", event.ScanID,
+			"type", event.Type,
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrAPIClientNotFound = errors.New("api client not found")
+
+type APIClientRepository interface {
+	Create(client *models.APIClient) error
+	FindByID(id string) (*models.APIClient, error)
+	FindByUserID(userID string) ([]models.APIClient, error)
+	FindByKeyHash(hash string) (*models.APIClient, error)
+	TouchLastUsed(id uuid.UUID, at time.Time) error
+	// Delete removes a client together with its webhooks
+	Delete(id string) error
+}
+
+type apiClientRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIClientRepository(db *gorm.DB) APIClientRepository {
+	return &apiClientRepository{db: db}
+}
+
+func (r *apiClientRepository) Create(client *models.APIClient) error {
+	return r.db.Create(client).Error
+}
+
+func (r *apiClientRepository) FindByID(id string) (*models.APIClient, error) {
+	var client models.APIClient
+	if err := r.db.First(&client, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIClientNotFound
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *apiClientRepository) FindByUserID(userID string) ([]models.APIClient, error) {
+	var clients []models.APIClient
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&clients).Error
+	return clients, err
+}
+
+func (r *apiClientRepository) FindByKeyHash(hash string) (*models.APIClient, error) {
+	var client models.APIClient
+	if err := r.db.First(&client, "key_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIClientNotFound
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *apiClientRepository) TouchLastUsed(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.APIClient{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+func (r *apiClientRepository) Delete(id string) error {
+	result := r.db.Delete(&models.APIClient{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIClientNotFound
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	Acquire(key *models.IdempotencyKey, staleAfter time.Duration) (*models.IdempotencyKey, error)
+	Complete(key *models.IdempotencyKey) error
+	Release(key *models.IdempotencyKey) error
+	DeleteExpired(limit int) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Acquire claims a key for a new request. When the user already holds the
+// key it returns the existing record instead and claims nothing, unless
+// that record has expired or is a request still processing after
+// staleAfter, whose process must have died. Those are replaced.
+func (r *idempotencyRepository) Acquire(key *models.IdempotencyKey, staleAfter time.Duration) (*models.IdempotencyKey, error) {
+	var existing *models.IdempotencyKey
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if result.Error != nil || result.RowsAffected == 1 {
+			return result.Error
+		}
+
+		var found models.IdempotencyKey
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND key = ?", key.UserID, key.Key).
+			Take(&found).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		stale := found.Status == models.IdempotencyStatusProcessing && now.Sub(found.CreatedAt) > staleAfter
+		if !found.Expired(now) && !stale {
+			existing = &found
+			return nil
+		}
+
+		if err := tx.Delete(&found).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+	return existing, err
+}
+
+// Complete stores the response of the request that holds the key
+func (r *idempotencyRepository) Complete(key *models.IdempotencyKey) error {
+	key.Status = models.IdempotencyStatusCompleted
+	return r.db.Model(key).Updates(map[string]interface{}{
+		"status":        key.Status,
+		"status_code":   key.StatusCode,
+		"content_type":  key.ContentType,
+		"response_body": key.ResponseBody,
+	}).Error
+}
+
+// Release frees a key whose request failed, so the client can retry it
+func (r *idempotencyRepository) Release(key *models.IdempotencyKey) error {
+	return r.db.Delete(key).Error
+}
+
+// DeleteExpired removes up to limit expired keys and returns how many
+func (r *idempotencyRepository) DeleteExpired(limit int) (int64, error) {
+	result := r.db.Exec(
+		"DELETE FROM idempotency_keys WHERE id IN (SELECT id FROM idempotency_keys WHERE expires_at <= ? LIMIT ?)",
+		time.Now(), limit,
+	)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+)
+
+type JobLeaseRepository interface {
+	// TryAcquire takes the named lease for ttl unless another holder has it
+	// and it has not expired
+	TryAcquire(name, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the named lease if holder still has it
+	Release(name, holder string) error
+}
+
+type jobLeaseRepository struct {
+	db *gorm.DB
+}
+
+func NewJobLeaseRepository(db *gorm.DB) JobLeaseRepository {
+	return &jobLeaseRepository{db: db}
+}
+
+func (r *jobLeaseRepository) TryAcquire(name, holder string, ttl time.Duration) (bool, error) {
+	result := r.db.Exec(`
+		INSERT INTO job_leases (name, holder, expires_at)
+		VALUES (?, ?, NOW() + make_interval(secs => ?))
+		ON CONFLICT (name) DO UPDATE
+		SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE job_leases.expires_at <= NOW()`,
+		name, holder, ttl.Seconds())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *jobLeaseRepository) Release(name, holder string) error {
+	return r.db.Where("name = ? AND holder = ?", name, holder).Delete(&models.JobLease{}).Error
+}
@@ -2,7 +2,9 @@ package repositories
 
 import (
 	"errors"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"gorm.io/gorm"
 )
@@ -16,6 +18,26 @@ type ProductRepository interface {
 	FindByBarcode(barcode string) (*models.Product, error)
 	FindByID(id string) (*models.Product, error)
 	Update(product *models.Product) error
+	// Search returns one page of matching products and the cursor of the
+	// next page, which is nil on the last page
+	Search(search ProductSearch) ([]models.Product, *ProductCursor, error)
+	// FindRefreshCandidates returns Open Food Facts products last fetched
+	// before staleBefore, most scanned first
+	FindRefreshCandidates(staleBefore time.Time, limit int) ([]models.Product, error)
+	// MarkFetched records a refresh that found the product unchanged
+	MarkFetched(id uuid.UUID, fetchedAt time.Time, etag *string) error
+	// UpdateWithChange saves the refreshed columns of a product together
+	// with the record of what changed, if anything did. Other columns keep
+	// what is stored, so a stale copy of the product does not overwrite them.
+	UpdateWithChange(product *models.Product, change *models.ProductChange) error
+	// IsMissing reports whether Open Food Facts recently did not know the
+	// barcode and the entry has not expired
+	IsMissing(barcode string) (bool, error)
+	// RecordMiss remembers that Open Food Facts does not know the barcode
+	// for ttl
+	RecordMiss(barcode string, ttl time.Duration) error
+	// DeleteExpiredMisses removes up to limit expired misses and returns how many
+	DeleteExpiredMisses(limit int) (int64, error)
 }
 
 type productRepository struct {
@@ -26,8 +48,15 @@ func NewProductRepository(db *gorm.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
+// Create saves a new product and forgets any miss recorded for its
+// barcode, so the product is found at once
 func (r *productRepository) Create(product *models.Product) error {
-	return r.db.Create(product).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		return forgetMiss(tx, product.Barcode)
+	})
 }
 
 func (r *productRepository) FindByBarcode(barcode string) (*models.Product, error) {
@@ -55,5 +84,76 @@ func (r *productRepository) FindByID(id string) (*models.Product, error) {
 }
 
 func (r *productRepository) Update(product *models.Product) error {
-	return r.db.Save(product).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(product).Error; err != nil {
+			return err
+		}
+		return forgetMiss(tx, product.Barcode)
+	})
+}
+
+func (r *productRepository) FindRefreshCandidates(staleBefore time.Time, limit int) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.Select("products.*").
+		Joins("LEFT JOIN scans ON scans.product_id = products.id AND scans.deleted_at IS NULL").
+		Where("products.source = ?", models.SourceOpenFoodFacts).
+		Where("products.fetched_at IS NULL OR products.fetched_at < ?", staleBefore).
+		Group("products.id").
+		Order("COUNT(scans.id) DESC, products.fetched_at ASC NULLS FIRST").
+		Limit(limit).
+		Find(&products).Error
+	return products, err
+}
+
+func (r *productRepository) MarkFetched(id uuid.UUID, fetchedAt time.Time, etag *string) error {
+	return r.db.Model(&models.Product{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"fetched_at": fetchedAt, "etag": etag}).Error
+}
+
+// refreshedColumns are the product columns a refresh from Open Food Facts
+// writes
+var refreshedColumns = []string{
+	"name", "brand", "image_url", "serving_size", "nutrients_json",
+	"nutri_score", "nutri_score_value", "fetched_at", "etag",
+}
+
+func (r *productRepository) UpdateWithChange(product *models.Product, change *models.ProductChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(product).Select(refreshedColumns).Updates(product).Error; err != nil {
+			return err
+		}
+		if change == nil {
+			return nil
+		}
+		return tx.Create(change).Error
+	})
+}
+
+func (r *productRepository) IsMissing(barcode string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.ProductMiss{}).
+		Where("barcode = ? AND expires_at > ?", barcode, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *productRepository) RecordMiss(barcode string, ttl time.Duration) error {
+	return r.db.Exec(`
+		INSERT INTO product_misses (barcode, created_at, expires_at)
+		VALUES (?, NOW(), ?)
+		ON CONFLICT (barcode) DO UPDATE SET expires_at = EXCLUDED.expires_at`,
+		barcode, time.Now().Add(ttl)).Error
+}
+
+func (r *productRepository) DeleteExpiredMisses(limit int) (int64, error) {
+	result := r.db.Exec(
+		"DELETE FROM product_misses WHERE barcode IN (SELECT barcode FROM product_misses WHERE expires_at <= ? LIMIT ?)",
+		time.Now(), limit,
+	)
+	return result.RowsAffected, result.Error
+}
+
+// forgetMiss drops the miss of a barcode that now has a product
+func forgetMiss(tx *gorm.DB, barcode string) error {
+	return tx.Where("barcode = ?", barcode).Delete(&models.ProductMiss{}).Error
 }
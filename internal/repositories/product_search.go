@@ -0,0 +1,191 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+// ProductSearchVector is the text search document of a product. Queries
+// must use it verbatim to be served by its index.
+const ProductSearchVector = "to_tsvector('simple', name || ' ' || coalesce(brand, ''))"
+
+// IndexedNutrients are the nutrients with an index for range filters
+var IndexedNutrients = []string{"energy_kcal", "sugar_g", "fat_g", "saturated_fat_g", "sodium_mg", "protein_g"}
+
+// NutrientExpr returns the SQL reading one nutrient from a product's
+// NutrientsJSON. The key must be one of models.NutrientKeys.
+func NutrientExpr(key string) string {
+	return fmt.Sprintf("((nutrients_json->>'%s')::numeric)", key)
+}
+
+// ProductSearchIndexes creates the indexes product search relies on
+var ProductSearchIndexes = func() []string {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_products_search ON products USING GIN ((" + ProductSearchVector + "))",
+		"CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_products_brand_trgm ON products USING GIN (brand gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_products_name_lower ON products (lower(name), id)",
+		"CREATE INDEX IF NOT EXISTS idx_products_nutri_score ON products ((upper(nutri_score)))",
+		"CREATE INDEX IF NOT EXISTS idx_products_source ON products (source)",
+		"CREATE INDEX IF NOT EXISTS idx_products_created_at ON products (created_at DESC, id)",
+	}
+	for _, key := range IndexedNutrients {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_products_nutrient_%s ON products (%s)", key, NutrientExpr(key)))
+	}
+	return statements
+}()
+
+// ProductSort orders product search results
+type ProductSort string
+
+const (
+	ProductSortRelevance  ProductSort = "relevance"
+	ProductSortName       ProductSort = "name"
+	ProductSortNutriScore ProductSort = "nutri_score"
+	ProductSortNewest     ProductSort = "newest"
+)
+
+// NutrientRange bounds one nutrient per 100g. Products without the
+// nutrient do not match.
+type NutrientRange struct {
+	Key string
+	Min *float64
+	Max *float64
+}
+
+// SearchableSources are the sources of shared products. OCR products are
+// read from one user's private photos and never show up in a search.
+var SearchableSources = []models.ProductSource{models.SourceOpenFoodFacts, models.SourceManual}
+
+// ProductSearch selects and orders products
+type ProductSearch struct {
+	// Query matches name and brand by words and by similarity
+	Query       string
+	NutriScores []string
+	// Sources narrows the search further within SearchableSources
+	Sources   []models.ProductSource
+	Nutrients []NutrientRange
+	Sort      ProductSort
+	// After continues after the last product of a previous page
+	After *ProductCursor
+	Limit int
+}
+
+// ProductCursor marks the last product of a page by its sort key
+type ProductCursor struct {
+	Key string
+	ID  uuid.UUID
+}
+
+// productSortKey is how a sort orders products
+type productSortKey struct {
+	expr clause.Expr
+	desc bool
+	// sqlType is what the key is cast back to when compared with a cursor
+	sqlType string
+}
+
+// productRow is a product with the sort key the cursor is built from
+type productRow struct {
+	models.Product
+	SortKey string
+}
+
+func (r *productRepository) Search(search ProductSearch) ([]models.Product, *ProductCursor, error) {
+	query := r.db.Model(&models.Product{}).Where("source IN ?", SearchableSources)
+
+	if search.Query != "" {
+		query = query.Where("("+ProductSearchVector+" @@ plainto_tsquery('simple', ?) OR ? <% name OR ? <% brand)",
+			search.Query, search.Query, search.Query)
+	}
+	if len(search.NutriScores) > 0 {
+		grades := make([]string, len(search.NutriScores))
+		for i, grade := range search.NutriScores {
+			grades[i] = strings.ToUpper(grade)
+		}
+		query = query.Where("upper(nutri_score) IN ?", grades)
+	}
+	if len(search.Sources) > 0 {
+		query = query.Where("source IN ?", search.Sources)
+	}
+	for _, nutrient := range search.Nutrients {
+		if nutrient.Min != nil {
+			query = query.Where(NutrientExpr(nutrient.Key)+" >= ?", *nutrient.Min)
+		}
+		if nutrient.Max != nil {
+			query = query.Where(NutrientExpr(nutrient.Key)+" <= ?", *nutrient.Max)
+		}
+	}
+
+	sort := productSortKeyFor(search)
+	if search.After != nil {
+		op := ">"
+		if sort.desc {
+			op = "<"
+		}
+		query = query.Where(
+			fmt.Sprintf("(? %s CAST(? AS %s) OR (? = CAST(? AS %s) AND products.id > ?))", op, sort.sqlType, sort.sqlType),
+			sort.expr, search.After.Key, sort.expr, search.After.Key, search.After.ID,
+		)
+	}
+
+	direction := "ASC"
+	if sort.desc {
+		direction = "DESC"
+	}
+
+	// One extra row tells whether there is a next page
+	var rows []productRow
+	err := query.
+		Select("products.*, CAST(? AS text) AS sort_key", sort.expr).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "? " + direction + ", products.id ASC",
+			Vars:               []interface{}{sort.expr},
+			WithoutParentheses: true,
+		}}).
+		Limit(search.Limit + 1).
+		Find(&rows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var next *ProductCursor
+	if len(rows) > search.Limit {
+		rows = rows[:search.Limit]
+		last := rows[len(rows)-1]
+		next = &ProductCursor{Key: last.SortKey, ID: last.ID}
+	}
+
+	products := make([]models.Product, len(rows))
+	for i := range rows {
+		products[i] = rows[i].Product
+	}
+	return products, next, nil
+}
+
+func productSortKeyFor(search ProductSearch) productSortKey {
+	switch search.Sort {
+	case ProductSortRelevance:
+		q := search.Query
+		return productSortKey{
+			expr: clause.Expr{
+				SQL:  "GREATEST(ts_rank(" + ProductSearchVector + ", plainto_tsquery('simple', ?)), word_similarity(?, name), coalesce(word_similarity(?, brand), 0))",
+				Vars: []interface{}{q, q, q},
+			},
+			desc:    true,
+			sqlType: "real",
+		}
+	case ProductSortNutriScore:
+		// Ungraded products sort after E
+		return productSortKey{expr: clause.Expr{SQL: "coalesce(upper(nutri_score), 'F')"}, sqlType: "text"}
+	case ProductSortNewest:
+		return productSortKey{expr: clause.Expr{SQL: "products.created_at"}, desc: true, sqlType: "timestamptz"}
+	default:
+		return productSortKey{expr: clause.Expr{SQL: "lower(name)"}, sqlType: "text"}
+	}
+}
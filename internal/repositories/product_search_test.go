@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// searchSQL returns the statement Search runs, without a database
+func searchSQL(t *testing.T, search ProductSearch) string {
+	t.Helper()
+	conn, err := sql.Open("pgx", "host=127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statement string
+	err = db.Callback().Query().After("gorm:query").Register("capture", func(tx *gorm.DB) {
+		statement = tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := NewProductRepository(db).(*productRepository).Search(search); err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	return statement
+}
+
+func TestSearchOnlySharedSources(t *testing.T) {
+	for _, search := range []ProductSearch{
+		{Limit: 20},
+		{Query: "teh", Sort: ProductSortRelevance, Limit: 20},
+	} {
+		statement := searchSQL(t, search)
+		if !strings.Contains(statement, "source IN ('openfoodfacts','manual')") {
+			t.Errorf("search is not limited to shared sources: %s", statement)
+		}
+	}
+}
+
+func TestSearchCursorUsesSortKey(t *testing.T) {
+	id := uuid.MustParse("5f0c6a2e-8a8f-4a53-a7f0-1f6f1a3c2b10")
+
+	tests := []struct {
+		sort ProductSort
+		key  string
+		// where is how the cursor condition must compare the sort key
+		where string
+		// selected is how the key handed out in cursors must be read
+		selected string
+		order    string
+	}{
+		{
+			ProductSortName, "teh botol",
+			"(lower(name) > CAST('teh botol' AS text) OR (lower(name) = CAST('teh botol' AS text)",
+			"CAST(lower(name) AS text) AS sort_key",
+			"ORDER BY lower(name) ASC, products.id ASC",
+		},
+		{
+			ProductSortNutriScore, "C",
+			"(coalesce(upper(nutri_score), 'F') > CAST('C' AS text)",
+			"CAST(coalesce(upper(nutri_score), 'F') AS text) AS sort_key",
+			"ORDER BY coalesce(upper(nutri_score), 'F') ASC, products.id ASC",
+		},
+		{
+			ProductSortNewest, "2026-10-18 09:30:00.123456+00",
+			"(products.created_at < CAST('2026-10-18 09:30:00.123456+00' AS timestamptz)",
+			"CAST(products.created_at AS text) AS sort_key",
+			"ORDER BY products.created_at DESC, products.id ASC",
+		},
+		{
+			ProductSortRelevance, "0.0607927",
+			"(GREATEST(ts_rank(" + ProductSearchVector + ", plainto_tsquery('simple', 'teh')), word_similarity('teh', name), coalesce(word_similarity('teh', brand), 0)) < CAST('0.0607927' AS real)",
+			"CAST(GREATEST(ts_rank(" + ProductSearchVector + ", plainto_tsquery('simple', 'teh')), word_similarity('teh', name), coalesce(word_similarity('teh', brand), 0)) AS text) AS sort_key",
+			"DESC, products.id ASC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.sort), func(t *testing.T) {
+			statement := searchSQL(t, ProductSearch{
+				Query: "teh",
+				Sort:  tt.sort,
+				After: &ProductCursor{Key: tt.key, ID: id},
+				Limit: 20,
+			})
+			for _, want := range []string{
+				tt.where,
+				"products.id > '" + id.String() + "'",
+				tt.selected,
+				tt.order,
+				"LIMIT 21",
+			} {
+				if !strings.Contains(statement, want) {
+					t.Errorf("statement lacks %q:\n%s", want, statement)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+)
+
+type ScanImageRepository interface {
+	Create(image *models.ScanImage) error
+	FindByScanID(scanID string) ([]models.ScanImage, error)
+	CountByScanID(scanID string) (int64, error)
+	Update(image *models.ScanImage) error
+}
+
+type scanImageRepository struct {
+	db *gorm.DB
+}
+
+func NewScanImageRepository(db *gorm.DB) ScanImageRepository {
+	return &scanImageRepository{db: db}
+}
+
+func (r *scanImageRepository) Create(image *models.ScanImage) error {
+	return r.db.Create(image).Error
+}
+
+func (r *scanImageRepository) FindByScanID(scanID string) ([]models.ScanImage, error) {
+	var images []models.ScanImage
+	err := r.db.Where("scan_id = ?", scanID).Order("created_at ASC").Find(&images).Error
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (r *scanImageRepository) CountByScanID(scanID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ScanImage{}).Where("scan_id = ?", scanID).Count(&count).Error
+	return count, err
+}
+
+func (r *scanImageRepository) Update(image *models.ScanImage) error {
+	return r.db.Save(image).Error
+}
@@ -0,0 +1,342 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNoScanJob       = errors.New("no scan job ready")
+	ErrScanJobLockLost = errors.New("scan job lock lost")
+	ErrScanJobNotFound = errors.New("scan job not found")
+	ErrScanJobActive   = errors.New("scan already has a queued or running job")
+)
+
+type ScanJobRepository interface {
+	Enqueue(scanID uuid.UUID, priority models.ScanPriority, maxAttempts int) (*models.ScanJob, error)
+	Claim(workerID string, visibility time.Duration, userMaxInFlight int) (*models.ScanJob, error)
+	Complete(job *models.ScanJob) error
+	Retry(job *models.ScanJob, runAt time.Time, lastError string) error
+	Fail(job *models.ScanJob, lastError string) error
+	DeadLetter(job *models.ScanJob, lastError string) error
+	Unclaim(job *models.ScanJob) error
+	FindDeadLettered(offset, limit int) ([]models.ScanJob, int64, error)
+	Requeue(scanID string) error
+	RequeueExpired() (int64, error)
+	FindOrphanedScanIDs(limit int) ([]uuid.UUID, error)
+	Position(scanID string) (*models.ScanJob, int64, error)
+	Throughput(window time.Duration) (int64, time.Duration, error)
+}
+
+type scanJobRepository struct {
+	db *gorm.DB
+}
+
+func NewScanJobRepository(db *gorm.DB) ScanJobRepository {
+	return &scanJobRepository{db: db}
+}
+
+// Enqueue adds a job for the scan unless one is already queued or running,
+// in which case it returns a nil job.
+//
+// FairSeq follows start-time fair queuing: a user's job goes after their
+// previous queued job, but never before the oldest sequence still waiting,
+// so a user who was idle joins the current round instead of jumping it.
+func (r *scanJobRepository) Enqueue(scanID uuid.UUID, priority models.ScanPriority, maxAttempts int) (*models.ScanJob, error) {
+	var scan models.Scan
+	if err := r.db.Select("id", "user_id").Take(&scan, "id = ?", scanID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScanNotFound
+		}
+		return nil, err
+	}
+	userID := scan.UserID
+
+	active := []models.ScanJobStatus{models.ScanJobStatusQueued, models.ScanJobStatusProcessing}
+
+	var round sql.NullInt64
+	err := r.db.Model(&models.ScanJob{}).
+		Where("status = ? AND priority = ?", models.ScanJobStatusQueued, priority).
+		Select("MIN(fair_seq)").Scan(&round).Error
+	if err != nil {
+		return nil, err
+	}
+	seq := round.Int64
+
+	if userID != nil {
+		var last sql.NullInt64
+		err = r.db.Model(&models.ScanJob{}).
+			Where("user_id = ? AND status IN ? AND priority = ?", userID, active, priority).
+			Select("MAX(fair_seq)").Scan(&last).Error
+		if err != nil {
+			return nil, err
+		}
+		if last.Valid && last.Int64+1 > seq {
+			seq = last.Int64 + 1
+		}
+	}
+
+	job := &models.ScanJob{
+		ScanID:      scanID,
+		UserID:      userID,
+		Status:      models.ScanJobStatusQueued,
+		Priority:    priority,
+		FairSeq:     seq,
+		RunAt:       time.Now(),
+		MaxAttempts: maxAttempts,
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return job, nil
+}
+
+// Claim locks the next due job for this worker. Jobs still marked processing
+// whose lock has expired are picked up again, since their worker is gone.
+// Users already at userMaxInFlight running jobs are skipped; the cap is
+// checked without locking, so concurrent claims may briefly exceed it.
+func (r *scanJobRepository) Claim(workerID string, visibility time.Duration, userMaxInFlight int) (*models.ScanJob, error) {
+	var job models.ScanJob
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+				models.ScanJobStatusQueued, now,
+				models.ScanJobStatusProcessing, now,
+			)
+		if userMaxInFlight > 0 {
+			query = query.Where(`user_id IS NULL OR (
+				SELECT COUNT(*) FROM scan_jobs running
+				WHERE running.user_id = scan_jobs.user_id AND running.status = ? AND running.locked_until >= ?
+			) < ?`, models.ScanJobStatusProcessing, now, userMaxInFlight)
+		}
+		err := query.Order("priority ASC, fair_seq ASC, run_at ASC").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		lockedUntil := now.Add(visibility)
+		job.Status = models.ScanJobStatusProcessing
+		job.Attempts++
+		job.LockedBy = &workerID
+		job.LockedUntil = &lockedUntil
+		return tx.Save(&job).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoScanJob
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *scanJobRepository) Complete(job *models.ScanJob) error {
+	return r.release(job, map[string]interface{}{
+		"status":       models.ScanJobStatusDone,
+		"completed_at": time.Now(),
+		"last_error":   nil,
+	})
+}
+
+func (r *scanJobRepository) Retry(job *models.ScanJob, runAt time.Time, lastError string) error {
+	return r.release(job, map[string]interface{}{
+		"status":     models.ScanJobStatusQueued,
+		"run_at":     runAt,
+		"last_error": lastError,
+	})
+}
+
+func (r *scanJobRepository) Fail(job *models.ScanJob, lastError string) error {
+	return r.release(job, map[string]interface{}{
+		"status":       models.ScanJobStatusFailed,
+		"completed_at": time.Now(),
+		"last_error":   lastError,
+	})
+}
+
+func (r *scanJobRepository) DeadLetter(job *models.ScanJob, lastError string) error {
+	return r.release(job, map[string]interface{}{
+		"status":       models.ScanJobStatusDeadLetter,
+		"completed_at": time.Now(),
+		"last_error":   lastError,
+	})
+}
+
+// Unclaim returns a job to the queue without counting the attempt
+func (r *scanJobRepository) Unclaim(job *models.ScanJob) error {
+	return r.release(job, map[string]interface{}{
+		"status":   models.ScanJobStatusQueued,
+		"run_at":   time.Now(),
+		"attempts": gorm.Expr("GREATEST(attempts - 1, 0)"),
+	})
+}
+
+func (r *scanJobRepository) FindDeadLettered(offset, limit int) ([]models.ScanJob, int64, error) {
+	var jobs []models.ScanJob
+	var total int64
+
+	query := r.db.Model(&models.ScanJob{}).Where("status = ?", models.ScanJobStatusDeadLetter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Scan").
+		Order("completed_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&jobs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, total, nil
+}
+
+// Requeue gives the latest dead-lettered job of a scan a fresh set of
+// attempts and puts the scan back to pending
+func (r *scanJobRepository) Requeue(scanID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var active int64
+		err := tx.Model(&models.ScanJob{}).
+			Where("scan_id = ? AND status IN ?", scanID,
+				[]models.ScanJobStatus{models.ScanJobStatusQueued, models.ScanJobStatusProcessing}).
+			Count(&active).Error
+		if err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrScanJobActive
+		}
+
+		var job models.ScanJob
+		err = tx.Where("scan_id = ? AND status = ?", scanID, models.ScanJobStatusDeadLetter).
+			Order("completed_at DESC").
+			First(&job).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrScanJobNotFound
+			}
+			return err
+		}
+
+		err = tx.Model(&job).Updates(map[string]interface{}{
+			"status":       models.ScanJobStatusQueued,
+			"attempts":     0,
+			"run_at":       time.Now(),
+			"completed_at": nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Scan{}).Where("id = ?", scanID).Updates(map[string]interface{}{
+			"status":        models.ScanStatusPending,
+			"error_code":    nil,
+			"error_message": nil,
+		}).Error
+	})
+}
+
+// release updates a claimed job, provided this worker still holds its lock
+func (r *scanJobRepository) release(job *models.ScanJob, updates map[string]interface{}) error {
+	updates["locked_by"] = nil
+	updates["locked_until"] = nil
+
+	result := r.db.Model(&models.ScanJob{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, models.ScanJobStatusProcessing, job.LockedBy).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrScanJobLockLost
+	}
+	return nil
+}
+
+// RequeueExpired puts jobs whose worker died mid-scan back in the queue
+func (r *scanJobRepository) RequeueExpired() (int64, error) {
+	result := r.db.Model(&models.ScanJob{}).
+		Where("status = ? AND locked_until < ?", models.ScanJobStatusProcessing, time.Now()).
+		Updates(map[string]interface{}{
+			"status":       models.ScanJobStatusQueued,
+			"run_at":       time.Now(),
+			"locked_by":    nil,
+			"locked_until": nil,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Position returns the queued job of a scan and how many queued jobs are
+// ordered ahead of it
+func (r *scanJobRepository) Position(scanID string) (*models.ScanJob, int64, error) {
+	var job models.ScanJob
+	err := r.db.Where("scan_id = ? AND status = ?", scanID, models.ScanJobStatusQueued).First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrScanJobNotFound
+		}
+		return nil, 0, err
+	}
+
+	var ahead int64
+	err = r.db.Model(&models.ScanJob{}).
+		Where("status = ? AND id <> ?", models.ScanJobStatusQueued, job.ID).
+		Where("priority < ? OR (priority = ? AND fair_seq < ?) OR (priority = ? AND fair_seq = ? AND run_at < ?)",
+			job.Priority,
+			job.Priority, job.FairSeq,
+			job.Priority, job.FairSeq, job.RunAt,
+		).
+		Count(&ahead).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &job, ahead, nil
+}
+
+// Throughput returns how many jobs finished within the window and the
+// average processing time of their scans
+func (r *scanJobRepository) Throughput(window time.Duration) (int64, time.Duration, error) {
+	var stats struct {
+		Completed int64
+		AvgMs     sql.NullFloat64
+	}
+	err := r.db.Model(&models.ScanJob{}).
+		Joins("JOIN scans ON scans.id = scan_jobs.scan_id").
+		Where("scan_jobs.status = ? AND scan_jobs.completed_at >= ?", models.ScanJobStatusDone, time.Now().Add(-window)).
+		Select("COUNT(*) AS completed, AVG(scans.processing_time_ms) AS avg_ms").
+		Scan(&stats).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return stats.Completed, time.Duration(stats.AvgMs.Float64 * float64(time.Millisecond)), nil
+}
+
+// FindOrphanedScanIDs returns scans waiting for OCR that have no active job,
+// such as scans queued in memory before jobs were persisted
+func (r *scanJobRepository) FindOrphanedScanIDs(limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&models.Scan{}).
+		Where("status IN ?", []models.ScanStatus{models.ScanStatusPending, models.ScanStatusProcessing}).
+		Where("(image_stored = ? AND image_ref IS NOT NULL) OR EXISTS (SELECT 1 FROM scan_images WHERE scan_images.scan_id = scans.id)", true).
+		Where("NOT EXISTS (SELECT 1 FROM scan_jobs WHERE scan_jobs.scan_id = scans.id AND scan_jobs.status IN ?)",
+			[]models.ScanJobStatus{models.ScanJobStatusQueued, models.ScanJobStatusProcessing}).
+		Order("created_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
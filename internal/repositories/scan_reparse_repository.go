@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrScanReparseNotFound = errors.New("scan reparse not found")
+	ErrNoScanReparse       = errors.New("no scan reparse queued")
+	ErrScanReparseLockLost = errors.New("scan reparse lock lost")
+)
+
+type ScanReparseRepository interface {
+	Create(run *models.ScanReparse) error
+	FindByID(id string) (*models.ScanReparse, error)
+	FindRecent(offset, limit int) ([]models.ScanReparse, int64, error)
+	// Claim locks the oldest queued run, or a running one whose worker
+	// stopped renewing its lock
+	Claim(visibility time.Duration) (*models.ScanReparse, error)
+	// SaveProgress stores the cursor, counts and report of a claimed run
+	// and renews its lock
+	SaveProgress(run *models.ScanReparse, visibility time.Duration) error
+	// Finish stores the final state of a claimed run
+	Finish(run *models.ScanReparse) error
+}
+
+type scanReparseRepository struct {
+	db *gorm.DB
+}
+
+func NewScanReparseRepository(db *gorm.DB) ScanReparseRepository {
+	return &scanReparseRepository{db: db}
+}
+
+func (r *scanReparseRepository) Create(run *models.ScanReparse) error {
+	return r.db.Create(run).Error
+}
+
+func (r *scanReparseRepository) FindByID(id string) (*models.ScanReparse, error) {
+	var run models.ScanReparse
+	if err := r.db.First(&run, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScanReparseNotFound
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FindRecent returns runs newest first, without their reports
+func (r *scanReparseRepository) FindRecent(offset, limit int) ([]models.ScanReparse, int64, error) {
+	var runs []models.ScanReparse
+	var total int64
+
+	if err := r.db.Model(&models.ScanReparse{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := r.db.Omit("report").Order("created_at DESC").Offset(offset).Limit(limit).Find(&runs).Error
+	return runs, total, err
+}
+
+func (r *scanReparseRepository) Claim(visibility time.Duration) (*models.ScanReparse, error) {
+	var run models.ScanReparse
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND locked_until < ?)",
+				models.ReparseQueued,
+				models.ReparseRunning, now,
+			).
+			Order("created_at ASC").
+			First(&run).Error
+		if err != nil {
+			return err
+		}
+
+		lockedUntil := now.Add(visibility)
+		run.Status = models.ReparseRunning
+		run.Claims++
+		run.LockedUntil = &lockedUntil
+		if run.StartedAt == nil {
+			run.StartedAt = &now
+		}
+
+		return tx.Model(&run).Updates(map[string]interface{}{
+			"status":       run.Status,
+			"claims":       run.Claims,
+			"locked_until": lockedUntil,
+			"started_at":   run.StartedAt,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoScanReparse
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *scanReparseRepository) SaveProgress(run *models.ScanReparse, visibility time.Duration) error {
+	lockedUntil := time.Now().Add(visibility)
+	err := r.update(run, map[string]interface{}{
+		"cursor_created_at": run.CursorCreatedAt,
+		"cursor_id":         run.CursorID,
+		"processed":         run.Processed,
+		"changed":           run.Changed,
+		"unchanged":         run.Unchanged,
+		"failed":            run.Failed,
+		"report":            run.ReportJSON,
+		"locked_until":      lockedUntil,
+	})
+	if err == nil {
+		run.LockedUntil = &lockedUntil
+	}
+	return err
+}
+
+func (r *scanReparseRepository) Finish(run *models.ScanReparse) error {
+	return r.update(run, map[string]interface{}{
+		"status":            run.Status,
+		"cursor_created_at": run.CursorCreatedAt,
+		"cursor_id":         run.CursorID,
+		"processed":         run.Processed,
+		"changed":           run.Changed,
+		"unchanged":         run.Unchanged,
+		"failed":            run.Failed,
+		"report":            run.ReportJSON,
+		"error":             run.Error,
+		"finished_at":       run.FinishedAt,
+		"locked_until":      nil,
+	})
+}
+
+// update changes a claimed run, provided no other worker has claimed it
+// since
+func (r *scanReparseRepository) update(run *models.ScanReparse, updates map[string]interface{}) error {
+	result := r.db.Model(&models.ScanReparse{}).
+		Where("id = ? AND status = ? AND claims = ?", run.ID, models.ReparseRunning, run.Claims).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrScanReparseLockLost
+	}
+	return nil
+}
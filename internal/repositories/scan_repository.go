@@ -7,18 +7,46 @@ import (
 	"github.com/google/uuid"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	ErrScanNotFound = errors.New("scan not found")
 )
 
+// ScanFilter selects scans for bulk operations. It is stored with bulk
+// runs, so it encodes to JSON.
+type ScanFilter struct {
+	From          *time.Time          `json:"from,omitempty"`
+	To            *time.Time          `json:"to,omitempty"`
+	Statuses      []models.ScanStatus `json:"statuses,omitempty"`
+	ParserVersion *string             `json:"parser_version,omitempty"`
+	// ExcludeParserVersion skips scans already read by this parser version
+	ExcludeParserVersion *string `json:"exclude_parser_version,omitempty"`
+}
+
+// ScanCursor is the last scan of a page read in created_at, id order
+type ScanCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
 type ScanRepository interface {
 	Create(scan *models.Scan) error
 	FindByID(id string) (*models.Scan, error)
 	FindByUserID(userID string, offset, limit int) ([]models.Scan, int64, error)
 	FindOldScansWithImages(olderThan time.Time, limit int) ([]models.Scan, error)
+	FindOldestWithStoredImages(userID, exclude uuid.UUID, limit int) ([]models.Scan, error)
+	ReleaseImages(id uuid.UUID) error
 	Update(scan *models.Scan) error
+	AddImages(scan *models.Scan, images []models.ScanImage) error
+	FindWithImages(after uuid.UUID, createdBefore time.Time, limit int) ([]models.Scan, error)
+	SetImageMissing(ids []uuid.UUID, missingAt *time.Time) error
+	UpdateStatus(id string, status models.ScanStatus) error
+	MarkFailed(id string, code string, message string) error
+	UpdateTrace(id string, trace models.JSON, processingTimeMs int) error
+	FindWithOCRText(filter ScanFilter, after *ScanCursor, limit int) ([]models.Scan, error)
+	// Delete removes a scan together with its image records
 	Delete(id string) error
 	Count() (int64, error)
 	CountByUserID(userID string) (int64, error)
@@ -38,7 +66,9 @@ func (r *scanRepository) Create(scan *models.Scan) error {
 
 func (r *scanRepository) FindByID(id string) (*models.Scan, error) {
 	var scan models.Scan
-	err := r.db.Preload("Product").Where("id = ?", id).First(&scan).Error
+	err := r.db.Preload("Product").Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("id = ?", id).First(&scan).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrScanNotFound
@@ -61,7 +91,7 @@ func (r *scanRepository) FindByUserID(userID string, offset, limit int) ([]model
 		return nil, 0, err
 	}
 
-	if err := r.db.Preload("Product").Where("user_id = ?", uid).
+	if err := r.db.Preload("Product").Preload("Images").Where("user_id = ?", uid).
 		Offset(offset).Limit(limit).
 		Order("created_at DESC").
 		Find(&scans).Error; err != nil {
@@ -75,8 +105,103 @@ func (r *scanRepository) Update(scan *models.Scan) error {
 	return r.db.Save(scan).Error
 }
 
+// AddImages saves new images of a scan together with the scan, so either
+// both are stored or neither is
+func (r *scanRepository) AddImages(scan *models.Scan, images []models.ScanImage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&images).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(scan).Error; err != nil {
+			return err
+		}
+		scan.Images = append(scan.Images, images...)
+		return nil
+	})
+}
+
+// FindWithImages pages through scans that reference stored images in ID
+// order, starting after the given ID. Scans created from createdBefore on
+// are left out, since their uploads may be newer than a storage listing.
+func (r *scanRepository) FindWithImages(after uuid.UUID, createdBefore time.Time, limit int) ([]models.Scan, error) {
+	var scans []models.Scan
+	err := r.db.Preload("Images").
+		Where("id > ? AND created_at < ?", after, createdBefore).
+		Where("image_ref IS NOT NULL OR EXISTS (SELECT 1 FROM scan_images WHERE scan_images.scan_id = scans.id)").
+		Order("id ASC").
+		Limit(limit).
+		Find(&scans).Error
+	return scans, err
+}
+
+// SetImageMissing flags scans whose images are gone, or clears the flag
+// when missingAt is nil
+func (r *scanRepository) SetImageMissing(ids []uuid.UUID, missingAt *time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.Scan{}).Where("id IN ?", ids).Update("image_missing_at", missingAt).Error
+}
+
+func (r *scanRepository) UpdateStatus(id string, status models.ScanStatus) error {
+	return r.db.Model(&models.Scan{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (r *scanRepository) MarkFailed(id string, code string, message string) error {
+	return r.db.Model(&models.Scan{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        models.ScanStatusFailed,
+		"error_code":    code,
+		"error_message": message,
+	}).Error
+}
+
+func (r *scanRepository) UpdateTrace(id string, trace models.JSON, processingTimeMs int) error {
+	return r.db.Model(&models.Scan{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"trace_json":         trace,
+		"processing_time_ms": processingTimeMs,
+	}).Error
+}
+
+// FindWithOCRText returns scans matching the filter that have stored OCR
+// text, oldest first, continuing after the cursor when one is given
+func (r *scanRepository) FindWithOCRText(filter ScanFilter, after *ScanCursor, limit int) ([]models.Scan, error) {
+	query := r.db.Preload("Product").
+		Preload("Images", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
+		Where("ocr_raw IS NOT NULL OR EXISTS (SELECT 1 FROM scan_images WHERE scan_images.scan_id = scans.id AND scan_images.ocr_raw IS NOT NULL)")
+
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.ParserVersion != nil {
+		query = query.Where("parser_version = ?", *filter.ParserVersion)
+	}
+	if filter.ExcludeParserVersion != nil {
+		query = query.Where("parser_version IS DISTINCT FROM ?", *filter.ExcludeParserVersion)
+	}
+	if after != nil {
+		query = query.Where("(created_at, id) > (?, ?)", after.CreatedAt, after.ID)
+	}
+
+	var scans []models.Scan
+	err := query.Order("created_at ASC, id ASC").Limit(limit).Find(&scans).Error
+	return scans, err
+}
+
 func (r *scanRepository) Delete(id string) error {
-	return r.db.Delete(&models.Scan{}, "id = ?", id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("scan_id = ?", id).Delete(&models.ScanImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Scan{}, "id = ?", id).Error
+	})
 }
 
 func (r *scanRepository) Count() (int64, error) {
@@ -98,8 +223,32 @@ func (r *scanRepository) CountByUserID(userID string) (int64, error) {
 // FindOldScansWithImages finds scans older than cutoff date that have stored images
 func (r *scanRepository) FindOldScansWithImages(olderThan time.Time, limit int) ([]models.Scan, error) {
 	var scans []models.Scan
-	err := r.db.Where("created_at < ? AND image_stored = ? AND image_ref IS NOT NULL", olderThan, true).
+	err := r.db.Preload("Images").
+		Where("created_at < ? AND image_stored = ? AND image_ref IS NOT NULL", olderThan, true).
+		Limit(limit).
+		Find(&scans).Error
+	return scans, err
+}
+
+// FindOldestWithStoredImages returns a user's scans that still store
+// images, oldest first. Scans OCR may be reading and the excluded scan
+// are left out.
+func (r *scanRepository) FindOldestWithStoredImages(userID, exclude uuid.UUID, limit int) ([]models.Scan, error) {
+	var scans []models.Scan
+	err := r.db.Preload("Images").
+		Where("user_id = ? AND id <> ? AND image_stored = ? AND status NOT IN ?", userID, exclude, true,
+			[]models.ScanStatus{models.ScanStatusPending, models.ScanStatusProcessing}).
+		Order("created_at ASC").
 		Limit(limit).
 		Find(&scans).Error
 	return scans, err
 }
+
+// ReleaseImages records that a scan's images were removed from storage.
+// The OCR results stay.
+func (r *scanRepository) ReleaseImages(id uuid.UUID) error {
+	return r.db.Model(&models.Scan{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"image_stored": false,
+		"image_ref":    nil,
+	}).Error
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrScanUploadNotFound = errors.New("scan upload not found")
+	ErrUploadCommitted    = errors.New("scan upload already committed")
+)
+
+type ScanUploadRepository interface {
+	Create(upload *models.ScanUpload) error
+	FindByID(id string) (*models.ScanUpload, error)
+	Commit(upload *models.ScanUpload, scan *models.Scan) error
+}
+
+type scanUploadRepository struct {
+	db *gorm.DB
+}
+
+func NewScanUploadRepository(db *gorm.DB) ScanUploadRepository {
+	return &scanUploadRepository{db: db}
+}
+
+func (r *scanUploadRepository) Create(upload *models.ScanUpload) error {
+	return r.db.Create(upload).Error
+}
+
+func (r *scanUploadRepository) FindByID(id string) (*models.ScanUpload, error) {
+	var upload models.ScanUpload
+	if err := r.db.Where("id = ?", id).First(&upload).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScanUploadNotFound
+		}
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// Commit marks the upload committed and creates its scan in one
+// transaction. Only one of two concurrent commits can succeed; the other
+// gets ErrUploadCommitted.
+func (r *scanUploadRepository) Commit(upload *models.ScanUpload, scan *models.Scan) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.ScanUpload{}).
+			Where("id = ? AND committed_at IS NULL", upload.ID).
+			Update("committed_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUploadCommitted
+		}
+
+		if err := tx.Create(scan).Error; err != nil {
+			return err
+		}
+		upload.CommittedAt = &now
+		return nil
+	})
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+)
+
+type StorageReconciliationRepository interface {
+	TryStart(report *models.StorageReconciliation, staleAfter time.Duration) (bool, error)
+	Update(report *models.StorageReconciliation) error
+	FindRecent(offset, limit int) ([]models.StorageReconciliation, int64, error)
+}
+
+type storageReconciliationRepository struct {
+	db *gorm.DB
+}
+
+func NewStorageReconciliationRepository(db *gorm.DB) StorageReconciliationRepository {
+	return &storageReconciliationRepository{db: db}
+}
+
+// TryStart saves a running report unless another instance has a run in
+// progress. A run older than staleAfter is assumed to have died with its
+// process.
+func (r *storageReconciliationRepository) TryStart(report *models.StorageReconciliation, staleAfter time.Duration) (bool, error) {
+	started := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Serialises the check and insert between worker processes
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('storage_reconciliation'))").Error; err != nil {
+			return err
+		}
+
+		var running int64
+		err := tx.Model(&models.StorageReconciliation{}).
+			Where("status = ? AND started_at > ?", models.ReconciliationRunning, time.Now().Add(-staleAfter)).
+			Count(&running).Error
+		if err != nil || running > 0 {
+			return err
+		}
+
+		started = true
+		return tx.Create(report).Error
+	})
+	return started && err == nil, err
+}
+
+func (r *storageReconciliationRepository) Update(report *models.StorageReconciliation) error {
+	return r.db.Save(report).Error
+}
+
+// FindRecent returns reports newest first
+func (r *storageReconciliationRepository) FindRecent(offset, limit int) ([]models.StorageReconciliation, int64, error) {
+	var reports []models.StorageReconciliation
+	var total int64
+
+	if err := r.db.Model(&models.StorageReconciliation{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := r.db.Order("started_at DESC").Offset(offset).Limit(limit).Find(&reports).Error
+	return reports, total, err
+}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+)
+
+type StorageUsageRepository interface {
+	Add(userID uuid.UUID, bytes int64, objects int) error
+	// Reserve adds to a user's usage only if the result stays within the
+	// limits, where zero means unlimited, and reports whether it did
+	Reserve(userID uuid.UUID, bytes int64, objects int, maxBytes int64, maxObjects int) (bool, error)
+	FindByUserID(userID uuid.UUID) (*models.StorageUsage, error)
+	FindHeaviest(offset, limit int) ([]models.StorageUsage, int64, error)
+	Recalculate() error
+}
+
+type storageUsageRepository struct {
+	db *gorm.DB
+}
+
+func NewStorageUsageRepository(db *gorm.DB) StorageUsageRepository {
+	return &storageUsageRepository{db: db}
+}
+
+// Add changes a user's usage by the given amounts, which are negative for
+// removed images. Usage never drops below zero.
+func (r *storageUsageRepository) Add(userID uuid.UUID, bytes int64, objects int) error {
+	return r.db.Exec(`
+		INSERT INTO storage_usages (user_id, bytes, objects, updated_at)
+		VALUES (?, GREATEST(?::bigint, 0), GREATEST(?::integer, 0), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			bytes = GREATEST(storage_usages.bytes + ?::bigint, 0),
+			objects = GREATEST(storage_usages.objects + ?::integer, 0),
+			updated_at = NOW()`,
+		userID, bytes, objects, bytes, objects).Error
+}
+
+// Reserve checks and adds in one statement, so concurrent uploads of a user
+// cannot overshoot the limits together. A user without usage yet starts
+// from zero, and callers reject uploads larger than the limits themselves.
+func (r *storageUsageRepository) Reserve(userID uuid.UUID, bytes int64, objects int, maxBytes int64, maxObjects int) (bool, error) {
+	result := r.db.Exec(`
+		INSERT INTO storage_usages (user_id, bytes, objects, updated_at)
+		VALUES (?, ?::bigint, ?::integer, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			bytes = storage_usages.bytes + EXCLUDED.bytes,
+			objects = storage_usages.objects + EXCLUDED.objects,
+			updated_at = NOW()
+		WHERE (?::bigint = 0 OR storage_usages.bytes + EXCLUDED.bytes <= ?::bigint)
+			AND (?::integer = 0 OR storage_usages.objects + EXCLUDED.objects <= ?::integer)`,
+		userID, bytes, objects, maxBytes, maxBytes, maxObjects, maxObjects)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindByUserID returns a user's usage, which is zero before their first upload
+func (r *storageUsageRepository) FindByUserID(userID uuid.UUID) (*models.StorageUsage, error) {
+	var usage models.StorageUsage
+	err := r.db.Where("user_id = ?", userID).First(&usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.StorageUsage{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// FindHeaviest returns users with stored images, largest usage first
+func (r *storageUsageRepository) FindHeaviest(offset, limit int) ([]models.StorageUsage, int64, error) {
+	var usages []models.StorageUsage
+	var total int64
+
+	query := r.db.Model(&models.StorageUsage{}).Where("objects > 0")
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("User").
+		Order("bytes DESC, objects DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&usages).Error
+	return usages, total, err
+}
+
+// Recalculate rebuilds every user's usage from the images their scans
+// still store, correcting drift from updates that failed
+func (r *storageUsageRepository) Recalculate() error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE storage_usages SET bytes = 0, objects = 0, updated_at = NOW()`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+			INSERT INTO storage_usages (user_id, bytes, objects, updated_at)
+			SELECT scans.user_id, COALESCE(SUM(scan_images.size_bytes), 0), COUNT(*), NOW()
+			FROM scans
+			JOIN scan_images ON scan_images.scan_id = scans.id
+			WHERE scans.image_stored AND scans.deleted_at IS NULL AND scans.user_id IS NOT NULL
+			GROUP BY scans.user_id
+			ON CONFLICT (user_id) DO UPDATE SET
+				bytes = EXCLUDED.bytes,
+				objects = EXCLUDED.objects,
+				updated_at = EXCLUDED.updated_at`).Error
+	})
+}
@@ -0,0 +1,299 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrNoWebhookDelivery       = errors.New("no webhook delivery due")
+	ErrWebhookDeliveryLockLost = errors.New("webhook delivery lock lost")
+)
+
+type WebhookRepository interface {
+	Create(webhook *models.WebhookSubscription) error
+	FindByID(id string) (*models.WebhookSubscription, error)
+	// FindByOwner returns the webhooks a user manages itself when clientID
+	// is nil, or those of one of the user's API clients
+	FindByOwner(userID string, clientID *uuid.UUID) ([]models.WebhookSubscription, error)
+	Update(webhook *models.WebhookSubscription) error
+	Delete(id string) error
+	FindSubscribed(event models.WebhookEventType, userID *uuid.UUID) ([]models.WebhookSubscription, error)
+
+	CreateDeliveries(deliveries []models.WebhookDelivery) error
+	FindDeliveries(subscriptionID string, offset, limit int) ([]models.WebhookDelivery, int64, error)
+	FindDelivery(subscriptionID, deliveryID string) (*models.WebhookDelivery, error)
+	ClaimDelivery(visibility time.Duration) (*models.WebhookDelivery, error)
+	MarkDelivered(delivery *models.WebhookDelivery) error
+	MarkAttemptFailed(delivery *models.WebhookDelivery, disableAfter int) (disabled bool, err error)
+	FailDelivery(delivery *models.WebhookDelivery, reason string) error
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) Create(webhook *models.WebhookSubscription) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *webhookRepository) FindByID(id string) (*models.WebhookSubscription, error) {
+	var webhook models.WebhookSubscription
+	if err := r.db.First(&webhook, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *webhookRepository) FindByOwner(userID string, clientID *uuid.UUID) ([]models.WebhookSubscription, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if clientID != nil {
+		query = query.Where("client_id = ?", clientID)
+	} else {
+		query = query.Where("client_id IS NULL")
+	}
+
+	var webhooks []models.WebhookSubscription
+	err := query.Order("created_at DESC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *webhookRepository) Update(webhook *models.WebhookSubscription) error {
+	return r.db.Save(webhook).Error
+}
+
+func (r *webhookRepository) Delete(id string) error {
+	result := r.db.Delete(&models.WebhookSubscription{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// FindSubscribed returns the active webhooks that want an event. With a
+// user only that user's webhooks match, without one every webhook does.
+func (r *webhookRepository) FindSubscribed(event models.WebhookEventType, userID *uuid.UUID) ([]models.WebhookSubscription, error) {
+	query := r.db.Where("active = ? AND events @> ?::jsonb", true, `["`+string(event)+`"]`)
+	if userID != nil {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var webhooks []models.WebhookSubscription
+	err := query.Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *webhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Create(&deliveries).Error
+}
+
+func (r *webhookRepository) FindDeliveries(subscriptionID string, offset, limit int) ([]models.WebhookDelivery, int64, error) {
+	var deliveries []models.WebhookDelivery
+	var total int64
+
+	query := r.db.Model(&models.WebhookDelivery{}).Where("subscription_id = ?", subscriptionID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+func (r *webhookRepository) FindDelivery(subscriptionID, deliveryID string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.First(&delivery, "id = ? AND subscription_id = ?", deliveryID, subscriptionID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ClaimDelivery locks the next due delivery with its webhook. Deliveries
+// left in delivering past their lock belonged to a worker that died and
+// are picked up again.
+func (r *webhookRepository) ClaimDelivery(visibility time.Duration) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				models.WebhookDeliveryPending, now,
+				models.WebhookDeliveryDelivering, now,
+			).
+			Order("next_attempt_at ASC").
+			First(&delivery).Error
+		if err != nil {
+			return err
+		}
+
+		lockedUntil := now.Add(visibility)
+		delivery.Status = models.WebhookDeliveryDelivering
+		delivery.Attempts++
+		delivery.LockedUntil = &lockedUntil
+
+		return tx.Model(&delivery).Updates(map[string]interface{}{
+			"status":       delivery.Status,
+			"attempts":     delivery.Attempts,
+			"locked_until": lockedUntil,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoWebhookDelivery
+		}
+		return nil, err
+	}
+
+	subscription, err := r.FindByID(delivery.SubscriptionID.String())
+	if err != nil {
+		return nil, err
+	}
+	delivery.Subscription = subscription
+
+	return &delivery, nil
+}
+
+// MarkDelivered records a successful attempt and resets the webhook's
+// failure count
+func (r *webhookRepository) MarkDelivered(delivery *models.WebhookDelivery) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := releaseDelivery(tx, delivery, map[string]interface{}{
+			"status":           models.WebhookDeliverySucceeded,
+			"delivered_at":     now,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       nil,
+			"response_body":    delivery.ResponseBody,
+			"duration_ms":      delivery.DurationMs,
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.WebhookSubscription{}).
+			Where("id = ?", delivery.SubscriptionID).
+			Updates(map[string]interface{}{
+				"consecutive_failures": 0,
+				"last_delivery_at":     now,
+			}).Error
+	})
+}
+
+// MarkAttemptFailed records a failed attempt. The delivery is retried at
+// NextAttemptAt unless it is exhausted. The webhook is disabled once its
+// consecutive failures reach disableAfter, and then reports true.
+func (r *webhookRepository) MarkAttemptFailed(delivery *models.WebhookDelivery, disableAfter int) (bool, error) {
+	disabled := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		status := models.WebhookDeliveryPending
+		if delivery.Exhausted() {
+			status = models.WebhookDeliveryFailed
+		}
+
+		err := releaseDelivery(tx, delivery, map[string]interface{}{
+			"status":           status,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"response_body":    delivery.ResponseBody,
+			"duration_ms":      delivery.DurationMs,
+		})
+		if err != nil {
+			return err
+		}
+
+		var webhook models.WebhookSubscription
+		err = tx.Clauses(clause.Returning{}).Model(&webhook).
+			Where("id = ?", delivery.SubscriptionID).
+			Updates(map[string]interface{}{
+				"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+				"last_delivery_at":     time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		if disableAfter <= 0 || !webhook.Active || webhook.ConsecutiveFailures < disableAfter {
+			return nil
+		}
+		disabled = true
+		return disableWebhook(tx, delivery.SubscriptionID, "Disabled after repeated delivery failures")
+	})
+	return disabled, err
+}
+
+// FailDelivery gives up on a delivery without sending it
+func (r *webhookRepository) FailDelivery(delivery *models.WebhookDelivery, reason string) error {
+	return releaseDelivery(r.db, delivery, map[string]interface{}{
+		"status":     models.WebhookDeliveryFailed,
+		"last_error": reason,
+	})
+}
+
+// releaseDelivery updates a claimed delivery, provided this worker still
+// holds it. Every claim counts an attempt, so a delivery taken over after
+// its lock expired no longer has the attempts this worker claimed it with.
+func releaseDelivery(tx *gorm.DB, delivery *models.WebhookDelivery, updates map[string]interface{}) error {
+	updates["locked_until"] = nil
+
+	result := tx.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", delivery.ID, models.WebhookDeliveryDelivering, delivery.Attempts).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookDeliveryLockLost
+	}
+	return nil
+}
+
+// disableWebhook stops a webhook and fails its queued deliveries, which
+// can be redelivered once it is enabled again
+func disableWebhook(tx *gorm.DB, id uuid.UUID, reason string) error {
+	err := tx.Model(&models.WebhookSubscription{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"active":          false,
+			"disabled_at":     time.Now(),
+			"disabled_reason": reason,
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&models.WebhookDelivery{}).
+		Where("subscription_id = ? AND status = ?", id, models.WebhookDeliveryPending).
+		Updates(map[string]interface{}{
+			"status":     models.WebhookDeliveryFailed,
+			"last_error": reason,
+		}).Error
+}
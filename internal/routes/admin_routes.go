@@ -23,4 +23,15 @@ func SetupAdminRoutes(v1 fiber.Router, adminController *controllers.AdminControl
 	admin.Get("/users/:id", adminController.GetUser)
 	admin.Put("/users/:id/role", adminController.UpdateUserRole)
 	admin.Delete("/users/:id", adminController.DeleteUser)
+
+	// Scan queue
+	admin.Get("/scans/dead-letter", adminController.GetDeadLetterScans)
+	admin.Post("/scans/:id/requeue", adminController.RequeueScan)
+	admin.Post("/scans/reparse", adminController.ReparseScans)
+	admin.Get("/scans/reparse", adminController.GetScanReparses)
+	admin.Get("/scans/reparse/:id", adminController.GetScanReparse)
+
+	// Storage
+	admin.Get("/storage/reconciliations", adminController.GetStorageReconciliations)
+	admin.Get("/storage/users", adminController.GetStorageUsers)
 }
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/internal/controllers"
+	"github.com/habbazettt/nutrisnap-server/internal/middleware"
+	"github.com/habbazettt/nutrisnap-server/pkg/jwt"
+)
+
+// SetupAPIClientRoutes registers API client management routes (protected).
+// Only users manage clients, so API keys are not accepted here.
+func SetupAPIClientRoutes(v1 fiber.Router, clientController *controllers.APIClientController, jwtManager *jwt.Manager) {
+	clients := v1.Group("/api-clients", middleware.JWTAuth(middleware.AuthConfig{
+		JWTManager: jwtManager,
+	}))
+
+	clients.Post("/", clientController.CreateClient)
+	clients.Get("/", clientController.GetClients)
+	clients.Delete("/:id", clientController.DeleteClient)
+}
@@ -17,4 +17,9 @@ func SetupProductRoutes(v1 fiber.Router, productController *controllers.ProductC
 	}))
 
 	product.Get("/:barcode", productController.GetProduct)
+
+	products := v1.Group("/products", middleware.JWTAuth(middleware.AuthConfig{
+		JWTManager: jwtManager,
+	}))
+	products.Get("/search", productController.SearchProducts)
 }
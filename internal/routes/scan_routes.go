@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/habbazettt/nutrisnap-server/internal/controllers"
 	"github.com/habbazettt/nutrisnap-server/internal/middleware"
@@ -8,20 +9,32 @@ import (
 )
 
 // SetupScanRoutes registers all scan routes
-func SetupScanRoutes(v1 fiber.Router, scanController *controllers.ScanController, correctionController *controllers.CorrectionController, jwtManager *jwt.Manager) {
+func SetupScanRoutes(v1 fiber.Router, scanController *controllers.ScanController, eventsController *controllers.ScanEventsController, correctionController *controllers.CorrectionController, jwtManager *jwt.Manager, idempotency fiber.Handler) {
 	scan := v1.Group("/scan")
 
 	// All scan routes require authentication
-	scan.Use(middleware.JWTAuth(middleware.AuthConfig{JWTManager: jwtManager}))
+	scan.Use(middleware.JWTAuth(middleware.AuthConfig{
+		JWTManager: jwtManager,
+		QueryParam: "access_token",
+	}))
 
-	// Scan endpoints
-	scan.Post("/", scanController.Upload)
+	// Status events, registered before /:id so "ws" is not taken for an ID
+	scan.Get("/ws", eventsController.RequireWebSocket, websocket.New(eventsController.StreamUserEvents))
+	scan.Get("/:id/events", eventsController.StreamScanEvents)
+
+	// Scan endpoints. Mutations honour Idempotency-Key so client retries
+	// do not create duplicate scans.
+	scan.Post("/", idempotency, scanController.Upload)
+	scan.Post("/upload-url", idempotency, scanController.CreateUploadURL)
 	scan.Get("/", scanController.GetUserScans)
 	scan.Get("/:id", scanController.GetScan)
 	scan.Get("/:id/image", scanController.GetScanImageURL)
+	scan.Post("/:id/images", idempotency, scanController.AttachImages)
+	scan.Post("/:id/commit", idempotency, scanController.CommitUpload)
+	scan.Post("/:id/reprocess", idempotency, scanController.ReprocessScan)
 	scan.Delete("/:id", scanController.DeleteScan)
 
 	// Correction endpoints
-	scan.Post("/:id/correct", correctionController.CreateCorrection)
+	scan.Post("/:id/correct", idempotency, correctionController.CreateCorrection)
 	scan.Get("/:id/corrections", correctionController.GetCorrections)
 }
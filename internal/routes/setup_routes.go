@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/habbazettt/nutrisnap-server/internal/controllers"
+	"github.com/habbazettt/nutrisnap-server/internal/middleware"
 	"github.com/habbazettt/nutrisnap-server/pkg/jwt"
 	"github.com/habbazettt/nutrisnap-server/pkg/response"
 )
@@ -13,10 +14,16 @@ type Container interface {
 	GetUserController() *controllers.UserController
 	GetAdminController() *controllers.AdminController
 	GetScanController() *controllers.ScanController
+	GetScanEventsController() *controllers.ScanEventsController
 	GetProductController() *controllers.ProductController
 	GetCorrectionController() *controllers.CorrectionController
 	GetCompareController() *controllers.CompareController
+	GetWebhookController() *controllers.WebhookController
+	GetAPIClientController() *controllers.APIClientController
+	GetAPIClients() middleware.APIKeyAuthenticator
+	GetStorageController() *controllers.StorageController
 	GetJWTManager() *jwt.Manager
+	GetIdempotency() fiber.Handler
 }
 
 // SetupRoutes is the main registry that registers all routes
@@ -31,12 +38,15 @@ func SetupRoutes(app *fiber.App, container Container) {
 	// Register all route groups
 	SetupHealthRoutes(app, v1)
 	SetupDocsRoutes(app)
+	SetupStorageRoutes(app, container.GetStorageController())
 	SetupAuthRoutes(v1, container.GetAuthController())
 	SetupUserRoutes(v1, container.GetUserController(), container.GetJWTManager())
 	SetupAdminRoutes(v1, container.GetAdminController(), container.GetJWTManager())
-	SetupScanRoutes(v1, container.GetScanController(), container.GetCorrectionController(), container.GetJWTManager())
+	SetupScanRoutes(v1, container.GetScanController(), container.GetScanEventsController(), container.GetCorrectionController(), container.GetJWTManager(), container.GetIdempotency())
 	SetupProductRoutes(v1, container.GetProductController(), container.GetJWTManager())
 	SetupCompareRoutes(v1, container.GetCompareController(), container.GetJWTManager())
+	SetupWebhookRoutes(v1, container.GetWebhookController(), container.GetJWTManager(), container.GetAPIClients(), container.GetIdempotency())
+	SetupAPIClientRoutes(v1, container.GetAPIClientController(), container.GetJWTManager())
 
 	// 404 Handler - must be last
 	app.Use(notFoundHandler)
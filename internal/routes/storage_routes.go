@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/internal/controllers"
+)
+
+// SetupStorageRoutes serves local storage files. The signed URL is the
+// credential, so there is no JWT check. Nothing is registered for cloud
+// backends.
+func SetupStorageRoutes(app *fiber.App, storageController *controllers.StorageController) {
+	if storageController == nil {
+		return
+	}
+
+	app.Get("/storage/*", storageController.ServeFile)
+}
@@ -18,4 +18,5 @@ func SetupUserRoutes(v1 fiber.Router, userController *controllers.UserController
 	protected.Get("/me", userController.GetMe)
 	protected.Put("/me", userController.UpdateProfile)
 	protected.Put("/me/password", userController.ChangePassword)
+	protected.Get("/me/storage", userController.GetStorageUsage)
 }
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/internal/controllers"
+	"github.com/habbazettt/nutrisnap-server/internal/middleware"
+	"github.com/habbazettt/nutrisnap-server/pkg/jwt"
+)
+
+// SetupWebhookRoutes registers webhook management routes (protected). API
+// clients manage their own webhooks here with their API key.
+func SetupWebhookRoutes(v1 fiber.Router, webhookController *controllers.WebhookController, jwtManager *jwt.Manager, apiClients middleware.APIKeyAuthenticator, idempotency fiber.Handler) {
+	webhooks := v1.Group("/webhooks", middleware.JWTAuth(middleware.AuthConfig{
+		JWTManager: jwtManager,
+		APIClients: apiClients,
+	}))
+
+	webhooks.Post("/", idempotency, webhookController.CreateWebhook)
+	webhooks.Get("/", webhookController.GetWebhooks)
+	webhooks.Get("/:id", webhookController.GetWebhook)
+	webhooks.Put("/:id", webhookController.UpdateWebhook)
+	webhooks.Delete("/:id", webhookController.DeleteWebhook)
+
+	// Delivery log
+	webhooks.Get("/:id/deliveries", webhookController.GetDeliveries)
+	webhooks.Post("/:id/deliveries/:deliveryId/redeliver", webhookController.Redeliver)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/ansrivas/fiberprometheus/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/habbazettt/nutrisnap-server/internal/controllers"
+)
+
+// SetupWorkerRoutes registers the health and metrics endpoints of a
+// worker-only process. The scan pipeline metrics share the default
+// registry, so /metrics exposes them alongside the process metrics.
+func SetupWorkerRoutes(app *fiber.App, controller *controllers.WorkerHealthController) {
+	prometheus := fiberprometheus.New("nutrisnap-worker")
+	prometheus.RegisterAt(app, "/metrics")
+
+	app.Get("/healthz", controller.Liveness)
+	app.Get("/readyz", controller.Readiness)
+
+	app.Use(notFoundHandler)
+}
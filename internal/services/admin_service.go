@@ -1,9 +1,14 @@
 package services
 
 import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
 	"github.com/habbazettt/nutrisnap-server/internal/dto"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/nutrition"
 )
 
 type AdminService interface {
@@ -12,14 +17,33 @@ type AdminService interface {
 	UpdateUserRole(userID string, role models.UserRole) (*models.User, error)
 	DeleteUser(userID string) error
 	GetStats() (*dto.AdminStatsResponse, error)
+	GetDeadLetterScans(page, limit int) ([]models.ScanJob, int64, error)
+	RequeueScan(scanID string) error
+	GetStorageReconciliations(page, limit int) ([]models.StorageReconciliation, int64, error)
+	GetStorageUsers(page, limit int) ([]models.StorageUsage, int64, error)
+	ReparseScans(req dto.ReparseScansRequest, requestedBy string) (*models.ScanReparse, error)
+	GetScanReparse(id string) (*models.ScanReparse, error)
+	GetScanReparses(page, limit int) ([]models.ScanReparse, int64, error)
 }
 
 type adminService struct {
-	userRepo repositories.UserRepository
+	userRepo      repositories.UserRepository
+	scanRepo      repositories.ScanRepository
+	scanJobRepo   repositories.ScanJobRepository
+	reconcileRepo repositories.StorageReconciliationRepository
+	usageRepo     repositories.StorageUsageRepository
+	reparseRepo   repositories.ScanReparseRepository
 }
 
-func NewAdminService(userRepo repositories.UserRepository) AdminService {
-	return &adminService{userRepo: userRepo}
+func NewAdminService(userRepo repositories.UserRepository, scanRepo repositories.ScanRepository, scanJobRepo repositories.ScanJobRepository, reconcileRepo repositories.StorageReconciliationRepository, usageRepo repositories.StorageUsageRepository, reparseRepo repositories.ScanReparseRepository) AdminService {
+	return &adminService{
+		userRepo:      userRepo,
+		scanRepo:      scanRepo,
+		scanJobRepo:   scanJobRepo,
+		reconcileRepo: reconcileRepo,
+		usageRepo:     usageRepo,
+		reparseRepo:   reparseRepo,
+	}
 }
 
 func (s *adminService) GetAllUsers(page, limit int) ([]models.User, int64, error) {
@@ -59,3 +83,75 @@ func (s *adminService) GetStats() (*dto.AdminStatsResponse, error) {
 		TotalUsers: totalUsers,
 	}, nil
 }
+
+func (s *adminService) GetDeadLetterScans(page, limit int) ([]models.ScanJob, int64, error) {
+	offset := (page - 1) * limit
+	return s.scanJobRepo.FindDeadLettered(offset, limit)
+}
+
+func (s *adminService) RequeueScan(scanID string) error {
+	return s.scanJobRepo.Requeue(scanID)
+}
+
+func (s *adminService) GetStorageReconciliations(page, limit int) ([]models.StorageReconciliation, int64, error) {
+	offset := (page - 1) * limit
+	return s.reconcileRepo.FindRecent(offset, limit)
+}
+
+// GetStorageUsers lists users by storage usage, heaviest first
+func (s *adminService) GetStorageUsers(page, limit int) ([]models.StorageUsage, int64, error) {
+	offset := (page - 1) * limit
+	return s.usageRepo.FindHeaviest(offset, limit)
+}
+
+// ReparseScans queues a run of the current parser and scoring over
+// historical scans. A worker carries it out in the background, and dry
+// runs only report what would change.
+func (s *adminService) ReparseScans(req dto.ReparseScansRequest, requestedBy string) (*models.ScanReparse, error) {
+	// Pending and processing scans are about to be read by OCR anyway
+	filter := repositories.ScanFilter{
+		From:          req.From,
+		To:            req.To,
+		Statuses:      []models.ScanStatus{models.ScanStatusCompleted, models.ScanStatusFailed},
+		ParserVersion: req.ParserVersion,
+	}
+	if len(req.Statuses) > 0 {
+		filter.Statuses = make([]models.ScanStatus, len(req.Statuses))
+		for i, status := range req.Statuses {
+			filter.Statuses[i] = models.ScanStatus(status)
+		}
+	}
+	if req.Outdated {
+		current := nutrition.ParserVersion
+		filter.ExcludeParserVersion = &current
+	}
+
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode filter: %w", err)
+	}
+
+	run := &models.ScanReparse{
+		Status:        models.ReparseQueued,
+		DryRun:        req.DryRun == nil || *req.DryRun,
+		ParserVersion: nutrition.ParserVersion,
+		FilterJSON:    filterJSON,
+		Limit:         req.Limit,
+	}
+	if id, err := uuid.Parse(requestedBy); err == nil {
+		run.RequestedBy = &id
+	}
+	if err := s.reparseRepo.Create(run); err != nil {
+		return nil, fmt.Errorf("failed to queue reparse: %w", err)
+	}
+	return run, nil
+}
+
+func (s *adminService) GetScanReparse(id string) (*models.ScanReparse, error) {
+	return s.reparseRepo.FindByID(id)
+}
+
+func (s *adminService) GetScanReparses(page, limit int) ([]models.ScanReparse, int64, error) {
+	offset := (page - 1) * limit
+	return s.reparseRepo.FindRecent(offset, limit)
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+)
+
+// memoryClientRepo keeps API clients in memory
+type memoryClientRepo struct {
+	repositories.APIClientRepository
+	clients map[uuid.UUID]*models.APIClient
+	touched int
+}
+
+func (r *memoryClientRepo) Create(client *models.APIClient) error {
+	client.ID = uuid.New()
+	if r.clients == nil {
+		r.clients = make(map[uuid.UUID]*models.APIClient)
+	}
+	r.clients[client.ID] = client
+	return nil
+}
+
+func (r *memoryClientRepo) FindByID(id string) (*models.APIClient, error) {
+	for _, client := range r.clients {
+		if client.ID.String() == id {
+			return client, nil
+		}
+	}
+	return nil, repositories.ErrAPIClientNotFound
+}
+
+func (r *memoryClientRepo) FindByKeyHash(hash string) (*models.APIClient, error) {
+	for _, client := range r.clients {
+		if client.KeyHash == hash {
+			return client, nil
+		}
+	}
+	return nil, repositories.ErrAPIClientNotFound
+}
+
+func (r *memoryClientRepo) TouchLastUsed(id uuid.UUID, at time.Time) error {
+	r.touched++
+	r.clients[id].LastUsedAt = &at
+	return nil
+}
+
+func (r *memoryClientRepo) Delete(id string) error {
+	client, err := r.FindByID(id)
+	if err != nil {
+		return err
+	}
+	delete(r.clients, client.ID)
+	return nil
+}
+
+func TestAPIClientAuthenticate(t *testing.T) {
+	repo := &memoryClientRepo{}
+	service := NewAPIClientService(repo)
+	userID := uuid.New()
+
+	created, err := service.CreateClient(userID.String(), dto.CreateAPIClientRequest{Name: "Partner"})
+	if err != nil {
+		t.Fatalf("CreateClient() error = %v", err)
+	}
+	if !strings.HasPrefix(created.Key, apiKeyPrefix) || !strings.HasPrefix(created.Key, created.KeyPrefix) {
+		t.Errorf("key %q does not start with %q and its prefix %q", created.Key, apiKeyPrefix, created.KeyPrefix)
+	}
+	for _, client := range repo.clients {
+		if strings.Contains(client.KeyHash, created.Key) {
+			t.Error("the key is stored, want only its hash")
+		}
+	}
+
+	client, err := service.Authenticate(created.Key)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if client.UserID != userID {
+		t.Errorf("client acts for %s, want %s", client.UserID, userID)
+	}
+
+	// Use within a minute of the last is not written again
+	if _, err := service.Authenticate(created.Key); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if repo.touched != 1 {
+		t.Errorf("last use written %d times, want 1", repo.touched)
+	}
+
+	for _, key := range []string{"", "nsk_unknown", created.Key[len(apiKeyPrefix):], created.Key + "0"} {
+		if _, err := service.Authenticate(key); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Authenticate(%q) error = %v, want ErrInvalidAPIKey", key, err)
+		}
+	}
+}
+
+func TestAPIClientDelete(t *testing.T) {
+	repo := &memoryClientRepo{}
+	service := NewAPIClientService(repo)
+	userID := uuid.New()
+
+	created, _ := service.CreateClient(userID.String(), dto.CreateAPIClientRequest{Name: "Partner"})
+
+	if err := service.DeleteClient(created.ID, uuid.NewString()); !errors.Is(err, ErrAPIClientNotOwned) {
+		t.Errorf("DeleteClient() by another user error = %v, want ErrAPIClientNotOwned", err)
+	}
+	if err := service.DeleteClient(created.ID, userID.String()); err != nil {
+		t.Fatalf("DeleteClient() error = %v", err)
+	}
+	if _, err := service.Authenticate(created.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Authenticate() after delete error = %v, want ErrInvalidAPIKey", err)
+	}
+}
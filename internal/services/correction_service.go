@@ -17,12 +17,14 @@ type CorrectionService interface {
 type correctionService struct {
 	correctionRepo repositories.CorrectionRepository
 	scanRepo       repositories.ScanRepository
+	webhooks       WebhookPublisher
 }
 
-func NewCorrectionService(correctionRepo repositories.CorrectionRepository, scanRepo repositories.ScanRepository) CorrectionService {
+func NewCorrectionService(correctionRepo repositories.CorrectionRepository, scanRepo repositories.ScanRepository, webhooks WebhookPublisher) CorrectionService {
 	return &correctionService{
 		correctionRepo: correctionRepo,
 		scanRepo:       scanRepo,
+		webhooks:       webhooks,
 	}
 }
 
@@ -64,6 +66,7 @@ func (s *correctionService) CreateCorrection(ctx context.Context, scanID, userID
 	}
 
 	resp := correction.ToResponse()
+	s.webhooks.Publish(models.WebhookEventCorrectionCreated, &userUUID, resp)
 	return &resp, nil
 }
 
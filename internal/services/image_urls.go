@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+	"github.com/habbazettt/nutrisnap-server/pkg/storage"
+)
+
+// ImageURLSigner turns the image refs stored on scans into URLs clients
+// can fetch. Depending on the backend a ref is already a URL or only an
+// object key.
+type ImageURLSigner struct {
+	Store storage.ObjectStore
+	TTL   time.Duration
+}
+
+// Sign returns a URL for ref, or ref itself if no URL can be made so the
+// response still says which image it was
+func (s ImageURLSigner) Sign(ctx context.Context, ref string) string {
+	url, err := s.Store.SignedURL(ctx, ref, s.TTL)
+	if err != nil {
+		logger.Warn("failed to sign image URL", "ref", ref, "error", err)
+		return ref
+	}
+	return url
+}
+
+// SignScan replaces the refs in a scan response with URLs
+func (s ImageURLSigner) SignScan(ctx context.Context, resp *dto.ScanResponse) {
+	resp.ImageURL = s.signImages(ctx, resp.ImageURL, resp.Images)
+	s.SignVariants(ctx, resp.ImageVariants)
+}
+
+// SignUpload replaces the refs in an upload response with URLs
+func (s ImageURLSigner) SignUpload(ctx context.Context, resp *dto.ScanUploadResponse) {
+	resp.ImageURL = s.signImages(ctx, resp.ImageURL, resp.Images)
+}
+
+// SignVariants replaces the refs in a variant map with URLs to each variant
+func (s ImageURLSigner) SignVariants(ctx context.Context, variants map[string]string) {
+	for name, ref := range variants {
+		url, err := storage.VariantURL(ctx, s.Store, ref, storage.Variant(name), s.TTL)
+		if err != nil {
+			logger.Warn("failed to sign image variant URL", "ref", ref, "variant", name, "error", err)
+			delete(variants, name)
+			continue
+		}
+		variants[name] = url
+	}
+}
+
+func (s ImageURLSigner) signImages(ctx context.Context, imageURL *string, images []dto.ScanImageResponse) *string {
+	for i := range images {
+		images[i].ImageURL = s.Sign(ctx, images[i].ImageURL)
+		s.SignVariants(ctx, images[i].Variants)
+	}
+	if imageURL == nil {
+		return nil
+	}
+	url := s.Sign(ctx, *imageURL)
+	return &url
+}
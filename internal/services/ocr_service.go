@@ -1,78 +1,186 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"net/url"
-	"os"
-	"path/filepath"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/pkg/imagequality"
 	"github.com/habbazettt/nutrisnap-server/pkg/nutrition"
 	"github.com/habbazettt/nutrisnap-server/pkg/ocr"
+	"github.com/habbazettt/nutrisnap-server/pkg/pipeline"
 	"github.com/habbazettt/nutrisnap-server/pkg/storage"
+	"github.com/otiai10/gosseract/v2"
 )
 
+// ErrImageDownload wraps failures fetching an image from storage
+var ErrImageDownload = errors.New("failed to download image")
+
 type OCRService interface {
 	ProcessImageFromStorage(ctx context.Context, imageURL string) (*models.Nutrients, string, string, error)
+	ExtractText(ctx context.Context, imageURL string, kind models.ScanImageKind) (*OCRText, error)
+}
+
+// OCRConfig controls how label images are read
+type OCRConfig struct {
+	// MultiPass runs several OCR configurations on nutrition panels
+	MultiPass bool
+	// MultiPassBudget bounds the total time spent on those passes
+	MultiPassBudget time.Duration
+	// Quality gates images before they are sent to Tesseract
+	Quality QualityConfig
+}
+
+// OCRText is the text read from an image and the strategy that produced it
+type OCRText struct {
+	Text     string
+	Strategy string
+	// Score is how complete and plausible the parsed nutrient table is (0-1).
+	// Only set for nutrition panels.
+	Score *float64
+	// Quality holds the image quality measurements, nil when the gate is off
+	Quality *imagequality.Report
 }
 
 type ocrService struct {
-	storageClient *storage.CloudinaryClient
+	store  storage.ObjectStore
+	pool   *ocr.Pool
+	config OCRConfig
 }
 
-func NewOCRService(storageClient *storage.CloudinaryClient) OCRService {
+func NewOCRService(store storage.ObjectStore, pool *ocr.Pool, config OCRConfig) OCRService {
 	return &ocrService{
-		storageClient: storageClient,
+		store:  store,
+		pool:   pool,
+		config: config,
 	}
 }
 
-// ProcessImageFromStorage downloads image from Cloudinary URL and performs OCR
-func (s *ocrService) ProcessImageFromStorage(ctx context.Context, imageURL string) (*models.Nutrients, string, string, error) {
-	// Parse URL to get file extension
-	parsedURL, err := url.Parse(imageURL)
-	if err != nil {
-		return nil, "", "", fmt.Errorf("invalid image URL: %w", err)
+// settingsForKind picks the OCR strategy for each kind of label photo
+func settingsForKind(kind models.ScanImageKind) ocr.Settings {
+	settings := ocr.DefaultSettings()
+
+	switch kind {
+	case models.ScanImageFront:
+		// Front of pack has scattered large text around graphics
+		settings.PageSegMode = gosseract.PSM_SPARSE_TEXT
+	case models.ScanImageIngredients:
+		// Ingredient lists are a single dense paragraph
+		settings.PageSegMode = gosseract.PSM_SINGLE_BLOCK
 	}
-	ext := filepath.Ext(parsedURL.Path)
-	if ext == "" {
-		ext = ".jpg"
+
+	return settings
+}
+
+// nutritionPasses are the configurations tried on nutrition panels, most
+// likely winners first so a tight budget still covers them. Every pass
+// keeps the engine's default languages, since switching them reloads
+// traineddata and would cost more than the pass itself.
+func nutritionPasses() []ocr.Pass {
+	both := []string{"eng", "ind"}
+	pass := func(name string, langs []string, psm gosseract.PageSegMode, variant ocr.Variant) ocr.Pass {
+		return ocr.Pass{
+			Name:       name,
+			Settings:   ocr.Settings{Languages: langs, PageSegMode: psm},
+			Preprocess: variant,
+		}
 	}
 
-	tmpFile := filepath.Join(os.TempDir(), fmt.Sprintf("ocr-%s%s", uuid.New().String(), ext))
+	return []ocr.Pass{
+		pass("psm3-eng+ind", both, gosseract.PSM_AUTO, ocr.VariantNone),
+		pass("psm6-eng+ind", both, gosseract.PSM_SINGLE_BLOCK, ocr.VariantNone),
+		pass("psm4-eng+ind", both, gosseract.PSM_SINGLE_COLUMN, ocr.VariantNone),
+		pass("psm6-eng+ind-binarize", both, gosseract.PSM_SINGLE_BLOCK, ocr.VariantBinarize),
+		pass("psm11-eng+ind", both, gosseract.PSM_SPARSE_TEXT, ocr.VariantNone),
+		pass("psm4-eng+ind-grayscale", both, gosseract.PSM_SINGLE_COLUMN, ocr.VariantGrayscale),
+	}
+}
 
-	// Create temp file
-	file, err := os.Create(tmpFile)
+// ProcessImageFromStorage downloads a nutrition panel image and parses nutrients from it
+func (s *ocrService) ProcessImageFromStorage(ctx context.Context, imageURL string) (*models.Nutrients, string, string, error) {
+	result, err := s.ExtractText(ctx, imageURL, models.ScanImageNutrition)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to create temp file: %w", err)
+		return nil, "", "", err
 	}
-	defer func() {
-		file.Close()
-		os.Remove(tmpFile) // Clean up
-	}()
 
-	// Download from Cloudinary URL
-	reader, err := s.storageClient.Download(ctx, imageURL)
+	// Use the dedicated nutrition parser package
+	nutrients, servingSize := nutrition.ParseFromText(result.Text)
+	return nutrients, servingSize, result.Text, nil
+}
+
+// download fetches an image from storage into memory
+func (s *ocrService) download(ctx context.Context, imageURL string) ([]byte, error) {
+	defer pipeline.FromContext(ctx).Measure(pipeline.StageDownload)()
+
+	reader, err := s.store.Get(ctx, imageURL)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to get image from Cloudinary: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrImageDownload, err)
 	}
 	defer reader.Close()
 
-	if _, err := io.Copy(file, reader); err != nil {
-		return nil, "", "", fmt.Errorf("failed to write image to temp file: %w", err)
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, reader); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrImageDownload, err)
 	}
+	return buf.Bytes(), nil
+}
 
-	client := ocr.NewClient()
-	defer client.Close()
+// ExtractText downloads an image from storage and performs OCR with
+// the strategy for its kind, using an engine borrowed from the pool.
+// Nutrition panels go through several passes and keep the best table.
+func (s *ocrService) ExtractText(ctx context.Context, imageURL string, kind models.ScanImageKind) (*OCRText, error) {
+	trace := pipeline.FromContext(ctx)
 
-	text, err := client.ProcessImage(tmpFile)
+	data, err := s.download(ctx, imageURL)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("OCR processing failed: %w", err)
+		return nil, err
 	}
 
-	// Use the dedicated nutrition parser package
-	nutrients, servingSize := nutrition.ParseFromText(text)
-	return nutrients, servingSize, text, nil
+	// Images uploaded before the gate existed, or attached by other paths,
+	// are checked here so a blurry photo fails instead of yielding nothing
+	stopPreprocess := trace.Measure(pipeline.StagePreprocess)
+	report, err := assessImage(data, kind, s.config.Quality)
+	stopPreprocess()
+	if err != nil {
+		return &OCRText{Quality: report}, err
+	}
+
+	passes := []ocr.Pass{{
+		Name:     fmt.Sprintf("%s-default", kind),
+		Settings: settingsForKind(kind),
+	}}
+	if kind == models.ScanImageNutrition && s.config.MultiPass {
+		passes = nutritionPasses()
+	}
+
+	start := time.Now()
+	result, err := s.pool.RunPasses(ctx, data, passes, ocr.MultiPassConfig{
+		Budget:     s.config.MultiPassBudget,
+		GoodEnough: 1,
+	}, nutrition.ScoreText)
+	if result != nil {
+		trace.Add(pipeline.StagePreprocess, result.PreprocessTime)
+		trace.Add(pipeline.StageOCR, time.Since(start)-result.PreprocessTime)
+	} else {
+		trace.Add(pipeline.StageOCR, time.Since(start))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("OCR processing failed: %w", err)
+	}
+
+	text := &OCRText{
+		Text:     result.Best.Text,
+		Strategy: result.Best.Pass,
+		Quality:  report,
+	}
+	if kind == models.ScanImageNutrition {
+		score := result.Best.Score
+		text.Score = &score
+	}
+
+	return text, nil
 }
@@ -2,32 +2,86 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
 	"github.com/habbazettt/nutrisnap-server/pkg/openfoodfacts"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// backgroundRefreshTimeout bounds a refresh started by a lookup
+const backgroundRefreshTimeout = 30 * time.Second
+
 type ProductService interface {
+	// GetProductByBarcode returns the cached product, fetching it from Open
+	// Food Facts on the first lookup. Stale products are returned as they
+	// are and refreshed in the background.
 	GetProductByBarcode(ctx context.Context, barcode string) (*models.Product, error)
+	SearchProducts(ctx context.Context, req dto.ProductSearchRequest) (*dto.ProductSearchResponse, error)
+	// RefreshProduct fetches an Open Food Facts product again and reports
+	// whether its nutrients or grade changed
+	RefreshProduct(ctx context.Context, product *models.Product) (bool, error)
+}
+
+// ProductCacheConfig holds Open Food Facts caching configuration
+type ProductCacheConfig struct {
+	// TTL is how long a fetched product is served before it is refreshed,
+	// DefaultProductCacheTTL when not positive
+	TTL time.Duration
+	// MissTTL is how long a barcode OFF did not know is answered as not
+	// found without asking again, 0 to always ask
+	MissTTL time.Duration
+}
+
+// DefaultProductCacheTTL is the cache TTL used when none is configured
+const DefaultProductCacheTTL = 7 * 24 * time.Hour
+
+// productCursor is the opaque cursor handed to clients. It carries the
+// sort it was made for, so it cannot continue a differently sorted search.
+type productCursor struct {
+	Sort repositories.ProductSort `json:"s"`
+	Key  string                   `json:"k"`
+	ID   uuid.UUID                `json:"id"`
 }
 
 type productService struct {
 	productRepo repositories.ProductRepository
 	offClient   *openfoodfacts.Client
+	webhooks    WebhookPublisher
+	cache       ProductCacheConfig
+	// refreshing holds the barcodes with a background refresh running
+	refreshing sync.Map
 }
 
-func NewProductService(productRepo repositories.ProductRepository, offClient *openfoodfacts.Client) ProductService {
+func NewProductService(productRepo repositories.ProductRepository, offClient *openfoodfacts.Client, webhooks WebhookPublisher, cache ProductCacheConfig) ProductService {
+	// Without a TTL every lookup would start a refresh
+	if cache.TTL <= 0 {
+		cache.TTL = DefaultProductCacheTTL
+	}
 	return &productService{
 		productRepo: productRepo,
 		offClient:   offClient,
+		webhooks:    webhooks,
+		cache:       cache,
 	}
 }
 
 func (s *productService) GetProductByBarcode(ctx context.Context, barcode string) (*models.Product, error) {
 	product, err := s.productRepo.FindByBarcode(barcode)
 	if err == nil {
+		if s.isStale(product) {
+			s.refreshInBackground(product)
+		}
 		return product, nil
 	}
 
@@ -36,16 +90,35 @@ func (s *productService) GetProductByBarcode(ctx context.Context, barcode string
 		return nil, err
 	}
 
+	// Barcodes OFF recently did not know are not asked about again
+	if s.cache.MissTTL > 0 {
+		missing, err := s.productRepo.IsMissing(barcode)
+		if err != nil {
+			logger.Warn("failed to check product miss", "barcode", barcode, "error", err)
+		} else if missing {
+			return nil, repositories.ErrProductNotFound
+		}
+	}
+
 	// 2. Fetch from OpenFoodFacts
-	offProduct, err := s.offClient.GetProduct(barcode)
+	result, err := s.offClient.Fetch(ctx, barcode, "")
 	if err != nil {
 		return nil, err // External API error
 	}
 
+	offProduct := result.Product
 	if offProduct == nil {
 		// Not found in OFF
+		if s.cache.MissTTL > 0 {
+			if err := s.productRepo.RecordMiss(barcode, s.cache.MissTTL); err != nil {
+				logger.Warn("failed to record product miss", "barcode", barcode, "error", err)
+			}
+		}
 		return nil, repositories.ErrProductNotFound
 	}
+	fetchedAt := time.Now()
+	offProduct.FetchedAt = &fetchedAt
+	offProduct.ETag = optionalETag(result.ETag)
 
 	// 3. Save to local DB (Cache)
 	if err := s.productRepo.Create(offProduct); err != nil {
@@ -55,3 +128,205 @@ func (s *productService) GetProductByBarcode(ctx context.Context, barcode string
 
 	return offProduct, nil
 }
+
+// SearchProducts finds products by name or brand and nutrient criteria.
+// Results are sorted by relevance when there is a query and by name
+// otherwise.
+func (s *productService) SearchProducts(ctx context.Context, req dto.ProductSearchRequest) (*dto.ProductSearchResponse, error) {
+	search := repositories.ProductSearch{
+		Query:       req.Query,
+		NutriScores: req.NutriScore,
+		Sort:        repositories.ProductSort(req.Sort),
+		Limit:       req.Limit,
+	}
+	if search.Limit == 0 {
+		search.Limit = 20
+	}
+	if search.Sort == "" {
+		search.Sort = repositories.ProductSortRelevance
+	}
+	if search.Sort == repositories.ProductSortRelevance && search.Query == "" {
+		search.Sort = repositories.ProductSortName
+	}
+	for _, source := range req.Source {
+		search.Sources = append(search.Sources, models.ProductSource(source))
+	}
+	for key, r := range req.Nutrients {
+		search.Nutrients = append(search.Nutrients, repositories.NutrientRange{Key: key, Min: r.Min, Max: r.Max})
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeProductCursor(req.Cursor)
+		if err != nil || cursor.Sort != search.Sort {
+			return nil, ErrInvalidCursor
+		}
+		search.After = &repositories.ProductCursor{Key: cursor.Key, ID: cursor.ID}
+	}
+
+	products, next, err := s.productRepo.Search(search)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.ProductSearchResponse{Products: make([]dto.ProductResponse, len(products))}
+	for i := range products {
+		resp.Products[i] = dto.ToProductResponse(&products[i])
+	}
+	if next != nil {
+		cursor := encodeProductCursor(productCursor{Sort: search.Sort, Key: next.Key, ID: next.ID})
+		resp.NextCursor = &cursor
+	}
+	return resp, nil
+}
+
+// RefreshProduct fetches an Open Food Facts product again. Changes to its
+// nutrients or grade are recorded and announced with product.updated.
+// Products OFF no longer knows keep their last known data.
+func (s *productService) RefreshProduct(ctx context.Context, product *models.Product) (bool, error) {
+	var etag string
+	if product.ETag != nil {
+		etag = *product.ETag
+	}
+	result, err := s.offClient.Fetch(ctx, product.Barcode, etag)
+	if err != nil {
+		return false, err
+	}
+
+	fetchedAt := time.Now()
+	if result.NotModified || result.Product == nil {
+		return false, s.productRepo.MarkFetched(product.ID, fetchedAt, product.ETag)
+	}
+
+	fresh := result.Product
+	change := diffProduct(product, fresh)
+
+	product.Name = fresh.Name
+	product.Brand = fresh.Brand
+	product.ImageURL = fresh.ImageURL
+	product.ServingSize = fresh.ServingSize
+	product.NutrientsJSON = fresh.NutrientsJSON
+	product.NutriScore = fresh.NutriScore
+	product.NutriScoreValue = fresh.NutriScoreValue
+	product.FetchedAt = &fetchedAt
+	product.ETag = optionalETag(result.ETag)
+
+	if err := s.productRepo.UpdateWithChange(product, change); err != nil {
+		return false, err
+	}
+	if change == nil {
+		return false, nil
+	}
+
+	// Open Food Facts products are shared, so every subscribed webhook is told
+	s.webhooks.Publish(models.WebhookEventProductUpdated, nil, dto.ToProductResponse(product))
+	return true, nil
+}
+
+// isStale reports whether an Open Food Facts product is past the cache TTL
+func (s *productService) isStale(product *models.Product) bool {
+	if product.Source != models.SourceOpenFoodFacts {
+		return false
+	}
+	return product.FetchedAt == nil || time.Since(*product.FetchedAt) > s.cache.TTL
+}
+
+// refreshInBackground refreshes a copy of the product, so the caller can
+// keep serving the stale one. Only one refresh per barcode runs at a time.
+func (s *productService) refreshInBackground(product *models.Product) {
+	if _, running := s.refreshing.LoadOrStore(product.Barcode, struct{}{}); running {
+		return
+	}
+	stale := *product
+
+	go func() {
+		defer s.refreshing.Delete(stale.Barcode)
+
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundRefreshTimeout)
+		defer cancel()
+
+		if _, err := s.RefreshProduct(ctx, &stale); err != nil {
+			logger.Warn("failed to refresh stale product", "barcode", stale.Barcode, "error", err)
+		}
+	}()
+}
+
+// diffProduct compares the nutrients and grade of a cached product with a
+// fresh copy, and returns nil when neither changed
+func diffProduct(cached, fresh *models.Product) *models.ProductChange {
+	change := &models.ProductChange{ProductID: cached.ID}
+	changed := false
+
+	if normalizeGrade(cached.NutriScore) != normalizeGrade(fresh.NutriScore) {
+		change.NutriScoreBefore = cached.NutriScore
+		change.NutriScoreAfter = fresh.NutriScore
+		changed = true
+	}
+
+	before, after := nutrientValues(cached.NutrientsJSON), nutrientValues(fresh.NutrientsJSON)
+	nutrients := make(map[string]models.NutrientChange)
+	for _, key := range models.NutrientKeys() {
+		old, hadOld := before[key]
+		value, hasNew := after[key]
+		if hadOld == hasNew && old == value {
+			continue
+		}
+		var nutrient models.NutrientChange
+		if hadOld {
+			nutrient.Old = &old
+		}
+		if hasNew {
+			nutrient.New = &value
+		}
+		nutrients[key] = nutrient
+	}
+	if len(nutrients) > 0 {
+		change.NutrientsJSON, _ = json.Marshal(nutrients)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return change
+}
+
+// normalizeGrade makes OFF's lowercase grades comparable with local ones
+func normalizeGrade(grade *string) string {
+	if grade == nil {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimSpace(*grade))
+}
+
+// nutrientValues reads the nutrients a product has values for
+func nutrientValues(data models.JSON) map[string]float64 {
+	values := make(map[string]float64)
+	if len(data) > 0 {
+		_ = json.Unmarshal(data, &values)
+	}
+	return values
+}
+
+func optionalETag(etag string) *string {
+	if etag == "" {
+		return nil
+	}
+	return &etag
+}
+
+func encodeProductCursor(cursor productCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProductCursor(value string) (*productCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor productCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
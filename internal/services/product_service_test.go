@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+)
+
+// searchRepo records the searches it is asked for and returns next as the
+// cursor of the following page
+type searchRepo struct {
+	repositories.ProductRepository
+	searches []repositories.ProductSearch
+	next     *repositories.ProductCursor
+}
+
+func (r *searchRepo) Search(search repositories.ProductSearch) ([]models.Product, *repositories.ProductCursor, error) {
+	r.searches = append(r.searches, search)
+	return []models.Product{{Name: "Teh Botol"}}, r.next, nil
+}
+
+func TestProductCursorRoundTrip(t *testing.T) {
+	cursors := []productCursor{
+		{Sort: repositories.ProductSortRelevance, Key: "0.0607927", ID: uuid.New()},
+		{Sort: repositories.ProductSortName, Key: "teh botol, \"less sugar\"", ID: uuid.New()},
+		{Sort: repositories.ProductSortNutriScore, Key: "F", ID: uuid.New()},
+		{Sort: repositories.ProductSortNewest, Key: "2026-10-18 09:30:00.123456+00", ID: uuid.New()},
+	}
+
+	for _, cursor := range cursors {
+		t.Run(string(cursor.Sort), func(t *testing.T) {
+			encoded := encodeProductCursor(cursor)
+			if _, err := base64.RawURLEncoding.DecodeString(encoded); err != nil {
+				t.Fatalf("cursor %q is not URL-safe base64: %v", encoded, err)
+			}
+
+			decoded, err := decodeProductCursor(encoded)
+			if err != nil {
+				t.Fatalf("decodeProductCursor() error = %v", err)
+			}
+			if *decoded != cursor {
+				t.Errorf("decodeProductCursor() = %+v, want %+v", *decoded, cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeProductCursorInvalid(t *testing.T) {
+	tests := map[string]string{
+		"not base64":   "%%%",
+		"not json":     base64.RawURLEncoding.EncodeToString([]byte("name|abc")),
+		"bad id":       base64.RawURLEncoding.EncodeToString([]byte(`{"s":"name","k":"a","id":"nope"}`)),
+		"padded":       base64.URLEncoding.EncodeToString([]byte(`{"s":"name","k":"a"}`)),
+		"standard b64": "eyJzIjoibmFtZSIsImsiOiI/In0+",
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeProductCursor(value); err == nil {
+				t.Error("decodeProductCursor() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestSearchProductsCursor(t *testing.T) {
+	next := &repositories.ProductCursor{Key: "teh botol", ID: uuid.New()}
+	repo := &searchRepo{next: next}
+	service := NewProductService(repo, nil, nil, ProductCacheConfig{})
+	ctx := context.Background()
+
+	first, err := service.SearchProducts(ctx, dto.ProductSearchRequest{Sort: "name"})
+	if err != nil {
+		t.Fatalf("SearchProducts() error = %v", err)
+	}
+	if first.NextCursor == nil {
+		t.Fatal("SearchProducts() NextCursor = nil, want a cursor")
+	}
+
+	repo.next = nil
+	second, err := service.SearchProducts(ctx, dto.ProductSearchRequest{Sort: "name", Cursor: *first.NextCursor})
+	if err != nil {
+		t.Fatalf("SearchProducts() with cursor error = %v", err)
+	}
+	if second.NextCursor != nil {
+		t.Error("NextCursor on the last page, want nil")
+	}
+	if after := repo.searches[1].After; after == nil || *after != *next {
+		t.Errorf("search continued after %+v, want %+v", after, next)
+	}
+
+	// A cursor only continues the sort it was made for
+	_, err = service.SearchProducts(ctx, dto.ProductSearchRequest{Sort: "newest", Cursor: *first.NextCursor})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("SearchProducts() with another sort error = %v, want ErrInvalidCursor", err)
+	}
+	_, err = service.SearchProducts(ctx, dto.ProductSearchRequest{Sort: "name", Cursor: "garbage!"})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("SearchProducts() with garbage cursor error = %v, want ErrInvalidCursor", err)
+	}
+	if len(repo.searches) != 2 {
+		t.Errorf("searches = %d, invalid cursors must not reach the repository", len(repo.searches))
+	}
+}
+
+func TestSearchProductsDefaultSort(t *testing.T) {
+	tests := []struct {
+		req  dto.ProductSearchRequest
+		want repositories.ProductSort
+	}{
+		{dto.ProductSearchRequest{Query: "teh"}, repositories.ProductSortRelevance},
+		{dto.ProductSearchRequest{}, repositories.ProductSortName},
+		{dto.ProductSearchRequest{Sort: "relevance"}, repositories.ProductSortName},
+		{dto.ProductSearchRequest{Sort: "newest"}, repositories.ProductSortNewest},
+	}
+	for _, tt := range tests {
+		repo := &searchRepo{}
+		if _, err := NewProductService(repo, nil, nil, ProductCacheConfig{}).SearchProducts(context.Background(), tt.req); err != nil {
+			t.Fatalf("SearchProducts() error = %v", err)
+		}
+		if got := repo.searches[0].Sort; got != tt.want {
+			t.Errorf("SearchProducts(%+v) sorted by %s, want %s", tt.req, got, tt.want)
+		}
+		if got := repo.searches[0].Limit; got != 20 {
+			t.Errorf("default limit = %d, want 20", got)
+		}
+	}
+}
+
+func TestProductCacheTTLDefault(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Hour} {
+		service := NewProductService(&searchRepo{}, nil, nil, ProductCacheConfig{TTL: ttl}).(*productService)
+		if service.cache.TTL != DefaultProductCacheTTL {
+			t.Errorf("TTL %s became %s, want %s", ttl, service.cache.TTL, DefaultProductCacheTTL)
+		}
+
+		fetchedAt := time.Now().Add(-time.Minute)
+		product := &models.Product{Source: models.SourceOpenFoodFacts, FetchedAt: &fetchedAt}
+		if service.isStale(product) {
+			t.Errorf("with TTL %s a product fetched a minute ago is stale", ttl)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/pkg/imageguard"
+	"github.com/habbazettt/nutrisnap-server/pkg/imagequality"
+)
+
+var ErrInvalidImage = errors.New("invalid image")
+
+// QualityConfig controls the image quality gate
+type QualityConfig struct {
+	Enabled    bool
+	Thresholds imagequality.Thresholds
+	// Limits are checked on every upload, even with the gate disabled
+	Limits imageguard.Limits
+}
+
+// assessImage measures an image and returns an *imagequality.Error when it
+// is not worth sending to OCR. Text density is only required on panels
+// that are mostly text.
+func assessImage(data []byte, kind models.ScanImageKind, config QualityConfig) (*imagequality.Report, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	checkText := kind == models.ScanImageNutrition || kind == models.ScanImageIngredients
+	report, err := imagequality.Assess(data, config.Thresholds, checkText)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	if !report.Usable() {
+		return report, &imagequality.Error{Report: report}
+	}
+
+	return report, nil
+}
+
+// qualityJSON marshals a quality report for storage
+func qualityJSON(report *imagequality.Report) models.JSON {
+	if report == nil {
+		return nil
+	}
+	data, _ := json.Marshal(report)
+	return data
+}
+
+// prepareUpload buffers an upload in memory, checks what the file really
+// is and runs the quality gate on it, so unsafe files and unusable photos
+// are rejected before they reach storage. The declared content type is
+// replaced by the sniffed one, and GPS data is stripped.
+func prepareUpload(image *ImageUpload, config QualityConfig) error {
+	data, err := io.ReadAll(image.File)
+	if err != nil {
+		return fmt.Errorf("failed to read %s image: %w", image.Kind, err)
+	}
+
+	// Only the header is decoded, so oversized images are refused before
+	// the quality gate decodes their pixels
+	checked, err := imageguard.Inspect(data, config.Limits)
+	if err != nil {
+		return err
+	}
+	data = checked.Data
+	image.ContentType = checked.Format.ContentType()
+
+	image.File = bytes.NewReader(data)
+	image.data = data
+	image.Size = int64(len(data))
+
+	report, err := assessImage(data, image.Kind, config)
+	if err != nil {
+		return err
+	}
+	image.quality = report
+	return nil
+}
@@ -2,46 +2,95 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/habbazettt/nutrisnap-server/internal/dto"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/imagequality"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
 	"github.com/habbazettt/nutrisnap-server/pkg/storage"
 )
 
+var (
+	ErrScanNotOwned   = errors.New("unauthorized: scan does not belong to user")
+	ErrTooManyImages  = errors.New("too many images for this scan")
+	ErrScanProcessing = errors.New("scan is still processing")
+	ErrNoOCRText      = errors.New("scan has no stored OCR text to re-parse")
+	ErrNoStoredImage  = errors.New("no image stored for this scan")
+)
+
+// ImageUpload is a single typed image file received for a scan
+type ImageUpload struct {
+	Kind models.ScanImageKind
+	File io.Reader
+	Size int64
+	// ContentType is replaced by the format sniffed from the file
+	ContentType string
+
+	quality *imagequality.Report
+	// data is the checked file, kept to render variants from
+	data []byte
+}
+
 type ScanService interface {
-	CreateScan(ctx context.Context, userID string, file io.Reader, filename string, fileSize int64, contentType string, storeImage bool, barcode *string) (*dto.ScanUploadResponse, error)
-	GetScanByID(ctx context.Context, id string) (*dto.ScanResponse, error)
+	CreateScan(ctx context.Context, userID string, images []ImageUpload, storeImage bool, barcode *string) (*dto.ScanUploadResponse, error)
+	AttachImages(ctx context.Context, scanID string, userID string, images []ImageUpload) (*dto.ScanResponse, error)
+	ReprocessScan(ctx context.Context, scanID string, userID string) (*dto.ScanReprocessResponse, error)
+	GetScanByID(ctx context.Context, id string, userID string) (*dto.ScanResponse, error)
 	GetUserScans(ctx context.Context, userID string, page, limit int) (*dto.PaginatedScansResponse, error)
 	DeleteScan(ctx context.Context, id string, userID string) error
-	GetScanImageURL(ctx context.Context, scanID string) (string, error)
+	GetScanImageURL(ctx context.Context, scanID string, userID string) (*dto.ScanImageURLResponse, error)
+	CreateUploadURL(ctx context.Context, userID string, req dto.CreateUploadURLRequest) (*dto.UploadURLResponse, error)
+	CommitUpload(ctx context.Context, scanID string, userID string) (*dto.ScanUploadResponse, error)
 }
 
 type ScanQueue interface {
-	EnqueueScan(scanID string)
+	EnqueueScan(scanID string, priority models.ScanPriority)
+	QueuePosition(scanID string) (*dto.QueuePosition, error)
+}
+
+// ScanReparser runs the current parser and scoring over stored OCR text
+type ScanReparser interface {
+	ReparseScan(ctx context.Context, scan *models.Scan, dryRun bool) (*dto.ScanReparseResult, error)
 }
 
 type scanService struct {
 	scanRepo       repositories.ScanRepository
-	storageClient  *storage.CloudinaryClient
+	scanImageRepo  repositories.ScanImageRepository
+	scanUploadRepo repositories.ScanUploadRepository
+	store          storage.ObjectStore
+	imageURLs      ImageURLSigner
+	uploadURLTTL   time.Duration
 	productService ProductService
 	scanQueue      ScanQueue
+	reparser       ScanReparser
+	quality        QualityConfig
+	quota          StorageQuotaService
 }
 
-func NewScanService(scanRepo repositories.ScanRepository, storageClient *storage.CloudinaryClient, productService ProductService, scanQueue ScanQueue) ScanService {
+func NewScanService(scanRepo repositories.ScanRepository, scanImageRepo repositories.ScanImageRepository, scanUploadRepo repositories.ScanUploadRepository, store storage.ObjectStore, signedURLTTL, uploadURLTTL time.Duration, productService ProductService, scanQueue ScanQueue, reparser ScanReparser, quality QualityConfig, quota StorageQuotaService) ScanService {
 	return &scanService{
 		scanRepo:       scanRepo,
-		storageClient:  storageClient,
+		scanImageRepo:  scanImageRepo,
+		scanUploadRepo: scanUploadRepo,
+		store:          store,
+		imageURLs:      ImageURLSigner{Store: store, TTL: signedURLTTL},
+		uploadURLTTL:   uploadURLTTL,
 		productService: productService,
 		scanQueue:      scanQueue,
+		reparser:       reparser,
+		quality:        quality,
+		quota:          quota,
 	}
 }
 
-func (s *scanService) CreateScan(ctx context.Context, userID string, file io.Reader, filename string, fileSize int64, contentType string, storeImage bool, barcode *string) (*dto.ScanUploadResponse, error) {
+func (s *scanService) CreateScan(ctx context.Context, userID string, images []ImageUpload, storeImage bool, barcode *string) (*dto.ScanUploadResponse, error) {
 	// Parse user ID
 	uid, err := uuid.Parse(userID)
 	if err != nil {
@@ -56,68 +105,315 @@ func (s *scanService) CreateScan(ctx context.Context, userID string, file io.Rea
 		ImageStored: storeImage,
 	}
 
-	// Upload image to Cloudinary if storeImage is true
-	// ImageRef now stores the public Cloudinary URL directly
-	var imageURL *string
-	if storeImage && file != nil {
-		// Generate unique object name
-		ext := filepath.Ext(filename)
-		objectName := fmt.Sprintf("scans/%s/%s%s", userID, uuid.New().String(), ext)
+	if len(images) > dto.MaxImagesPerScan {
+		return nil, ErrTooManyImages
+	}
 
-		// Upload to Cloudinary - returns public URL directly
-		url, err := s.storageClient.Upload(ctx, objectName, file, fileSize, contentType)
-		if err != nil {
-			return nil, fmt.Errorf("failed to upload image: %w", err)
+	// Reject unusable photos before anything is stored
+	for i := range images {
+		if err := prepareUpload(&images[i], s.quality); err != nil {
+			return nil, err
 		}
-
-		imageURL = &url
-		scan.ImageRef = imageURL // Store the full Cloudinary URL
 	}
+	scan.QualityJSON = qualityJSON(primaryQuality(images))
 
-	// Fast-Path: If barcode is provided, try to find product immediately
-	var productID *uuid.UUID
-	if barcode != nil && *barcode != "" {
-		product, err := s.productService.GetProductByBarcode(ctx, *barcode)
-		if err == nil && product != nil {
-			productID = &product.ID
-			scan.ProductID = productID
-			scan.Status = models.ScanStatusCompleted // Fast-path success!
+	// Upload images to storage if storeImage is true
+	// ImageRef stores the storage ref of the primary image
+	var imageURL *string
+	if storeImage {
+		if err := s.reserveStorage(ctx, uid, images, uuid.Nil); err != nil {
+			return nil, err
+		}
+		for _, image := range images {
+			scanImage, err := s.uploadImage(ctx, userID, image)
+			if err != nil {
+				s.discardUploads(ctx, scan.Images)
+				s.releaseStorage(uid, images)
+				return nil, err
+			}
+			scan.Images = append(scan.Images, *scanImage)
 		}
-		// If fails, we continue as pending (fallback to OCR)
+		imageURL = primaryImageRef(scan.Images)
+		scan.ImageRef = imageURL
 	}
 
+	// Fast-Path: If barcode is provided, try to find product immediately
+	s.matchBarcode(ctx, scan)
+
 	// Save scan to database
 	if err := s.scanRepo.Create(scan); err != nil {
-		// TODO: Cleanup uploaded file if database save fails
+		// Nothing references the uploads without the scan
+		s.discardUploads(ctx, scan.Images)
+		if storeImage {
+			s.releaseStorage(uid, images)
+		}
 		return nil, fmt.Errorf("failed to create scan: %w", err)
 	}
 
 	// Enqueue for OCR if pending and image is available
-	if scan.Status == models.ScanStatusPending && scan.ImageStored && scan.ImageRef != nil {
-		// Asynchronous enqueue
-		if s.scanQueue != nil {
-			s.scanQueue.EnqueueScan(scan.ID.String())
-		}
-	}
+	s.enqueueNewScan(scan)
 
-	return &dto.ScanUploadResponse{
+	resp := &dto.ScanUploadResponse{
 		ID:        scan.ID.String(),
 		Status:    scan.Status,
 		ImageURL:  imageURL,
+		Images:    dto.ToScanImageResponses(scan.Images),
 		Message:   "Scan created successfully",
 		CreatedAt: scan.CreatedAt,
+	}
+	s.imageURLs.SignUpload(ctx, resp)
+
+	return resp, nil
+}
+
+// AttachImages adds more typed images to an existing scan and queues it
+// to be processed again with all of its images
+func (s *scanService) AttachImages(ctx context.Context, scanID string, userID string, images []ImageUpload) (*dto.ScanResponse, error) {
+	scan, err := s.scanRepo.FindByID(scanID)
+	if err != nil {
+		return nil, err
+	}
+
+	if scan.UserID != nil && scan.UserID.String() != userID {
+		return nil, ErrScanNotOwned
+	}
+
+	if scan.IsProcessing() {
+		return nil, ErrScanProcessing
+	}
+
+	if len(scan.Images)+len(images) > dto.MaxImagesPerScan {
+		return nil, ErrTooManyImages
+	}
+
+	for i := range images {
+		if err := prepareUpload(&images[i], s.quality); err != nil {
+			return nil, err
+		}
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+	if err := s.reserveStorage(ctx, uid, images, scan.ID); err != nil {
+		return nil, err
+	}
+
+	var added []models.ScanImage
+	for _, image := range images {
+		scanImage, err := s.uploadImage(ctx, userID, image)
+		if err != nil {
+			s.discardUploads(ctx, added)
+			s.releaseStorage(uid, images)
+			return nil, err
+		}
+		scanImage.ScanID = scan.ID
+		added = append(added, *scanImage)
+	}
+
+	scan.ImageStored = true
+	if scan.ImageRef == nil {
+		scan.ImageRef = primaryImageRef(append(slices.Clone(scan.Images), added...))
+		scan.QualityJSON = qualityJSON(primaryQuality(images))
+	}
+	scan.Status = models.ScanStatusPending
+	scan.ErrorCode = nil
+	scan.ErrorMessage = nil
+
+	if err := s.scanRepo.AddImages(scan, added); err != nil {
+		s.discardUploads(ctx, added)
+		s.releaseStorage(uid, images)
+		return nil, fmt.Errorf("failed to save scan images: %w", err)
+	}
+
+	if s.scanQueue != nil {
+		s.scanQueue.EnqueueScan(scan.ID.String(), models.ScanPriorityInteractive)
+	}
+
+	resp := dto.ToScanResponse(scan, scan.ImageRef)
+	s.imageURLs.SignScan(ctx, &resp)
+	return &resp, nil
+}
+
+// ReprocessScan reads a scan again with the current pipeline. Scans whose
+// images are still stored go back through OCR; otherwise the stored OCR
+// text is re-parsed right away.
+func (s *scanService) ReprocessScan(ctx context.Context, scanID string, userID string) (*dto.ScanReprocessResponse, error) {
+	scan, err := s.scanRepo.FindByID(scanID)
+	if err != nil {
+		return nil, err
+	}
+
+	if scan.UserID != nil && scan.UserID.String() != userID {
+		return nil, ErrScanNotOwned
+	}
+
+	if scan.IsProcessing() {
+		return nil, ErrScanProcessing
+	}
+
+	if scan.ImageStored && scan.HasImages() && s.scanQueue != nil {
+		scan.Status = models.ScanStatusPending
+		scan.ErrorCode = nil
+		scan.ErrorMessage = nil
+		if err := s.scanRepo.Update(scan); err != nil {
+			return nil, fmt.Errorf("failed to update scan: %w", err)
+		}
+		s.scanQueue.EnqueueScan(scan.ID.String(), models.ScanPriorityReprocess)
+
+		return &dto.ScanReprocessResponse{
+			ID:      scan.ID.String(),
+			Mode:    "ocr",
+			Status:  scan.Status,
+			Message: "Scan queued for OCR",
+		}, nil
+	}
+
+	result, err := s.reparser.ReparseScan(ctx, scan, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ScanReprocessResponse{
+		ID:      scan.ID.String(),
+		Mode:    "reparse",
+		Status:  scan.Status,
+		Reparse: result,
+		Message: "Scan re-parsed from stored OCR text",
 	}, nil
 }
 
-func (s *scanService) GetScanByID(ctx context.Context, id string) (*dto.ScanResponse, error) {
+// matchBarcode is the fast path for scans with a barcode: a known product
+// completes the scan without OCR. Otherwise it stays pending.
+func (s *scanService) matchBarcode(ctx context.Context, scan *models.Scan) {
+	if scan.Barcode == nil || *scan.Barcode == "" {
+		return
+	}
+	product, err := s.productService.GetProductByBarcode(ctx, *scan.Barcode)
+	if err == nil && product != nil {
+		scan.ProductID = &product.ID
+		scan.Status = models.ScanStatusCompleted
+	}
+}
+
+// enqueueNewScan queues a saved scan for OCR if it is pending and has an image
+func (s *scanService) enqueueNewScan(scan *models.Scan) {
+	if scan.Status == models.ScanStatusPending && scan.HasImages() && s.scanQueue != nil {
+		s.scanQueue.EnqueueScan(scan.ID.String(), models.ScanPriorityInteractive)
+	}
+}
+
+// reserveStorage adds images about to be stored to the user's usage if
+// they fit the storage quota
+func (s *scanService) reserveStorage(ctx context.Context, userID uuid.UUID, images []ImageUpload, keepScanID uuid.UUID) error {
+	return s.quota.Reserve(ctx, userID, uploadSize(images), len(images), keepScanID)
+}
+
+// releaseStorage gives back what reserveStorage held for images that
+// were not stored after all
+func (s *scanService) releaseStorage(userID uuid.UUID, images []ImageUpload) {
+	s.quota.Release(userID, uploadSize(images), len(images))
+}
+
+func uploadSize(images []ImageUpload) int64 {
+	var bytes int64
+	for _, image := range images {
+		bytes += image.Size
+	}
+	return bytes
+}
+
+// uploadImage stores one image and returns its scan image record
+func (s *scanService) uploadImage(ctx context.Context, userID string, image ImageUpload) (*models.ScanImage, error) {
+	// Generate unique object name, with the extension of the sniffed format
+	// rather than the client's filename
+	objectName := fmt.Sprintf("scans/%s/%s%s", userID, uuid.New().String(), uploadExtensions[image.ContentType])
+
+	ref, err := s.store.Put(ctx, objectName, image.File, image.Size, image.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s image: %w", image.Kind, err)
+	}
+
+	// Clients fall back to the full image, so a failure here is not fatal
+	hasVariants := true
+	if err := storage.StoreVariants(ctx, s.store, ref, image.data); err != nil {
+		logger.Warn("failed to store scan image variants", "ref", ref, "error", err)
+		hasVariants = false
+	}
+
+	return &models.ScanImage{
+		Kind:        image.Kind,
+		ImageRef:    ref,
+		ContentType: image.ContentType,
+		SizeBytes:   image.Size,
+		HasVariants: hasVariants,
+		QualityJSON: qualityJSON(image.quality),
+	}, nil
+}
+
+// discardUploads deletes images stored for a request that failed before
+// anything referenced them. Storage reconciliation removes any that cannot
+// be deleted here once they are past the grace period.
+func (s *scanService) discardUploads(ctx context.Context, images []models.ScanImage) {
+	// Clean up even when the client has gone away
+	ctx = context.WithoutCancel(ctx)
+	for _, image := range images {
+		if err := storage.DeleteImage(ctx, s.store, image.ImageRef); err != nil {
+			logger.Warn("failed to discard uploaded scan image", "ref", image.ImageRef, "error", err)
+		}
+	}
+}
+
+// primaryQuality returns the quality report of the image used as ImageRef
+func primaryQuality(images []ImageUpload) *imagequality.Report {
+	if len(images) == 0 {
+		return nil
+	}
+	for _, image := range images {
+		if image.Kind == models.ScanImageNutrition {
+			return image.quality
+		}
+	}
+	return images[0].quality
+}
+
+// primaryImageRef picks the nutrition panel, or the first image when there is none
+func primaryImageRef(images []models.ScanImage) *string {
+	if len(images) == 0 {
+		return nil
+	}
+	for _, image := range images {
+		if image.Kind == models.ScanImageNutrition {
+			ref := image.ImageRef
+			return &ref
+		}
+	}
+	ref := images[0].ImageRef
+	return &ref
+}
+
+func (s *scanService) GetScanByID(ctx context.Context, id string, userID string) (*dto.ScanResponse, error) {
 	scan, err := s.scanRepo.FindByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// ImageRef now stores the full Cloudinary URL directly
-	// No presigned URL generation needed
+	// The response carries URLs to the user's photos
+	if scan.UserID != nil && scan.UserID.String() != userID {
+		return nil, ErrScanNotOwned
+	}
+
 	resp := dto.ToScanResponse(scan, scan.ImageRef)
+	s.imageURLs.SignScan(ctx, &resp)
+
+	if scan.Status == models.ScanStatusPending && s.scanQueue != nil {
+		// A missing job only means the scan is not queued right now
+		if position, err := s.scanQueue.QueuePosition(scan.ID.String()); err == nil {
+			resp.Queue = position
+		}
+	}
+
 	return &resp, nil
 }
 
@@ -130,8 +426,8 @@ func (s *scanService) GetUserScans(ctx context.Context, userID string, page, lim
 
 	scanResponses := make([]dto.ScanResponse, len(scans))
 	for i, scan := range scans {
-		// ImageRef now stores the full Cloudinary URL directly
 		scanResponses[i] = dto.ToScanResponse(&scan, scan.ImageRef)
+		s.imageURLs.SignScan(ctx, &scanResponses[i])
 	}
 
 	totalPages := int(total) / limit
@@ -157,31 +453,69 @@ func (s *scanService) DeleteScan(ctx context.Context, id string, userID string)
 
 	// Check ownership
 	if scan.UserID != nil && scan.UserID.String() != userID {
-		return fmt.Errorf("unauthorized: scan does not belong to user")
+		return ErrScanNotOwned
 	}
 
-	// Delete image from Cloudinary if exists
-	if scan.ImageRef != nil && scan.ImageStored {
-		if err := s.storageClient.Delete(ctx, *scan.ImageRef); err != nil {
-			// Log error but continue with deletion
-			fmt.Printf("Warning: failed to delete image from Cloudinary: %v\n", err)
-		}
+	// Records go first, so a failure leaves the scan whole. Images left
+	// behind by a failed object delete are removed by reconciliation.
+	if err := s.scanRepo.Delete(id); err != nil {
+		return err
 	}
 
-	// Delete scan record
-	return s.scanRepo.Delete(id)
+	usedBytes, usedObjects := scan.StoredUsage()
+	for _, ref := range imageRefs(scan) {
+		if err := storage.DeleteImage(ctx, s.store, ref); err != nil {
+			logger.Warn("failed to delete scan image", "scan_id", id, "ref", ref, "error", err)
+		}
+	}
+	if scan.UserID != nil {
+		s.quota.Record(*scan.UserID, -usedBytes, -usedObjects)
+	}
+	return nil
 }
 
-func (s *scanService) GetScanImageURL(ctx context.Context, scanID string) (string, error) {
+// GetScanImageURL issues a URL to the scan's primary image that stops
+// working after the configured expiry
+func (s *scanService) GetScanImageURL(ctx context.Context, scanID string, userID string) (*dto.ScanImageURLResponse, error) {
 	scan, err := s.scanRepo.FindByID(scanID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if scan.UserID != nil && scan.UserID.String() != userID {
+		return nil, ErrScanNotOwned
 	}
 
 	if scan.ImageRef == nil || !scan.ImageStored {
-		return "", fmt.Errorf("no image stored for this scan")
+		return nil, ErrNoStoredImage
 	}
 
-	// ImageRef is already the public Cloudinary URL
-	return *scan.ImageRef, nil
+	expiresAt := time.Now().Add(s.imageURLs.TTL)
+	url, err := s.store.SignedURL(ctx, *scan.ImageRef, s.imageURLs.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign image URL: %w", err)
+	}
+
+	return &dto.ScanImageURLResponse{
+		ImageURL:  url,
+		ExpiresAt: expiresAt,
+		ExpiresIn: int(s.imageURLs.TTL.Seconds()),
+	}, nil
+}
+
+// imageRefs returns every stored image of a scan without duplicates
+func imageRefs(scan *models.Scan) []string {
+	var refs []string
+	seen := make(map[string]bool)
+	if scan.ImageRef != nil && scan.ImageStored {
+		refs = append(refs, *scan.ImageRef)
+		seen[*scan.ImageRef] = true
+	}
+	for _, image := range scan.Images {
+		if !seen[image.ImageRef] {
+			refs = append(refs, image.ImageRef)
+			seen[image.ImageRef] = true
+		}
+	}
+	return refs
 }
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/storage"
+)
+
+// deleteScanRepo serves one scan and fails Delete with deleteErr
+type deleteScanRepo struct {
+	repositories.ScanRepository
+	scan      *models.Scan
+	deleteErr error
+	deleted   bool
+}
+
+func (r *deleteScanRepo) FindByID(id string) (*models.Scan, error) {
+	return r.scan, nil
+}
+
+func (r *deleteScanRepo) Delete(id string) error {
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	r.deleted = true
+	return nil
+}
+
+// recordingStore records deleted objects and fails deletes with deleteErr
+type recordingStore struct {
+	storage.ObjectStore
+	deleted   []string
+	deleteErr error
+}
+
+func (s *recordingStore) Delete(ctx context.Context, ref string) error {
+	s.deleted = append(s.deleted, ref)
+	return s.deleteErr
+}
+
+// recordingQuota records usage changes
+type recordingQuota struct {
+	StorageQuotaService
+	bytes   int64
+	objects int
+}
+
+func (q *recordingQuota) Record(userID uuid.UUID, bytes int64, objects int) {
+	q.bytes += bytes
+	q.objects += objects
+}
+
+func ownedScan(userID uuid.UUID) *models.Scan {
+	ref := "scans/a.jpg"
+	scan := &models.Scan{UserID: &userID, ImageRef: &ref, ImageStored: true}
+	scan.Images = []models.ScanImage{{ImageRef: ref, SizeBytes: 1000}}
+	scan.ID = uuid.New()
+	return scan
+}
+
+func TestDeleteScanKeepsImagesWhenRecordsFail(t *testing.T) {
+	userID := uuid.New()
+	repo := &deleteScanRepo{scan: ownedScan(userID), deleteErr: errors.New("connection reset")}
+	store := &recordingStore{}
+	quota := &recordingQuota{}
+	service := &scanService{scanRepo: repo, store: store, quota: quota}
+
+	if err := service.DeleteScan(context.Background(), repo.scan.ID.String(), userID.String()); err == nil {
+		t.Fatal("DeleteScan() error = nil, want the repository error")
+	}
+	if len(store.deleted) != 0 {
+		t.Errorf("deleted objects %v although the scan was kept", store.deleted)
+	}
+	if quota.bytes != 0 || quota.objects != 0 {
+		t.Errorf("usage changed by %d bytes and %d objects, want unchanged", quota.bytes, quota.objects)
+	}
+}
+
+func TestDeleteScanToleratesStorageFailures(t *testing.T) {
+	userID := uuid.New()
+	repo := &deleteScanRepo{scan: ownedScan(userID)}
+	store := &recordingStore{deleteErr: errors.New("bucket unavailable")}
+	quota := &recordingQuota{}
+	service := &scanService{scanRepo: repo, store: store, quota: quota}
+
+	if err := service.DeleteScan(context.Background(), repo.scan.ID.String(), userID.String()); err != nil {
+		t.Fatalf("DeleteScan() error = %v, storage failures are left to reconciliation", err)
+	}
+	if !repo.deleted {
+		t.Error("scan records were not deleted")
+	}
+	if len(store.deleted) == 0 || store.deleted[0] != "scans/a.jpg" {
+		t.Errorf("deleted objects = %v, want the scan image attempted", store.deleted)
+	}
+	if quota.bytes != -1000 || quota.objects != -1 {
+		t.Errorf("usage changed by %d bytes and %d objects, want -1000 and -1", quota.bytes, quota.objects)
+	}
+}
+
+func TestDeleteScanNotOwned(t *testing.T) {
+	repo := &deleteScanRepo{scan: ownedScan(uuid.New())}
+	store := &recordingStore{}
+	service := &scanService{scanRepo: repo, store: store}
+
+	err := service.DeleteScan(context.Background(), repo.scan.ID.String(), uuid.NewString())
+	if !errors.Is(err, ErrScanNotOwned) {
+		t.Errorf("DeleteScan() error = %v, want ErrScanNotOwned", err)
+	}
+	if repo.deleted || len(store.deleted) != 0 {
+		t.Error("another user's scan was deleted")
+	}
+}
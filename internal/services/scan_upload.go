@@ -0,0 +1,248 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+	"github.com/habbazettt/nutrisnap-server/pkg/storage"
+)
+
+var (
+	ErrDirectUploadUnsupported = errors.New("storage backend does not support direct uploads")
+	ErrUploadMissing           = errors.New("nothing has been uploaded for this scan yet")
+	ErrUploadMismatch          = errors.New("uploaded file does not match the declared file")
+	ErrUploadExpired           = errors.New("upload target has expired")
+)
+
+// uploadExtensions names stored objects by their declared content type
+var uploadExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// CreateUploadURL reserves a scan ID and returns a target the client
+// uploads the image to directly, for backends that support it
+func (s *scanService) CreateUploadURL(ctx context.Context, userID string, req dto.CreateUploadURLRequest) (*dto.UploadURLResponse, error) {
+	uploader, ok := s.store.(storage.DirectUploader)
+	if !ok {
+		return nil, ErrDirectUploadUnsupported
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	kind := models.ScanImageNutrition
+	if req.Kind != "" {
+		kind = models.ScanImageKind(req.Kind)
+	}
+	if req.Barcode != nil && *req.Barcode == "" {
+		req.Barcode = nil
+	}
+
+	// Checked again on commit, when eviction makes room if enabled
+	if err := s.quota.Check(uid, req.Size, 1); err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("scans/%s/%s%s", userID, uuid.New().String(), uploadExtensions[req.ContentType])
+	target, err := uploader.PresignUpload(ctx, key, req.ContentType, s.uploadURLTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &models.ScanUpload{
+		UserID:         uid,
+		Kind:           kind,
+		ObjectKey:      key,
+		ContentType:    req.ContentType,
+		SizeBytes:      req.Size,
+		ChecksumSHA256: strings.ToLower(req.ChecksumSHA256),
+		Barcode:        req.Barcode,
+		ExpiresAt:      target.ExpiresAt,
+	}
+	if err := s.scanUploadRepo.Create(upload); err != nil {
+		return nil, fmt.Errorf("failed to save upload: %w", err)
+	}
+
+	return &dto.UploadURLResponse{
+		ScanID:    upload.ID.String(),
+		Method:    target.Method,
+		URL:       target.URL,
+		Headers:   target.Headers,
+		Fields:    target.Fields,
+		FileField: target.FileField,
+		ExpiresAt: target.ExpiresAt,
+	}, nil
+}
+
+// CommitUpload checks a directly uploaded image against what the client
+// declared, then creates its scan and queues it for processing. An image
+// that fails the checks is deleted so the client can upload it again.
+// Expired uploads are refused and their object is left to storage
+// reconciliation.
+func (s *scanService) CommitUpload(ctx context.Context, scanID string, userID string) (*dto.ScanUploadResponse, error) {
+	uploader, ok := s.store.(storage.DirectUploader)
+	if !ok {
+		return nil, ErrDirectUploadUnsupported
+	}
+
+	upload, err := s.scanUploadRepo.FindByID(scanID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.UserID.String() != userID {
+		return nil, ErrScanNotOwned
+	}
+	if upload.CommittedAt != nil {
+		return nil, repositories.ErrUploadCommitted
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return nil, ErrUploadExpired
+	}
+
+	ref, err := uploader.UploadedRef(ctx, upload.ObjectKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrUploadMissing
+		}
+		return nil, err
+	}
+
+	data, err := s.readUpload(ctx, ref, upload.SizeBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	image := ImageUpload{
+		Kind:        upload.Kind,
+		File:        bytes.NewReader(data),
+		Size:        int64(len(data)),
+		ContentType: upload.ContentType,
+	}
+	err = verifyUpload(upload, data)
+	if err == nil {
+		err = prepareUpload(&image, s.quality)
+	}
+	if err == nil {
+		err = s.quota.Reserve(ctx, upload.UserID, image.Size, 1, uuid.Nil)
+	}
+	if err != nil {
+		s.discardUploads(ctx, []models.ScanImage{{ImageRef: ref}})
+		return nil, err
+	}
+
+	// Stripping metadata changed the image, so a clean copy is stored. The
+	// upload stays in place until the commit succeeds, so a failed commit
+	// can be retried against the declared checksum.
+	uploadedRef := ref
+	if !bytes.Equal(image.data, data) {
+		key := fmt.Sprintf("scans/%s/%s%s", upload.UserID, uuid.New().String(), uploadExtensions[image.ContentType])
+		ref, err = s.store.Put(ctx, key, bytes.NewReader(image.data), image.Size, image.ContentType)
+		if err != nil {
+			s.quota.Release(upload.UserID, image.Size, 1)
+			return nil, fmt.Errorf("failed to store cleaned image: %w", err)
+		}
+	}
+
+	// Clients fall back to the full image, so a failure here is not fatal
+	hasVariants := true
+	if err := storage.StoreVariants(ctx, s.store, ref, image.data); err != nil {
+		logger.Warn("failed to store scan image variants", "ref", ref, "error", err)
+		hasVariants = false
+	}
+
+	scan := &models.Scan{
+		UserID:      &upload.UserID,
+		Barcode:     upload.Barcode,
+		Status:      models.ScanStatusPending,
+		ImageRef:    &ref,
+		ImageStored: true,
+		QualityJSON: qualityJSON(image.quality),
+		Images: []models.ScanImage{{
+			Kind:        upload.Kind,
+			ImageRef:    ref,
+			ContentType: image.ContentType,
+			SizeBytes:   image.Size,
+			HasVariants: hasVariants,
+			QualityJSON: qualityJSON(image.quality),
+		}},
+	}
+	scan.ID = upload.ID
+	s.matchBarcode(ctx, scan)
+
+	// The upload stays in place, so a failed commit can be retried
+	if err := s.scanUploadRepo.Commit(upload, scan); err != nil {
+		if ref != uploadedRef {
+			s.discardUploads(ctx, []models.ScanImage{{ImageRef: ref}})
+		}
+		s.quota.Release(upload.UserID, image.Size, 1)
+		return nil, err
+	}
+
+	if ref != uploadedRef {
+		if err := storage.DeleteImage(ctx, s.store, uploadedRef); err != nil {
+			// Reconciliation removes the unreferenced upload later
+			logger.Warn("failed to delete replaced upload", "ref", uploadedRef, "error", err)
+		}
+	}
+
+	s.enqueueNewScan(scan)
+
+	resp := dto.ToScanUploadResponse(scan, scan.ImageRef)
+	s.imageURLs.SignUpload(ctx, &resp)
+	return &resp, nil
+}
+
+// readUpload reads an uploaded object, refusing to buffer more than one
+// byte past the declared size
+func (s *scanService) readUpload(ctx context.Context, ref string, size int64) ([]byte, error) {
+	reader, err := s.store.Get(ctx, ref)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrUploadMissing
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, size+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded image: %w", err)
+	}
+	return data, nil
+}
+
+// verifyUpload compares an uploaded file with the size, checksum and
+// content type declared for it
+func verifyUpload(upload *models.ScanUpload, data []byte) error {
+	if int64(len(data)) != upload.SizeBytes {
+		return fmt.Errorf("%w: size differs from the declared %d bytes", ErrUploadMismatch, upload.SizeBytes)
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != upload.ChecksumSHA256 {
+		return fmt.Errorf("%w: SHA-256 checksum differs", ErrUploadMismatch)
+	}
+
+	if detected := http.DetectContentType(data); detected != upload.ContentType {
+		return fmt.Errorf("%w: content is %s, not %s", ErrUploadMismatch, detected, upload.ContentType)
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+	"github.com/habbazettt/nutrisnap-server/pkg/storage"
+)
+
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+// evictionBatch bounds how many scans one upload may evict images from
+const evictionBatch = 50
+
+// StorageQuota limits the stored images of a user. Zero means unlimited.
+type StorageQuota struct {
+	Bytes   int64
+	Objects int
+}
+
+func (q StorageQuota) allows(bytes int64, objects int) bool {
+	return (q.Bytes == 0 || bytes <= q.Bytes) && (q.Objects == 0 || objects <= q.Objects)
+}
+
+// QuotaConfig holds the storage quota of each role
+type QuotaConfig struct {
+	Quotas map[models.UserRole]StorageQuota
+	// Evict deletes a user's oldest images to make room for an upload
+	// instead of rejecting it
+	Evict bool
+}
+
+// StorageQuotaService accounts for the images users store and enforces
+// their quotas
+type StorageQuotaService interface {
+	// Check reports ErrStorageQuotaExceeded for an upload that will not
+	// fit, without evicting anything
+	Check(userID uuid.UUID, bytes int64, objects int) error
+	// Reserve adds an upload to the user's usage if it fits their quota,
+	// evicting old images first when configured to. keepScanID names a
+	// scan whose images are never evicted, or is uuid.Nil.
+	Reserve(ctx context.Context, userID uuid.UUID, bytes int64, objects int, keepScanID uuid.UUID) error
+	// Release gives back a reservation for images that were not stored
+	Release(userID uuid.UUID, bytes int64, objects int)
+	// Record adds stored images to a user's usage, or removes them when
+	// negative
+	Record(userID uuid.UUID, bytes int64, objects int)
+	GetUsage(userID string) (*dto.StorageUsageResponse, error)
+}
+
+type storageQuotaService struct {
+	usageRepo repositories.StorageUsageRepository
+	userRepo  repositories.UserRepository
+	scanRepo  repositories.ScanRepository
+	store     storage.ObjectStore
+	config    QuotaConfig
+}
+
+func NewStorageQuotaService(usageRepo repositories.StorageUsageRepository, userRepo repositories.UserRepository, scanRepo repositories.ScanRepository, store storage.ObjectStore, config QuotaConfig) StorageQuotaService {
+	return &storageQuotaService{
+		usageRepo: usageRepo,
+		userRepo:  userRepo,
+		scanRepo:  scanRepo,
+		store:     store,
+		config:    config,
+	}
+}
+
+// Check lets through uploads that eviction could make room for when it
+// is enabled. It only reads usage, so Reserve has the final say.
+func (s *storageQuotaService) Check(userID uuid.UUID, bytes int64, objects int) error {
+	quota, err := s.quotaFor(userID.String())
+	if err != nil {
+		return err
+	}
+	if quota == (StorageQuota{}) {
+		return nil
+	}
+	// Evicting everything else would not make room
+	if !quota.allows(bytes, objects) {
+		return ErrStorageQuotaExceeded
+	}
+	if s.config.Evict {
+		return nil
+	}
+
+	usage, err := s.usageRepo.FindByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to load storage usage: %w", err)
+	}
+	if !quota.allows(usage.Bytes+bytes, usage.Objects+objects) {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// Reserve holds the space for the upload before it is stored, so
+// concurrent uploads of one user cannot overshoot the quota together
+func (s *storageQuotaService) Reserve(ctx context.Context, userID uuid.UUID, bytes int64, objects int, keepScanID uuid.UUID) error {
+	quota, err := s.quotaFor(userID.String())
+	if err != nil {
+		return err
+	}
+	// Evicting everything else would not make room
+	if !quota.allows(bytes, objects) {
+		return ErrStorageQuotaExceeded
+	}
+
+	reserved, err := s.usageRepo.Reserve(userID, bytes, objects, quota.Bytes, quota.Objects)
+	if err != nil {
+		return fmt.Errorf("failed to reserve storage: %w", err)
+	}
+	if reserved {
+		return nil
+	}
+	if !s.config.Evict {
+		return ErrStorageQuotaExceeded
+	}
+
+	scans, err := s.scanRepo.FindOldestWithStoredImages(userID, keepScanID, evictionBatch)
+	if err != nil {
+		return fmt.Errorf("failed to find images to evict: %w", err)
+	}
+	for i := range scans {
+		if err := s.evict(ctx, &scans[i]); err != nil {
+			logger.Warn("failed to evict scan images", "scan_id", scans[i].ID, "error", err)
+			continue
+		}
+		reserved, err := s.usageRepo.Reserve(userID, bytes, objects, quota.Bytes, quota.Objects)
+		if err != nil {
+			return fmt.Errorf("failed to reserve storage: %w", err)
+		}
+		if reserved {
+			return nil
+		}
+	}
+
+	return ErrStorageQuotaExceeded
+}
+
+// Release is Record for images that were reserved but never stored
+func (s *storageQuotaService) Release(userID uuid.UUID, bytes int64, objects int) {
+	s.Record(userID, -bytes, -objects)
+}
+
+// evict deletes the stored images of a scan, keeping its OCR results, and
+// removes them from its owner's usage
+func (s *storageQuotaService) evict(ctx context.Context, scan *models.Scan) error {
+	for _, ref := range imageRefs(scan) {
+		if err := storage.DeleteImage(ctx, s.store, ref); err != nil {
+			return err
+		}
+	}
+	if err := s.scanRepo.ReleaseImages(scan.ID); err != nil {
+		return err
+	}
+
+	bytes, objects := scan.StoredUsage()
+	s.Record(*scan.UserID, -bytes, -objects)
+	logger.Info("evicted scan images to stay within storage quota", "scan_id", scan.ID, "bytes", bytes)
+	return nil
+}
+
+// Record logs failures instead of returning them, since the stored images
+// are already in place. Storage reconciliation recalculates usage.
+func (s *storageQuotaService) Record(userID uuid.UUID, bytes int64, objects int) {
+	if bytes == 0 && objects == 0 {
+		return
+	}
+	if err := s.usageRepo.Add(userID, bytes, objects); err != nil {
+		logger.Warn("failed to record storage usage", "user_id", userID, "bytes", bytes, "objects", objects, "error", err)
+	}
+}
+
+func (s *storageQuotaService) GetUsage(userID string) (*dto.StorageUsageResponse, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	quota, err := s.quotaFor(userID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.usageRepo.FindByUserID(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.StorageUsageResponse{
+		UsedBytes:   usage.Bytes,
+		UsedObjects: usage.Objects,
+		Eviction:    s.config.Evict,
+	}
+	if quota.Bytes > 0 {
+		resp.QuotaBytes = &quota.Bytes
+	}
+	if quota.Objects > 0 {
+		resp.QuotaObjects = &quota.Objects
+	}
+	return resp, nil
+}
+
+func (s *storageQuotaService) quotaFor(userID string) (StorageQuota, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return StorageQuota{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	return s.config.Quotas[user.Role], nil
+}
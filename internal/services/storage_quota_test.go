@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+)
+
+// memoryUsageRepo keeps usage in memory and reserves under a lock, like
+// the conditional update does in the database
+type memoryUsageRepo struct {
+	repositories.StorageUsageRepository
+	mu    sync.Mutex
+	usage models.StorageUsage
+}
+
+func (r *memoryUsageRepo) Add(userID uuid.UUID, bytes int64, objects int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage.Bytes = max(r.usage.Bytes+bytes, 0)
+	r.usage.Objects = max(r.usage.Objects+objects, 0)
+	return nil
+}
+
+func (r *memoryUsageRepo) Reserve(userID uuid.UUID, bytes int64, objects int, maxBytes int64, maxObjects int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if (maxBytes > 0 && r.usage.Bytes+bytes > maxBytes) || (maxObjects > 0 && r.usage.Objects+objects > maxObjects) {
+		return false, nil
+	}
+	r.usage.Bytes += bytes
+	r.usage.Objects += objects
+	return true, nil
+}
+
+func (r *memoryUsageRepo) FindByUserID(userID uuid.UUID) (*models.StorageUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	usage := r.usage
+	return &usage, nil
+}
+
+type roleUserRepo struct {
+	repositories.UserRepository
+}
+
+func (roleUserRepo) FindByID(id string) (*models.User, error) {
+	return &models.User{Role: models.RoleUser}, nil
+}
+
+// evictableScanRepo offers scans with stored images for eviction
+type evictableScanRepo struct {
+	repositories.ScanRepository
+	scans    []models.Scan
+	released []uuid.UUID
+}
+
+func (r *evictableScanRepo) FindOldestWithStoredImages(userID, exclude uuid.UUID, limit int) ([]models.Scan, error) {
+	return r.scans, nil
+}
+
+func (r *evictableScanRepo) ReleaseImages(id uuid.UUID) error {
+	r.released = append(r.released, id)
+	return nil
+}
+
+func testQuotaService(usage *memoryUsageRepo, scans *evictableScanRepo, evict bool) StorageQuotaService {
+	config := QuotaConfig{
+		Quotas: map[models.UserRole]StorageQuota{models.RoleUser: {Bytes: 1000, Objects: 3}},
+		Evict:  evict,
+	}
+	return NewStorageQuotaService(usage, roleUserRepo{}, scans, &recordingStore{}, config)
+}
+
+func TestReserveConcurrentUploads(t *testing.T) {
+	usage := &memoryUsageRepo{}
+	quota := testQuotaService(usage, &evictableScanRepo{}, false)
+	userID := uuid.New()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := quota.Reserve(context.Background(), userID, 300, 1, uuid.Nil); err == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrStorageQuotaExceeded) {
+				t.Errorf("Reserve() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 3 {
+		t.Errorf("accepted %d concurrent uploads, want 3", accepted)
+	}
+	if usage.usage.Bytes != 900 || usage.usage.Objects != 3 {
+		t.Errorf("usage = %d bytes, %d objects, want 900 and 3", usage.usage.Bytes, usage.usage.Objects)
+	}
+}
+
+func TestReserveRelease(t *testing.T) {
+	usage := &memoryUsageRepo{}
+	quota := testQuotaService(usage, &evictableScanRepo{}, false)
+	userID := uuid.New()
+
+	if err := quota.Reserve(context.Background(), userID, 800, 1, uuid.Nil); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if err := quota.Reserve(context.Background(), userID, 300, 1, uuid.Nil); !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("Reserve() over the quota error = %v, want ErrStorageQuotaExceeded", err)
+	}
+
+	// A failed upload gives its space back
+	quota.Release(userID, 800, 1)
+	if err := quota.Reserve(context.Background(), userID, 300, 1, uuid.Nil); err != nil {
+		t.Errorf("Reserve() after Release error = %v", err)
+	}
+	if usage.usage.Bytes != 300 || usage.usage.Objects != 1 {
+		t.Errorf("usage = %d bytes, %d objects, want 300 and 1", usage.usage.Bytes, usage.usage.Objects)
+	}
+}
+
+func TestReserveEvicts(t *testing.T) {
+	userID := uuid.New()
+	old := ownedScan(userID)
+	usage := &memoryUsageRepo{usage: models.StorageUsage{Bytes: 1000, Objects: 1}}
+	scans := &evictableScanRepo{scans: []models.Scan{*old}}
+	quota := testQuotaService(usage, scans, true)
+
+	if err := quota.Reserve(context.Background(), userID, 500, 1, uuid.Nil); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if len(scans.released) != 1 || scans.released[0] != old.ID {
+		t.Errorf("released scans %v, want the oldest scan", scans.released)
+	}
+	if usage.usage.Bytes != 500 || usage.usage.Objects != 1 {
+		t.Errorf("usage = %d bytes, %d objects, want 500 and 1", usage.usage.Bytes, usage.usage.Objects)
+	}
+}
+
+func TestReserveLargerThanQuota(t *testing.T) {
+	usage := &memoryUsageRepo{}
+	scans := &evictableScanRepo{scans: []models.Scan{*ownedScan(uuid.New())}}
+	quota := testQuotaService(usage, scans, true)
+
+	if err := quota.Reserve(context.Background(), uuid.New(), 1001, 1, uuid.Nil); !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Errorf("Reserve() error = %v, want ErrStorageQuotaExceeded", err)
+	}
+	if len(scans.released) != 0 {
+		t.Error("evicted images for an upload that can never fit")
+	}
+}
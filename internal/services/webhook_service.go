@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+	"github.com/habbazettt/nutrisnap-server/pkg/webhook"
+)
+
+var (
+	ErrWebhookNotOwned   = errors.New("webhook does not belong to user")
+	ErrWebhookDisabled   = errors.New("webhook is disabled")
+	ErrInvalidWebhookURL = errors.New("invalid webhook url")
+	// ErrWebhookTargetForbidden is returned for URLs resolving to loopback,
+	// private, link-local or other non-public addresses
+	ErrWebhookTargetForbidden = errors.New("webhook url does not resolve to a public address")
+)
+
+// webhookLookupTimeout bounds resolving a webhook host at registration
+const webhookLookupTimeout = 5 * time.Second
+
+// WebhookPublisher queues an event for every webhook subscribed to it
+type WebhookPublisher interface {
+	// Publish is best effort: failures are logged and never fail the caller.
+	// userID limits delivery to that user's webhooks; nil sends the event to
+	// every subscribed webhook.
+	Publish(event models.WebhookEventType, userID *uuid.UUID, data interface{})
+}
+
+// WebhookOwner is who manages a webhook: a user, or one of the user's API
+// clients when ClientID is set. Each only sees the webhooks it created.
+type WebhookOwner struct {
+	UserID   string
+	ClientID string
+}
+
+type WebhookService interface {
+	WebhookPublisher
+	CreateWebhook(owner WebhookOwner, req dto.CreateWebhookRequest) (*dto.WebhookCreatedResponse, error)
+	GetWebhooks(owner WebhookOwner) ([]dto.WebhookResponse, error)
+	GetWebhook(id string, owner WebhookOwner) (*dto.WebhookResponse, error)
+	UpdateWebhook(id string, owner WebhookOwner, req dto.UpdateWebhookRequest) (*dto.WebhookResponse, error)
+	DeleteWebhook(id string, owner WebhookOwner) error
+	GetDeliveries(id string, owner WebhookOwner, page, limit int) (*dto.PaginatedWebhookDeliveriesResponse, error)
+	Redeliver(id, deliveryID string, owner WebhookOwner) (*dto.WebhookDeliveryResponse, error)
+}
+
+// WebhookConfig holds webhook service configuration
+type WebhookConfig struct {
+	MaxAttempts int
+	// RequireHTTPS rejects plain http URLs, so payloads and signatures are
+	// not sent in the clear
+	RequireHTTPS bool
+	// AllowPrivateTargets accepts URLs on loopback and private networks,
+	// for receivers running next to a development server
+	AllowPrivateTargets bool
+}
+
+type webhookService struct {
+	webhookRepo repositories.WebhookRepository
+	config      WebhookConfig
+}
+
+func NewWebhookService(webhookRepo repositories.WebhookRepository, config WebhookConfig) WebhookService {
+	return &webhookService{
+		webhookRepo: webhookRepo,
+		config:      config,
+	}
+}
+
+func (s *webhookService) CreateWebhook(owner WebhookOwner, req dto.CreateWebhookRequest) (*dto.WebhookCreatedResponse, error) {
+	userUUID, err := uuid.Parse(owner.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+	clientID, err := owner.clientUUID()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	events, _ := json.Marshal(req.Events)
+	subscription := &models.WebhookSubscription{
+		UserID:      userUUID,
+		ClientID:    clientID,
+		URL:         req.URL,
+		Description: req.Description,
+		EventsJSON:  events,
+		Secret:      secret,
+		Active:      true,
+	}
+	if err := s.webhookRepo.Create(subscription); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return &dto.WebhookCreatedResponse{
+		WebhookResponse: dto.ToWebhookResponse(subscription),
+		Secret:          secret,
+	}, nil
+}
+
+func (s *webhookService) GetWebhooks(owner WebhookOwner) ([]dto.WebhookResponse, error) {
+	clientID, err := owner.clientUUID()
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := s.webhookRepo.FindByOwner(owner.UserID, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]dto.WebhookResponse, len(subscriptions))
+	for i := range subscriptions {
+		resp[i] = dto.ToWebhookResponse(&subscriptions[i])
+	}
+	return resp, nil
+}
+
+func (s *webhookService) GetWebhook(id string, owner WebhookOwner) (*dto.WebhookResponse, error) {
+	subscription, err := s.findOwned(id, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := dto.ToWebhookResponse(subscription)
+	return &resp, nil
+}
+
+func (s *webhookService) UpdateWebhook(id string, owner WebhookOwner, req dto.UpdateWebhookRequest) (*dto.WebhookResponse, error) {
+	subscription, err := s.findOwned(id, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		if err := s.validateURL(*req.URL); err != nil {
+			return nil, err
+		}
+		subscription.URL = *req.URL
+	}
+	if req.Events != nil {
+		subscription.EventsJSON, _ = json.Marshal(req.Events)
+	}
+	if req.Description != nil {
+		subscription.Description = req.Description
+	}
+	if req.Active != nil {
+		if *req.Active && !subscription.Active {
+			// Re-enabled by the owner, so start counting failures afresh
+			subscription.ConsecutiveFailures = 0
+			subscription.DisabledAt = nil
+			subscription.DisabledReason = nil
+		}
+		subscription.Active = *req.Active
+	}
+
+	if err := s.webhookRepo.Update(subscription); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	resp := dto.ToWebhookResponse(subscription)
+	return &resp, nil
+}
+
+func (s *webhookService) DeleteWebhook(id string, owner WebhookOwner) error {
+	if _, err := s.findOwned(id, owner); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(id)
+}
+
+func (s *webhookService) GetDeliveries(id string, owner WebhookOwner, page, limit int) (*dto.PaginatedWebhookDeliveriesResponse, error) {
+	if _, err := s.findOwned(id, owner); err != nil {
+		return nil, err
+	}
+
+	deliveries, total, err := s.webhookRepo.FindDeliveries(id, (page-1)*limit, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]dto.WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		resp[i] = dto.ToWebhookDeliveryResponse(&deliveries[i])
+	}
+
+	totalPages := int(total) / limit
+	if int(total)%limit > 0 {
+		totalPages++
+	}
+
+	return &dto.PaginatedWebhookDeliveriesResponse{
+		Deliveries: resp,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// Redeliver queues the event of a past delivery again as a new delivery,
+// keeping the original in the log
+func (s *webhookService) Redeliver(id, deliveryID string, owner WebhookOwner) (*dto.WebhookDeliveryResponse, error) {
+	subscription, err := s.findOwned(id, owner)
+	if err != nil {
+		return nil, err
+	}
+	if !subscription.Active {
+		return nil, ErrWebhookDisabled
+	}
+
+	original, err := s.webhookRepo.FindDelivery(id, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := models.WebhookDelivery{
+		SubscriptionID: subscription.ID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  time.Now(),
+		MaxAttempts:    s.config.MaxAttempts,
+	}
+	if err := s.webhookRepo.CreateDeliveries([]models.WebhookDelivery{delivery}); err != nil {
+		return nil, fmt.Errorf("failed to queue redelivery: %w", err)
+	}
+
+	resp := dto.ToWebhookDeliveryResponse(&delivery)
+	return &resp, nil
+}
+
+func (s *webhookService) Publish(event models.WebhookEventType, userID *uuid.UUID, data interface{}) {
+	subscriptions, err := s.webhookRepo.FindSubscribed(event, userID)
+	if err != nil {
+		logger.Error("failed to find webhooks for event", "event", event, "error", err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	envelope := dto.WebhookEvent{
+		ID:        uuid.New(),
+		Type:      event,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		logger.Error("failed to encode webhook event", "event", event, "error", err)
+		return
+	}
+
+	deliveries := make([]models.WebhookDelivery, len(subscriptions))
+	for i, subscription := range subscriptions {
+		deliveries[i] = models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        envelope.ID,
+			EventType:      event,
+			Payload:        payload,
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  envelope.CreatedAt,
+			MaxAttempts:    s.config.MaxAttempts,
+		}
+	}
+	if err := s.webhookRepo.CreateDeliveries(deliveries); err != nil {
+		logger.Error("failed to queue webhook deliveries",
+			"event", event,
+			"event_id", envelope.ID,
+			"webhooks", len(deliveries),
+			"error", err,
+		)
+	}
+}
+
+func (s *webhookService) findOwned(id string, owner WebhookOwner) (*models.WebhookSubscription, error) {
+	subscription, err := s.webhookRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	clientID := ""
+	if subscription.ClientID != nil {
+		clientID = subscription.ClientID.String()
+	}
+	if subscription.UserID.String() != owner.UserID || clientID != owner.ClientID {
+		return nil, ErrWebhookNotOwned
+	}
+	return subscription, nil
+}
+
+// clientUUID returns the owning client, or nil for a user's own webhooks
+func (o WebhookOwner) clientUUID() (*uuid.UUID, error) {
+	if o.ClientID == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(o.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client ID: %w", err)
+	}
+	return &id, nil
+}
+
+func (s *webhookService) validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if s.config.RequireHTTPS {
+			return ErrInvalidWebhookURL
+		}
+	default:
+		return ErrInvalidWebhookURL
+	}
+
+	if s.config.AllowPrivateTargets {
+		return nil
+	}
+	// Deliveries check the address again when they connect, this only
+	// turns away internal targets early
+	ctx, cancel := context.WithTimeout(context.Background(), webhookLookupTimeout)
+	defer cancel()
+	if err := webhook.CheckHost(ctx, u.Hostname()); err != nil {
+		if errors.Is(err, webhook.ErrForbiddenTarget) {
+			return ErrWebhookTargetForbidden
+		}
+		return ErrInvalidWebhookURL
+	}
+	return nil
+}
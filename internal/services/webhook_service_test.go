@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+)
+
+// memoryWebhookRepo keeps webhook subscriptions in memory
+type memoryWebhookRepo struct {
+	repositories.WebhookRepository
+	webhooks []*models.WebhookSubscription
+}
+
+func (r *memoryWebhookRepo) Create(webhook *models.WebhookSubscription) error {
+	webhook.ID = uuid.New()
+	r.webhooks = append(r.webhooks, webhook)
+	return nil
+}
+
+func (r *memoryWebhookRepo) FindByID(id string) (*models.WebhookSubscription, error) {
+	for _, webhook := range r.webhooks {
+		if webhook.ID.String() == id {
+			return webhook, nil
+		}
+	}
+	return nil, repositories.ErrWebhookNotFound
+}
+
+func (r *memoryWebhookRepo) FindByOwner(userID string, clientID *uuid.UUID) ([]models.WebhookSubscription, error) {
+	var found []models.WebhookSubscription
+	for _, webhook := range r.webhooks {
+		sameClient := (clientID == nil && webhook.ClientID == nil) ||
+			(clientID != nil && webhook.ClientID != nil && *clientID == *webhook.ClientID)
+		if webhook.UserID.String() == userID && sameClient {
+			found = append(found, *webhook)
+		}
+	}
+	return found, nil
+}
+
+func TestWebhookClientOwnership(t *testing.T) {
+	repo := &memoryWebhookRepo{}
+	service := NewWebhookService(repo, WebhookConfig{MaxAttempts: 3, AllowPrivateTargets: true})
+
+	userID := uuid.NewString()
+	user := WebhookOwner{UserID: userID}
+	client := WebhookOwner{UserID: userID, ClientID: uuid.NewString()}
+	otherClient := WebhookOwner{UserID: userID, ClientID: uuid.NewString()}
+	req := dto.CreateWebhookRequest{URL: "https://partner.example.com/hook", Events: []string{"scan.completed"}}
+
+	userHook, err := service.CreateWebhook(user, req)
+	if err != nil {
+		t.Fatalf("CreateWebhook() for the user error = %v", err)
+	}
+	clientHook, err := service.CreateWebhook(client, req)
+	if err != nil {
+		t.Fatalf("CreateWebhook() for the client error = %v", err)
+	}
+	if clientHook.ClientID == nil || *clientHook.ClientID != client.ClientID {
+		t.Errorf("client webhook ClientID = %v, want %s", clientHook.ClientID, client.ClientID)
+	}
+	// Client webhooks still receive the user's events
+	if repo.webhooks[1].UserID.String() != userID {
+		t.Errorf("client webhook belongs to user %s, want %s", repo.webhooks[1].UserID, userID)
+	}
+
+	tests := []struct {
+		name  string
+		owner WebhookOwner
+		want  string
+	}{
+		{"user", user, userHook.ID},
+		{"client", client, clientHook.ID},
+		{"other client", otherClient, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhooks, err := service.GetWebhooks(tt.owner)
+			if err != nil {
+				t.Fatalf("GetWebhooks() error = %v", err)
+			}
+			if tt.want == "" {
+				if len(webhooks) != 0 {
+					t.Errorf("GetWebhooks() = %d webhooks, want none", len(webhooks))
+				}
+				return
+			}
+			if len(webhooks) != 1 || webhooks[0].ID != tt.want {
+				t.Errorf("GetWebhooks() = %+v, want only %s", webhooks, tt.want)
+			}
+		})
+	}
+
+	if _, err := service.GetWebhook(userHook.ID, client); !errors.Is(err, ErrWebhookNotOwned) {
+		t.Errorf("client GetWebhook() of the user's webhook error = %v, want ErrWebhookNotOwned", err)
+	}
+	if _, err := service.GetWebhook(clientHook.ID, user); !errors.Is(err, ErrWebhookNotOwned) {
+		t.Errorf("user GetWebhook() of the client's webhook error = %v, want ErrWebhookNotOwned", err)
+	}
+	if _, err := service.GetWebhook(clientHook.ID, otherClient); !errors.Is(err, ErrWebhookNotOwned) {
+		t.Errorf("other client GetWebhook() error = %v, want ErrWebhookNotOwned", err)
+	}
+}
@@ -3,158 +3,687 @@ package workers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
 	"github.com/habbazettt/nutrisnap-server/internal/models"
 	"github.com/habbazettt/nutrisnap-server/internal/repositories"
 	"github.com/habbazettt/nutrisnap-server/internal/services"
+	"github.com/habbazettt/nutrisnap-server/pkg/backoff"
+	"github.com/habbazettt/nutrisnap-server/pkg/imagequality"
 	"github.com/habbazettt/nutrisnap-server/pkg/nutrition"
+	"github.com/habbazettt/nutrisnap-server/pkg/pipeline"
 )
 
+// QueueConfig controls how workers consume the scan job table
+type QueueConfig struct {
+	PollInterval      time.Duration
+	JobTimeout        time.Duration
+	VisibilityTimeout time.Duration
+	MaxAttempts       int
+	RetryDelay        time.Duration
+	RetryMaxDelay     time.Duration
+	// UserMaxInFlight caps how many scans of one user are processed at once
+	UserMaxInFlight int
+}
+
+// throughputWindow is how far back completed jobs are counted when
+// estimating queue wait
+const throughputWindow = 10 * time.Minute
+
+// orphanBatchSize bounds how many unqueued scans are recovered at startup
+const orphanBatchSize = 1000
+
+// EventPublisher pushes scan status changes to clients watching them
+type EventPublisher interface {
+	PublishScanEvent(event dto.ScanEvent)
+}
+
+// OCRWorker consumes scan jobs from Postgres. Jobs survive restarts and are
+// shared safely between workers and instances through SKIP LOCKED.
 type OCRWorker struct {
-	scanRepo    repositories.ScanRepository
-	productRepo repositories.ProductRepository
-	ocrService  services.OCRService
-	scanQueue   chan string // Channel receiving ScanIDs
-	quit        chan bool
+	scanRepo      repositories.ScanRepository
+	scanImageRepo repositories.ScanImageRepository
+	productRepo   repositories.ProductRepository
+	jobRepo       repositories.ScanJobRepository
+	ocrService    services.OCRService
+	events        EventPublisher
+	webhooks      services.WebhookPublisher
+	imageURLs     services.ImageURLSigner
+	config        QueueConfig
+	instanceID    string
+	wake          chan struct{} // Nudges an idle worker when a job is enqueued locally
+	workers       int
+
+	// intake stops workers from claiming new jobs, work aborts scans in flight
+	intake     context.Context
+	stopIntake context.CancelFunc
+	work       context.Context
+	abortWork  context.CancelFunc
+	wg         sync.WaitGroup
 }
 
-func NewOCRWorker(scanRepo repositories.ScanRepository, productRepo repositories.ProductRepository, ocrService services.OCRService, bufferSize int) *OCRWorker {
+func NewOCRWorker(scanRepo repositories.ScanRepository, scanImageRepo repositories.ScanImageRepository, productRepo repositories.ProductRepository, jobRepo repositories.ScanJobRepository, ocrService services.OCRService, events EventPublisher, webhooks services.WebhookPublisher, imageURLs services.ImageURLSigner, config QueueConfig) *OCRWorker {
+	hostname, _ := os.Hostname()
 	return &OCRWorker{
-		scanRepo:    scanRepo,
-		productRepo: productRepo,
-		ocrService:  ocrService,
-		scanQueue:   make(chan string, bufferSize),
-		quit:        make(chan bool),
+		scanRepo:      scanRepo,
+		scanImageRepo: scanImageRepo,
+		productRepo:   productRepo,
+		jobRepo:       jobRepo,
+		ocrService:    ocrService,
+		events:        events,
+		webhooks:      webhooks,
+		imageURLs:     imageURLs,
+		config:        config,
+		instanceID:    fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8]),
+		wake:          make(chan struct{}, 1),
 	}
 }
 
-// Start launches 'workers' number of goroutines
+// Start recovers abandoned jobs and launches 'workers' number of goroutines
 func (w *OCRWorker) Start(workers int) {
+	w.intake, w.stopIntake = context.WithCancel(context.Background())
+	w.work, w.abortWork = context.WithCancel(context.Background())
+
+	w.workers = workers
+	w.recover()
 	for i := 0; i < workers; i++ {
+		w.wg.Add(1)
 		go w.run(i)
 	}
-	log.Printf("OCR Worker: Started %d worker(s)", workers)
+	log.Printf("OCR Worker: Started %d worker(s) as %s", workers, w.instanceID)
+}
+
+// Running returns how many workers are taking jobs, zero before Start and
+// once shutdown has begun
+func (w *OCRWorker) Running() int {
+	if w.intake == nil || w.intake.Err() != nil {
+		return 0
+	}
+	return w.workers
 }
 
-// Stop signals all workers to stop
-func (w *OCRWorker) Stop() {
+// Shutdown stops claiming new jobs and waits for scans in flight to finish.
+// When ctx expires first, the remaining scans are aborted and their jobs
+// put back in the queue for another worker, and ctx.Err() is returned
+// once every worker goroutine has exited.
+func (w *OCRWorker) Shutdown(ctx context.Context) error {
+	if w.stopIntake == nil {
+		return nil
+	}
+	w.stopIntake()
+
+	done := make(chan struct{})
 	go func() {
-		w.quit <- true
+		w.wg.Wait()
+		close(done)
 	}()
+
+	select {
+	case <-done:
+		w.abortWork()
+		log.Printf("OCR Worker: All workers stopped")
+		return nil
+	case <-ctx.Done():
+		log.Printf("OCR Worker: Shutdown deadline reached, checkpointing scans in flight")
+		w.abortWork()
+		<-done
+		return ctx.Err()
+	}
 }
 
-// EnqueueScan adds a scan ID to the processing queue
-func (w *OCRWorker) EnqueueScan(scanID string) {
+// EnqueueScan persists a job for the scan so it is processed even if this
+// instance restarts before a worker gets to it
+func (w *OCRWorker) EnqueueScan(scanID string, priority models.ScanPriority) {
+	id, err := uuid.Parse(scanID)
+	if err != nil {
+		log.Printf("OCR Worker: Invalid scan ID %q: %v", scanID, err)
+		return
+	}
+
+	job, err := w.jobRepo.Enqueue(id, priority, w.config.MaxAttempts)
+	if err != nil {
+		// The scan stays pending and is picked up by recovery on the next start
+		log.Printf("OCR Worker: Failed to enqueue scan %s: %v", scanID, err)
+		return
+	}
+	if job != nil {
+		w.publish(job, dto.ScanEvent{Type: dto.ScanEventQueued})
+	}
+
 	select {
-	case w.scanQueue <- scanID:
-		// Successfully queued
+	case w.wake <- struct{}{}:
 	default:
-		log.Printf("OCR Worker: Queue full, dropping scan %s", scanID)
+	}
+}
+
+// QueuePosition reports where a pending scan stands in the queue. The wait
+// is estimated from how many jobs all workers finished recently, so it
+// holds however many worker instances are running.
+func (w *OCRWorker) QueuePosition(scanID string) (*dto.QueuePosition, error) {
+	job, ahead, err := w.jobRepo.Position(scanID)
+	if err != nil {
+		return nil, err
+	}
+
+	position := &dto.QueuePosition{
+		Position: int(ahead) + 1,
+		Priority: job.Priority.String(),
+	}
+
+	completed, avgProcessing, err := w.jobRepo.Throughput(throughputWindow)
+	if err != nil || completed == 0 {
+		// Nothing finished recently, so there is no rate to estimate from
+		return position, nil
+	}
+
+	perJob := throughputWindow / time.Duration(completed)
+	wait := time.Duration(position.Position)*perJob + avgProcessing
+	if delay := time.Until(job.RunAt); delay > wait {
+		// A retry that is not due yet waits at least until it is
+		wait = delay
+	}
+	seconds := int(wait.Round(time.Second).Seconds())
+	position.EstimatedWaitSeconds = &seconds
+
+	return position, nil
+}
+
+// recover requeues jobs whose worker died mid-scan and creates jobs for
+// pending scans that were never persisted to the queue
+func (w *OCRWorker) recover() {
+	requeued, err := w.jobRepo.RequeueExpired()
+	if err != nil {
+		log.Printf("OCR Worker: Failed to requeue expired jobs: %v", err)
+	} else if requeued > 0 {
+		log.Printf("OCR Worker: Requeued %d job(s) stuck in processing", requeued)
+	}
+
+	orphans, err := w.jobRepo.FindOrphanedScanIDs(orphanBatchSize)
+	if err != nil {
+		log.Printf("OCR Worker: Failed to find unqueued scans: %v", err)
+		return
+	}
+	for _, scanID := range orphans {
+		if _, err := w.jobRepo.Enqueue(scanID, models.ScanPriorityInteractive, w.config.MaxAttempts); err != nil {
+			log.Printf("OCR Worker: Failed to enqueue scan %s: %v", scanID, err)
+		}
+	}
+	if len(orphans) > 0 {
+		log.Printf("OCR Worker: Enqueued %d scan(s) left without a job", len(orphans))
 	}
 }
 
 func (w *OCRWorker) run(id int) {
+	defer w.wg.Done()
+	workerID := fmt.Sprintf("%s-%d", w.instanceID, id)
+
 	for {
-		select {
-		case scanID := <-w.scanQueue:
-			// Process scan
-			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-			if err := w.processScan(ctx, scanID); err != nil {
-				log.Printf("OCR Worker [%d]: Failed to process scan %s: %v", id, scanID, err)
-			} else {
-				log.Printf("OCR Worker [%d]: Successfully processed scan %s", id, scanID)
-			}
-			cancel()
-		case <-w.quit:
+		if w.intake.Err() != nil {
 			log.Printf("OCR Worker [%d]: Stopping", id)
 			return
 		}
+
+		job, err := w.jobRepo.Claim(workerID, w.config.VisibilityTimeout, w.config.UserMaxInFlight)
+		if err == nil {
+			w.handleJob(id, job)
+			// Keep draining while there is work
+			continue
+		}
+		if !errors.Is(err, repositories.ErrNoScanJob) {
+			log.Printf("OCR Worker [%d]: Failed to claim job: %v", id, err)
+		}
+
+		select {
+		case <-w.wake:
+		case <-time.After(w.config.PollInterval):
+		case <-w.intake.Done():
+		}
+	}
+}
+
+// Outcomes of a processing attempt, used in traces and metric labels
+const (
+	outcomeCompleted  = "completed"
+	outcomeRetried    = "retried"
+	outcomeFailed     = "failed"
+	outcomeDeadLetter = "dead_letter"
+	outcomeAborted    = "aborted"
+)
+
+func (w *OCRWorker) handleJob(id int, job *models.ScanJob) {
+	scanID := job.ScanID.String()
+
+	// The previous holder died after using up the last attempt
+	if job.Attempts > job.MaxAttempts {
+		w.deadLetterJob(job, "worker lost the job after the last attempt")
+		w.publishOutcome(job, outcomeDeadLetter, nil)
+		return
+	}
+
+	trace := pipeline.NewTrace()
+	trace.Add(pipeline.StageQueueWait, time.Since(job.RunAt))
+
+	w.publish(job, dto.ScanEvent{Type: dto.ScanEventProcessing})
+	trace.OnStage(func(stage pipeline.Stage) {
+		w.publish(job, dto.ScanEvent{Type: dto.ScanEventProcessing, Stage: string(stage)})
+	})
+
+	ctx, cancel := context.WithTimeout(pipeline.WithTrace(w.work, trace), w.config.JobTimeout)
+	err := w.processScan(ctx, scanID)
+	cancel()
+
+	outcome := w.settleJob(id, job, err)
+	w.saveTrace(scanID, trace, outcome)
+	w.publishOutcome(job, outcome, err)
+}
+
+// publish fills in the scan, user and time of an event and sends it
+func (w *OCRWorker) publish(job *models.ScanJob, event dto.ScanEvent) {
+	event.ScanID = job.ScanID.String()
+	if job.UserID != nil {
+		event.UserID = job.UserID.String()
 	}
+	event.At = time.Now()
+	w.events.PublishScanEvent(event)
+}
+
+// publishOutcome reports the state a scan was left in after an attempt.
+// The scan is read back so the event carries what was stored, including
+// the result summary or the error shown to the user.
+func (w *OCRWorker) publishOutcome(job *models.ScanJob, outcome string, err error) {
+	scan, findErr := w.scanRepo.FindByID(job.ScanID.String())
+	if findErr != nil {
+		log.Printf("OCR Worker: Failed to load scan %s for its status event: %v", job.ScanID, findErr)
+		return
+	}
+
+	resp := dto.ToScanResponse(scan, scan.ImageRef)
+	w.imageURLs.SignScan(context.Background(), &resp)
+	event := dto.NewScanEvent(&resp)
+	if outcome == outcomeRetried {
+		// Say why the attempt failed even though the scan is waiting again
+		failure := classifyError(err)
+		event.ErrorCode = &failure.code
+		event.ErrorMessage = &failure.message
+		event.RetryAt = &job.RunAt
+	}
+	w.publish(job, event)
+
+	// Webhooks only hear about final outcomes, and only the owner's
+	if job.UserID == nil {
+		return
+	}
+	switch outcome {
+	case outcomeCompleted:
+		w.webhooks.Publish(models.WebhookEventScanCompleted, job.UserID, resp)
+	case outcomeFailed, outcomeDeadLetter:
+		w.webhooks.Publish(models.WebhookEventScanFailed, job.UserID, resp)
+	}
+}
+
+// settleJob completes, retries or gives up on a job after an attempt
+func (w *OCRWorker) settleJob(id int, job *models.ScanJob, err error) string {
+	scanID := job.ScanID.String()
+
+	// Aborted by shutdown, not the scan's fault. Hand the job back without
+	// spending an attempt so another worker picks it up right away.
+	if err != nil && w.work.Err() != nil {
+		log.Printf("OCR Worker [%d]: Checkpointing scan %s for another worker", id, scanID)
+		if err := w.jobRepo.Unclaim(job); err != nil {
+			log.Printf("OCR Worker [%d]: Failed to return job %s to the queue: %v", id, job.ID, err)
+		}
+		if err := w.scanRepo.UpdateStatus(scanID, models.ScanStatusPending); err != nil {
+			log.Printf("OCR Worker [%d]: Failed to reset scan %s to pending: %v", id, scanID, err)
+		}
+		return outcomeAborted
+	}
+
+	if err == nil {
+		log.Printf("OCR Worker [%d]: Successfully processed scan %s", id, scanID)
+		if err := w.jobRepo.Complete(job); err != nil {
+			log.Printf("OCR Worker [%d]: Failed to complete job %s: %v", id, job.ID, err)
+		}
+		return outcomeCompleted
+	}
+
+	failure := classifyError(err)
+	log.Printf("OCR Worker [%d]: Failed to process scan %s (attempt %d/%d, %s): %v",
+		id, scanID, job.Attempts, job.MaxAttempts, failure.code, err)
+
+	if !failure.retryable {
+		if err := w.jobRepo.Fail(job, err.Error()); err != nil {
+			log.Printf("OCR Worker [%d]: Failed to mark job %s failed: %v", id, job.ID, err)
+		}
+		w.failScan(scanID, failure)
+		return outcomeFailed
+	}
+
+	if job.Exhausted() {
+		// Park the job for an admin to look at and requeue
+		if err := w.jobRepo.DeadLetter(job, err.Error()); err != nil {
+			log.Printf("OCR Worker [%d]: Failed to dead-letter job %s: %v", id, job.ID, err)
+		}
+		w.failScan(scanID, failure)
+		return outcomeDeadLetter
+	}
+
+	runAt := time.Now().Add(w.retryDelay(job.Attempts))
+	if err := w.jobRepo.Retry(job, runAt, err.Error()); err != nil {
+		log.Printf("OCR Worker [%d]: Failed to reschedule job %s: %v", id, job.ID, err)
+		return outcomeRetried
+	}
+	// Keep the job in step with the row for the retry event
+	job.RunAt = runAt
+
+	// The scan is waiting again rather than failed while a retry is scheduled
+	if err := w.scanRepo.UpdateStatus(scanID, models.ScanStatusPending); err != nil {
+		log.Printf("OCR Worker [%d]: Failed to reset scan %s to pending: %v", id, scanID, err)
+	}
+	return outcomeRetried
+}
+
+// saveTrace stores the stage timings on the scan and exports them as metrics
+func (w *OCRWorker) saveTrace(scanID string, trace *pipeline.Trace, outcome string) {
+	trace.Observe(outcome)
+
+	summary := trace.Summarize(outcome)
+	traceJSON, _ := json.Marshal(summary)
+	if err := w.scanRepo.UpdateTrace(scanID, traceJSON, int(summary.TotalMs)); err != nil {
+		log.Printf("OCR Worker: Failed to save trace for scan %s: %v", scanID, err)
+	}
+}
+
+// deadLetterJob parks a job that cannot be attempted again and fails its scan
+func (w *OCRWorker) deadLetterJob(job *models.ScanJob, reason string) {
+	if err := w.jobRepo.DeadLetter(job, reason); err != nil {
+		log.Printf("OCR Worker: Failed to dead-letter job %s: %v", job.ID, err)
+	}
+	w.failScan(job.ScanID.String(), scanFailure{
+		code:    ErrCodeInternal,
+		message: "Failed to process scan",
+	})
+}
+
+// failScan records the error code and message on the scan
+func (w *OCRWorker) failScan(scanID string, failure scanFailure) {
+	if err := w.scanRepo.MarkFailed(scanID, failure.code, failure.message); err != nil {
+		log.Printf("OCR Worker: Failed to mark scan %s failed: %v", scanID, err)
+	}
+}
+
+// retryDelay backs off from RetryDelay up to RetryMaxDelay
+func (w *OCRWorker) retryDelay(attempts int) time.Duration {
+	return backoff.Delay(w.config.RetryDelay, w.config.RetryMaxDelay, attempts)
 }
 
 func (w *OCRWorker) processScan(ctx context.Context, scanID string) error {
+	trace := pipeline.FromContext(ctx)
+
 	// 1. Get Scan
 	scan, err := w.scanRepo.FindByID(scanID)
 	if err != nil {
 		return fmt.Errorf("scan not found: %w", err)
 	}
 
-	if scan.ImageRef == nil || !scan.ImageStored {
-		return fmt.Errorf("no image to process")
+	if !scan.HasImages() {
+		return errNoImage
 	}
 
 	// Update status to processing
 	scan.Status = "processing"
+	stopPersist := trace.Measure(pipeline.StagePersist)
 	w.scanRepo.Update(scan)
+	stopPersist()
 
-	// 2. Run OCR
-	nutrients, servingSize, rawText, err := w.ocrService.ProcessImageFromStorage(ctx, *scan.ImageRef)
+	// 2. Run OCR on every image with the strategy for its kind and merge
+	// the pieces into a single product result
+	result, err := w.readImages(ctx, scan)
 	if err != nil {
-		scan.Status = "failed"
-		// Append error?
-		w.scanRepo.Update(scan)
+		var qualityErr *imagequality.Error
+		if errors.As(err, &qualityErr) {
+			// Keep the measurements so the retake hint can be explained
+			scan.QualityJSON, _ = json.Marshal(qualityErr.Report)
+			w.scanRepo.Update(scan)
+		}
 		return err
 	}
 
 	// Save Raw Text for debugging
-	scan.OCRRaw = &rawText
+	if result.nutritionText != "" {
+		scan.OCRRaw = &result.nutritionText
+	}
+	scan.OCRStrategy = result.strategy
+	scan.OCRConfidence = result.confidence
+	if result.quality != nil {
+		scan.QualityJSON, _ = json.Marshal(result.quality)
+	}
 
 	// 3. Process Nutrition Data (Analysis, Score, etc.)
-	// Calculate NutriScore
-	grade, score := nutrition.CalculateNutriScore(nutrients)
+	product, err := w.upsertProduct(ctx, scan, result)
+	if err != nil {
+		return fmt.Errorf("failed to save ocr product: %w", err)
+	}
+
+	// 4. Link Product to Scan and Complete
+	completeScan(scan, product)
+
+	defer trace.Measure(pipeline.StagePersist)()
+	if err := w.scanRepo.Update(scan); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+
+	return nil
+}
 
-	// Analyze Highlights & Insights
-	highlights, insights := nutrition.Analyze(nutrients)
+// completeScan links the product to the scan and marks it completed
+func completeScan(scan *models.Scan, product *models.Product) {
+	scan.ProductID = &product.ID
+	scan.Product = product
+
+	// Update redundant Scan fields (optional but good for consistency if queries use Scan table)
+	scan.NutriScore = product.NutriScore
+	scan.NutriScoreValue = product.NutriScoreValue
+	scan.HighlightsJSON = product.HighlightsJSON
+	scan.InsightsJSON = product.InsightsJSON
 
-	// Marshal JSONs
-	nutrientsJSON, _ := json.Marshal(nutrients)
-	highlightsJSON, _ := json.Marshal(highlights)
-	insightsJSON, _ := json.Marshal(insights)
+	parserVersion := nutrition.ParserVersion
+	scan.ParserVersion = &parserVersion
+	scan.Status = models.ScanStatusCompleted
+	scan.ErrorCode = nil
+	scan.ErrorMessage = nil
+}
 
-	ocrBarcode := fmt.Sprintf("ocr-%s", scanID)
+// labelResult is the merged outcome of reading all images of a scan
+type labelResult struct {
+	nutrients      *models.Nutrients
+	servingSize    string
+	nutritionText  string
+	nutritionTexts []string
+	strategy       *string
+	confidence     *float64
+	quality        *imagequality.Report
+	name           string
+	brand          string
+	ingredients    string
+}
 
-	var servingSizePtr *string
-	if servingSize != "" {
-		servingSizePtr = &servingSize
+// scanImages returns the images to read, falling back to the single
+// ImageRef of scans created before multi-image support
+func scanImages(scan *models.Scan) []models.ScanImage {
+	if len(scan.Images) > 0 {
+		return scan.Images
 	}
+	return []models.ScanImage{{
+		ScanID:   scan.ID,
+		Kind:     models.ScanImageNutrition,
+		ImageRef: *scan.ImageRef,
+	}}
+}
+
+func (w *OCRWorker) readImages(ctx context.Context, scan *models.Scan) (*labelResult, error) {
+	trace := pipeline.FromContext(ctx)
+	result := &labelResult{}
 
-	loc, _ := time.LoadLocation("Asia/Jakarta")
-	product := &models.Product{
-		Barcode:         ocrBarcode,
-		Name:            "Scanned Product " + time.Now().In(loc).Format("02-Jan 15:04"),
-		Source:          models.SourceOCRScan,
-		NutrientsJSON:   nutrientsJSON,
-		ServingSize:     servingSizePtr,
-		NutriScore:      &grade,
-		NutriScoreValue: &score,
-		HighlightsJSON:  highlightsJSON,
-		InsightsJSON:    insightsJSON,
+	for _, image := range scanImages(scan) {
+		ocrText, err := w.ocrService.ExtractText(ctx, image.ImageRef, image.Kind)
+		if ocrText != nil && ocrText.Quality != nil && image.ID != uuid.Nil {
+			image.QualityJSON, _ = json.Marshal(ocrText.Quality)
+		}
+		if err != nil {
+			if image.ID != uuid.Nil {
+				w.scanImageRepo.Update(&image)
+			}
+			return nil, fmt.Errorf("%s image: %w", image.Kind, err)
+		}
+		text := ocrText.Text
+
+		if image.ID != uuid.Nil {
+			image.OCRRaw = &text
+			image.OCRStrategy = &ocrText.Strategy
+			stopPersist := trace.Measure(pipeline.StagePersist)
+			if err := w.scanImageRepo.Update(&image); err != nil {
+				log.Printf("OCR Worker: Failed to save OCR text for image %s: %v", image.ID, err)
+			}
+			stopPersist()
+		}
+
+		stopParse := trace.Measure(pipeline.StageParse)
+		result.addText(image.Kind, text)
+		stopParse()
+
+		if image.Kind == models.ScanImageNutrition {
+			if result.quality == nil {
+				result.quality = ocrText.Quality
+			}
+			// Keep the strategy of the best scoring nutrition panel
+			if ocrText.Score != nil && (result.confidence == nil || *ocrText.Score > *result.confidence) {
+				result.confidence = ocrText.Score
+				result.strategy = &ocrText.Strategy
+			}
+		}
 	}
 
-	if err := w.productRepo.Create(product); err != nil {
-		// Possibly duplicate if re-scanning?
-		scan.Status = "failed"
-		w.scanRepo.Update(scan)
-		return fmt.Errorf("failed to create ocr product: %w", err)
+	result.nutritionText = strings.Join(result.nutritionTexts, "\n\n")
+	return result, nil
+}
+
+// addText parses the text read from one image into the result
+func (r *labelResult) addText(kind models.ScanImageKind, text string) {
+	switch kind {
+	case models.ScanImageNutrition:
+		nutrients, servingSize := nutrition.ParseFromText(text)
+		r.nutrients = nutrition.MergeNutrients(r.nutrients, nutrients)
+		if r.servingSize == "" {
+			r.servingSize = servingSize
+		}
+		r.nutritionTexts = append(r.nutritionTexts, text)
+	case models.ScanImageFront:
+		if name, brand := nutrition.ParseFrontText(text); name != "" {
+			r.name = name
+			r.brand = brand
+		}
+	case models.ScanImageIngredients:
+		if ingredients := nutrition.ParseIngredientsText(text); ingredients != "" {
+			r.ingredients = ingredients
+		}
 	}
+}
 
-	// 4. Link Product to Scan and Complete
-	scan.ProductID = &product.ID
+// upsertProduct creates or refreshes the OCR product that belongs to a scan
+func (w *OCRWorker) upsertProduct(ctx context.Context, scan *models.Scan, result *labelResult) (*models.Product, error) {
+	product, err := w.findOCRProduct(ctx, scan)
+	if err != nil {
+		return nil, err
+	}
+	applyLabel(ctx, product, result)
+	if err := w.saveOCRProduct(ctx, scan, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
 
-	// Update redundant Scan fields (optional but good for consistency if queries use Scan table)
-	scan.NutriScore = &grade
-	scan.NutriScoreValue = &score
-	scan.HighlightsJSON = highlightsJSON
-	scan.InsightsJSON = insightsJSON
+// findOCRProduct loads the OCR product of a scan, or starts a new one
+func (w *OCRWorker) findOCRProduct(ctx context.Context, scan *models.Scan) (*models.Product, error) {
+	ocrBarcode := fmt.Sprintf("ocr-%s", scan.ID)
 
-	scan.Status = models.ScanStatusCompleted
+	defer pipeline.FromContext(ctx).Measure(pipeline.StagePersist)()
+	product, err := w.productRepo.FindByBarcode(ocrBarcode)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrProductNotFound) {
+			return nil, err
+		}
+		product = &models.Product{
+			Barcode: ocrBarcode,
+			Source:  models.SourceOCRScan,
+		}
+	}
+	return product, nil
+}
 
-	if err := w.scanRepo.Update(scan); err != nil {
-		return fmt.Errorf("failed to update scan status: %w", err)
+// applyLabel writes what was read from a scan's images onto its product.
+// Nutrients, grade and analysis are kept when no nutrients were read.
+func applyLabel(ctx context.Context, product *models.Product, result *labelResult) {
+	if result.name != "" {
+		product.Name = result.name
+	} else if product.Name == "" {
+		loc, _ := time.LoadLocation("Asia/Jakarta")
+		product.Name = "Scanned Product " + time.Now().In(loc).Format("02-Jan 15:04")
+	}
+	product.Brand = nil
+	if result.brand != "" {
+		product.Brand = &result.brand
+	}
+	product.Ingredients = nil
+	if result.ingredients != "" {
+		product.Ingredients = &result.ingredients
+	}
+	product.ServingSize = nil
+	if result.servingSize != "" {
+		product.ServingSize = &result.servingSize
 	}
 
-	return nil
+	if result.nutrients != nil {
+		stopScore := pipeline.FromContext(ctx).Measure(pipeline.StageScore)
+
+		// Calculate NutriScore
+		grade, score := nutrition.CalculateNutriScore(result.nutrients)
+
+		// Analyze Highlights & Insights
+		highlights, insights := nutrition.Analyze(result.nutrients)
+
+		// Marshal JSONs
+		product.NutrientsJSON, _ = json.Marshal(result.nutrients)
+		product.HighlightsJSON, _ = json.Marshal(highlights)
+		product.InsightsJSON, _ = json.Marshal(insights)
+		product.NutriScore = &grade
+		product.NutriScoreValue = &score
+
+		stopScore()
+	}
+}
+
+// saveOCRProduct stores the OCR product of a scan
+func (w *OCRWorker) saveOCRProduct(ctx context.Context, scan *models.Scan, product *models.Product) error {
+	defer pipeline.FromContext(ctx).Measure(pipeline.StagePersist)()
+
+	var err error
+	if product.ID == uuid.Nil {
+		err = w.productRepo.Create(product)
+	} else {
+		err = w.productRepo.Update(product)
+		// An OCR product is read from one user's photos, so only the owner
+		// of the scan is told. Without an owner nobody is.
+		if err == nil && scan.UserID != nil {
+			w.webhooks.Publish(models.WebhookEventProductUpdated, scan.UserID, dto.ToProductResponse(product))
+		}
+	}
+	return err
 }
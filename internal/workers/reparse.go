@@ -0,0 +1,116 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/habbazettt/nutrisnap-server/internal/dto"
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/services"
+	"github.com/habbazettt/nutrisnap-server/pkg/nutrition"
+)
+
+// ReparseScan runs the current parser and scoring over the OCR text stored
+// on a scan, without reading the images again. With dryRun the scan is left
+// untouched and only the differences are reported. The dry run builds the
+// same product applying would save, so both report the same changes.
+func (w *OCRWorker) ReparseScan(ctx context.Context, scan *models.Scan, dryRun bool) (*dto.ScanReparseResult, error) {
+	result := &labelResult{}
+	for _, image := range scan.Images {
+		if image.OCRRaw != nil {
+			result.addText(image.Kind, *image.OCRRaw)
+		}
+	}
+	// Scans read before per-image text was kept only have the nutrition
+	// text on the scan itself
+	if len(result.nutritionTexts) == 0 && scan.OCRRaw != nil {
+		result.addText(models.ScanImageNutrition, *scan.OCRRaw)
+	}
+	if len(result.nutritionTexts) == 0 && result.name == "" && result.ingredients == "" {
+		return nil, services.ErrNoOCRText
+	}
+	result.nutritionText = strings.Join(result.nutritionTexts, "\n\n")
+
+	product, err := w.findOCRProduct(ctx, scan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ocr product: %w", err)
+	}
+	before := *product
+	applyLabel(ctx, product, result)
+
+	// The new side is what the scan shows once completeScan has linked the
+	// updated product
+	diff := &dto.ScanReparseResult{
+		ScanID:             scan.ID.String(),
+		Nutrients:          nutrition.DiffNutrients(shownNutrients(scan, &before), shownNutrients(scan, product)),
+		Fields:             changedFields(&before, product),
+		OldNutriScore:      scan.NutriScore,
+		NewNutriScore:      product.NutriScore,
+		OldNutriScoreValue: scan.NutriScoreValue,
+		NewNutriScoreValue: product.NutriScoreValue,
+		OldParserVersion:   scan.ParserVersion,
+		NewParserVersion:   nutrition.ParserVersion,
+	}
+	diff.Changed = len(diff.Nutrients) > 0 || len(diff.Fields) > 0 ||
+		!equalPtr(diff.OldNutriScore, diff.NewNutriScore) ||
+		!equalPtr(diff.OldNutriScoreValue, diff.NewNutriScoreValue)
+
+	if dryRun {
+		return diff, nil
+	}
+
+	if err := w.saveOCRProduct(ctx, scan, product); err != nil {
+		return nil, fmt.Errorf("failed to save ocr product: %w", err)
+	}
+	completeScan(scan, product)
+	if err := w.scanRepo.Update(scan); err != nil {
+		return nil, fmt.Errorf("failed to update scan: %w", err)
+	}
+
+	diff.Applied = true
+	return diff, nil
+}
+
+// shownNutrients returns the nutrients a scan shows with the given product
+func shownNutrients(scan *models.Scan, product *models.Product) *models.Nutrients {
+	data := scan.ParsedJSON
+	if len(product.NutrientsJSON) > 0 {
+		data = product.NutrientsJSON
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var n models.Nutrients
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil
+	}
+	return &n
+}
+
+// changedFields lists the text fields of a product that differ
+func changedFields(before, after *models.Product) []string {
+	var fields []string
+	if before.Name != after.Name {
+		fields = append(fields, "name")
+	}
+	if !equalPtr(before.Brand, after.Brand) {
+		fields = append(fields, "brand")
+	}
+	if !equalPtr(before.Ingredients, after.Ingredients) {
+		fields = append(fields, "ingredients")
+	}
+	if !equalPtr(before.ServingSize, after.ServingSize) {
+		fields = append(fields, "serving_size")
+	}
+	return fields
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
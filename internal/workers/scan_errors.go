@@ -0,0 +1,64 @@
+package workers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/internal/services"
+	"github.com/habbazettt/nutrisnap-server/pkg/imagequality"
+	"github.com/habbazettt/nutrisnap-server/pkg/ocr"
+	"github.com/habbazettt/nutrisnap-server/pkg/storage"
+)
+
+// Error codes stored on failed scans. Quality rejections use "image_"
+// followed by the quality issue, e.g. "image_blurry".
+const (
+	ErrCodeScanNotFound   = "scan_not_found"
+	ErrCodeNoImage        = "no_image"
+	ErrCodeImageMissing   = "image_missing"
+	ErrCodeInvalidImage   = "invalid_image"
+	ErrCodeDownload       = "download_failed"
+	ErrCodeOCRUnavailable = "ocr_unavailable"
+	ErrCodeTimeout        = "timeout"
+	ErrCodeInternal       = "internal_error"
+)
+
+var errNoImage = errors.New("no image to process")
+
+// scanFailure is a classified processing error
+type scanFailure struct {
+	code      string
+	message   string
+	retryable bool
+}
+
+// classifyError decides whether a failed scan is worth retrying and what
+// to tell the user. Unknown errors are retried, since giving up on a scan
+// that would have succeeded is worse than a wasted attempt.
+func classifyError(err error) scanFailure {
+	var qualityErr *imagequality.Error
+	switch {
+	case errors.As(err, &qualityErr):
+		return scanFailure{
+			code:    "image_" + string(qualityErr.Issue()),
+			message: imagequality.RetakeHint(qualityErr.Issue())["en"],
+		}
+	case errors.Is(err, repositories.ErrScanNotFound):
+		return scanFailure{code: ErrCodeScanNotFound, message: "Scan no longer exists"}
+	case errors.Is(err, errNoImage):
+		return scanFailure{code: ErrCodeNoImage, message: "Scan has no image to read"}
+	case errors.Is(err, storage.ErrNotFound):
+		return scanFailure{code: ErrCodeImageMissing, message: "Image is no longer available, please upload it again"}
+	case errors.Is(err, services.ErrInvalidImage):
+		return scanFailure{code: ErrCodeInvalidImage, message: "Image could not be decoded, please upload a JPEG, PNG or WebP photo"}
+	case errors.Is(err, services.ErrImageDownload):
+		return scanFailure{code: ErrCodeDownload, message: "Could not download the image", retryable: true}
+	case errors.Is(err, ocr.ErrAcquireTimeout), errors.Is(err, ocr.ErrPoolClosed):
+		return scanFailure{code: ErrCodeOCRUnavailable, message: "OCR is busy, the scan will be retried", retryable: true}
+	case errors.Is(err, context.DeadlineExceeded):
+		return scanFailure{code: ErrCodeTimeout, message: "Processing took too long", retryable: true}
+	default:
+		return scanFailure{code: ErrCodeInternal, message: "Failed to process scan", retryable: true}
+	}
+}
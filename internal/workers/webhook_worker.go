@@ -0,0 +1,222 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/internal/repositories"
+	"github.com/habbazettt/nutrisnap-server/pkg/backoff"
+	"github.com/habbazettt/nutrisnap-server/pkg/webhook"
+)
+
+// WebhookConfig controls how webhook deliveries are sent and retried
+type WebhookConfig struct {
+	PollInterval  time.Duration
+	Timeout       time.Duration
+	RetryDelay    time.Duration
+	RetryMaxDelay time.Duration
+	// DisableAfter is how many failed attempts in a row disable a webhook
+	DisableAfter int
+	// AllowPrivateTargets lets deliveries reach loopback and private
+	// networks, for receivers running next to a development server
+	AllowPrivateTargets bool
+}
+
+const (
+	// webhookUserAgent identifies deliveries to receivers
+	webhookUserAgent = "NutriSnap-Webhooks/1.0"
+	// maxWebhookResponseBody bounds how much of a receiver's response is kept
+	// in the delivery log
+	maxWebhookResponseBody = 1024
+)
+
+// WebhookWorker sends queued webhook deliveries. Like scan jobs, deliveries
+// live in Postgres and are shared between instances through SKIP LOCKED.
+type WebhookWorker struct {
+	webhookRepo repositories.WebhookRepository
+	client      *http.Client
+	config      WebhookConfig
+
+	stop   context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewWebhookWorker(webhookRepo repositories.WebhookRepository, config WebhookConfig) *WebhookWorker {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Deliveries connect to the receiver directly, so the dialer checks the
+	// receiver's own address and a response is only ever read, and kept in
+	// the delivery log, from a public one
+	transport.Proxy = nil
+	if !config.AllowPrivateTargets {
+		transport.DialContext = webhook.NewDialer(config.Timeout).DialContext
+	}
+
+	return &WebhookWorker{
+		webhookRepo: webhookRepo,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+			// A redirect could send the signed payload somewhere the owner
+			// never registered, so treat it as the response
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		config: config,
+	}
+}
+
+// Start launches 'workers' number of delivery goroutines
+func (w *WebhookWorker) Start(workers int) {
+	w.stop, w.cancel = context.WithCancel(context.Background())
+	for i := 0; i < workers; i++ {
+		w.wg.Add(1)
+		go w.run(i)
+	}
+	log.Printf("Webhook Worker: Started %d worker(s)", workers)
+}
+
+// Shutdown stops claiming deliveries and waits for those in flight, which
+// take at most the configured timeout. A delivery cut short is retried
+// once its lock expires.
+func (w *WebhookWorker) Shutdown(ctx context.Context) error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("Webhook Worker: All workers stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *WebhookWorker) run(id int) {
+	defer w.wg.Done()
+
+	// Leave room past the request timeout before another worker may take
+	// the delivery over
+	visibility := w.config.Timeout + 30*time.Second
+
+	for {
+		if w.stop.Err() != nil {
+			return
+		}
+
+		delivery, err := w.webhookRepo.ClaimDelivery(visibility)
+		if err == nil {
+			w.deliver(id, delivery)
+			continue
+		}
+		if !errors.Is(err, repositories.ErrNoWebhookDelivery) {
+			log.Printf("Webhook Worker [%d]: Failed to claim delivery: %v", id, err)
+		}
+
+		select {
+		case <-time.After(w.config.PollInterval):
+		case <-w.stop.Done():
+		}
+	}
+}
+
+func (w *WebhookWorker) deliver(id int, delivery *models.WebhookDelivery) {
+	subscription := delivery.Subscription
+	if !subscription.Active {
+		if err := w.webhookRepo.FailDelivery(delivery, "Webhook is disabled"); err != nil {
+			log.Printf("Webhook Worker [%d]: Failed to update delivery %s: %v", id, delivery.ID, err)
+		}
+		return
+	}
+
+	err := w.send(delivery)
+	if err == nil {
+		err = w.webhookRepo.MarkDelivered(delivery)
+		if errors.Is(err, repositories.ErrWebhookDeliveryLockLost) {
+			log.Printf("Webhook Worker [%d]: Delivery %s was taken over by another worker before it was marked delivered", id, delivery.ID)
+		} else if err != nil {
+			log.Printf("Webhook Worker [%d]: Failed to mark delivery %s delivered: %v", id, delivery.ID, err)
+		}
+		return
+	}
+
+	message := err.Error()
+	delivery.LastError = &message
+	delivery.NextAttemptAt = time.Now().Add(backoff.Delay(w.config.RetryDelay, w.config.RetryMaxDelay, delivery.Attempts))
+	log.Printf("Webhook Worker [%d]: Delivery %s of %s to webhook %s failed (attempt %d/%d): %v",
+		id, delivery.ID, delivery.EventType, subscription.ID, delivery.Attempts, delivery.MaxAttempts, err)
+
+	disabled, err := w.webhookRepo.MarkAttemptFailed(delivery, w.config.DisableAfter)
+	if errors.Is(err, repositories.ErrWebhookDeliveryLockLost) {
+		log.Printf("Webhook Worker [%d]: Delivery %s was taken over by another worker, leaving its retry to it", id, delivery.ID)
+		return
+	}
+	if err != nil {
+		log.Printf("Webhook Worker [%d]: Failed to record failed delivery %s: %v", id, delivery.ID, err)
+		return
+	}
+	if disabled {
+		log.Printf("Webhook Worker [%d]: Disabled webhook %s after %d failures in a row",
+			id, subscription.ID, w.config.DisableAfter)
+	}
+}
+
+// send POSTs the signed payload and records the outcome on the delivery.
+// Any 2xx response counts as delivered.
+func (w *WebhookWorker) send(delivery *models.WebhookDelivery) error {
+	subscription := delivery.Subscription
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(webhook.HeaderEvent, string(delivery.EventType))
+	req.Header.Set(webhook.HeaderDelivery, delivery.ID.String())
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(subscription.Secret, time.Now(), delivery.Payload))
+
+	start := time.Now()
+	resp, err := w.client.Do(req)
+	durationMs := int(time.Since(start).Milliseconds())
+	delivery.DurationMs = &durationMs
+	delivery.LastStatusCode = nil
+	delivery.ResponseBody = nil
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseBody))
+	// Drain a little more so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	statusCode := resp.StatusCode
+	delivery.LastStatusCode = &statusCode
+	if len(body) > 0 {
+		text := string(bytes.ToValidUTF8(body, nil))
+		delivery.ResponseBody = &text
+	}
+
+	if statusCode < 200 || statusCode > 299 {
+		return fmt.Errorf("receiver responded with status %d", statusCode)
+	}
+	return nil
+}
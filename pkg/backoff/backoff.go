@@ -0,0 +1,25 @@
+// Package backoff computes retry delays shared by everything that retries
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Delay is exponential backoff with jitter for the given attempt, counted
+// from 1. The delay doubles with every attempt up to max, and a random half
+// of it is used so work that failed together does not retry together.
+func Delay(base, max time.Duration, attempt int) time.Duration {
+	delay := max
+	// A first attempt, or none yet, waits the base delay
+	if shift := attempt - 1; shift < 1 {
+		delay = min(base, max)
+	} else if shift < 32 {
+		if d := base << shift; d > 0 && d < delay {
+			delay = d
+		}
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
@@ -0,0 +1,47 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	maxDelay := 2 * time.Second
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, base},
+		{1, base},
+		{2, 2 * base},
+		{3, 4 * base},
+		{5, 16 * base},
+		{6, maxDelay},
+		{40, maxDelay},
+		{1000, maxDelay},
+	}
+
+	for _, tt := range tests {
+		lowest, highest := tt.want, time.Duration(0)
+		for i := 0; i < 200; i++ {
+			got := Delay(base, maxDelay, tt.attempts)
+			if got < tt.want/2 || got > tt.want {
+				t.Fatalf("Delay(%d) = %s, want between %s and %s", tt.attempts, got, tt.want/2, tt.want)
+			}
+			lowest = min(lowest, got)
+			highest = max(highest, got)
+		}
+		// Jitter spreads retries of work that failed together
+		if lowest == highest {
+			t.Errorf("Delay(%d) always returned %s, want jitter", tt.attempts, lowest)
+		}
+	}
+}
+
+func TestDelayBaseAboveMax(t *testing.T) {
+	if got := Delay(time.Minute, time.Second, 1); got > time.Second {
+		t.Errorf("Delay() = %s, want at most the 1s maximum", got)
+	}
+}
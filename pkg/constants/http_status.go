@@ -29,13 +29,22 @@ var HTTPStatusMap = map[int]int{
 	StatusOAuthError:          401,
 
 	// Scan Errors -> 400/404
-	StatusScanNotFound:      404,
-	StatusScanInProgress:    202,
-	StatusScanFailed:        400,
-	StatusInvalidImage:      400,
-	StatusImageTooLarge:     413,
-	StatusOCRFailed:         422,
-	StatusNutritionNotFound: 422,
+	StatusScanNotFound:            404,
+	StatusScanInProgress:          202,
+	StatusScanFailed:              400,
+	StatusInvalidImage:            400,
+	StatusImageTooLarge:           413,
+	StatusOCRFailed:               422,
+	StatusNutritionNotFound:       422,
+	StatusImageTooSmall:           422,
+	StatusImageBlurry:             422,
+	StatusImageGlare:              422,
+	StatusImageNoText:             422,
+	StatusImageUnsupported:        415,
+	StatusImageCorrupt:            422,
+	StatusImagePolyglot:           422,
+	StatusImageDimensionsTooLarge: 422,
+	StatusImageTooManyPixels:      413,
 
 	// Product Errors -> 400/404
 	StatusProductNotFound:  404,
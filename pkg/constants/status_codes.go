@@ -34,13 +34,22 @@ const (
 	StatusOAuthError          = 400109 // OAuth authentication error
 
 	// ========== CLIENT ERRORS - Scan (4002XX) ==========
-	StatusScanNotFound      = 400200 // Scan not found
-	StatusScanInProgress    = 400201 // Scan still processing
-	StatusScanFailed        = 400202 // Scan processing failed
-	StatusInvalidImage      = 400203 // Invalid image format
-	StatusImageTooLarge     = 400204 // Image size too large
-	StatusOCRFailed         = 400205 // OCR processing failed
-	StatusNutritionNotFound = 400206 // Nutrition data not found
+	StatusScanNotFound            = 400200 // Scan not found
+	StatusScanInProgress          = 400201 // Scan still processing
+	StatusScanFailed              = 400202 // Scan processing failed
+	StatusInvalidImage            = 400203 // Invalid image format
+	StatusImageTooLarge           = 400204 // Image size too large
+	StatusOCRFailed               = 400205 // OCR processing failed
+	StatusNutritionNotFound       = 400206 // Nutrition data not found
+	StatusImageTooSmall           = 400207 // Image dimensions below minimum
+	StatusImageBlurry             = 400208 // Image too blurry to read
+	StatusImageGlare              = 400209 // Image overexposed or has glare
+	StatusImageNoText             = 400210 // No readable text in image
+	StatusImageUnsupported        = 400211 // File is not a JPEG, PNG or WebP image
+	StatusImageCorrupt            = 400212 // Image file is truncated or malformed
+	StatusImagePolyglot           = 400213 // Image file carries data after the image
+	StatusImageDimensionsTooLarge = 400214 // Image dimensions above maximum
+	StatusImageTooManyPixels      = 400215 // Image pixel count above maximum
 
 	// ========== CLIENT ERRORS - Product (4003XX) ==========
 	StatusProductNotFound  = 400300 // Product not found
@@ -102,13 +111,22 @@ var StatusMessages = map[int]string{
 	StatusOAuthError:          "OAuth authentication failed",
 
 	// Client Errors - Scan
-	StatusScanNotFound:      "Scan not found",
-	StatusScanInProgress:    "Scan is still processing",
-	StatusScanFailed:        "Scan processing failed",
-	StatusInvalidImage:      "Invalid image format",
-	StatusImageTooLarge:     "Image size too large",
-	StatusOCRFailed:         "OCR processing failed",
-	StatusNutritionNotFound: "Nutrition data not found in image",
+	StatusScanNotFound:            "Scan not found",
+	StatusScanInProgress:          "Scan is still processing",
+	StatusScanFailed:              "Scan processing failed",
+	StatusInvalidImage:            "Invalid image format",
+	StatusImageTooLarge:           "Image size too large",
+	StatusOCRFailed:               "OCR processing failed",
+	StatusNutritionNotFound:       "Nutrition data not found in image",
+	StatusImageTooSmall:           "Image resolution is too low",
+	StatusImageBlurry:             "Image is too blurry",
+	StatusImageGlare:              "Image has too much glare",
+	StatusImageNoText:             "No readable text found in image",
+	StatusImageUnsupported:        "Unsupported image format",
+	StatusImageCorrupt:            "Image file is corrupt",
+	StatusImagePolyglot:           "Image file contains data that is not part of the image",
+	StatusImageDimensionsTooLarge: "Image dimensions are too large",
+	StatusImageTooManyPixels:      "Image has too many pixels",
 
 	// Client Errors - Product
 	StatusProductNotFound:  "Product not found",
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -21,15 +23,20 @@ type Config struct {
 	SSLMode  string
 }
 
-func Connect(cfg Config) (*gorm.DB, error) {
+// DSN returns the connection string for the config
+func (cfg Config) DSN() string {
 	if cfg.SSLMode == "" {
 		cfg.SSLMode = "disable"
 	}
 
-	dsn := fmt.Sprintf(
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
 	)
+}
+
+func Connect(cfg Config) (*gorm.DB, error) {
+	dsn := cfg.DSN()
 
 	gormConfig := &gorm.Config{
 		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
@@ -82,6 +89,18 @@ func Close() error {
 	return nil
 }
 
+// Ping checks that the database is reachable
+func Ping(ctx context.Context) error {
+	if DB == nil {
+		return errors.New("database not connected")
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 // GetDB returns the global database instance
 func GetDB() *gorm.DB {
 	return DB
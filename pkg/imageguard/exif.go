@@ -0,0 +1,103 @@
+package imageguard
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const gpsIFDTag = 0x8825
+
+// exifPrefix starts EXIF data in JPEG APP1 segments and some WebP files
+var exifPrefix = []byte("Exif\x00\x00")
+
+// typeSizes holds the size of one value of each TIFF field type
+var typeSizes = map[uint16]uint64{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// stripGPS returns a copy of EXIF data with the GPS IFD removed from IFD0
+// and its contents zeroed. The data may start with the "Exif" prefix.
+// Unreadable EXIF returns ok false, and callers drop it entirely, since
+// it cannot be shown to be free of GPS data.
+func stripGPS(exif []byte) (out []byte, ok bool) {
+	out = bytes.Clone(exif)
+	tiff := out
+	if bytes.HasPrefix(tiff, exifPrefix) {
+		tiff = tiff[len(exifPrefix):]
+	}
+	if len(tiff) < 8 {
+		return nil, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, false
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return nil, false
+	}
+
+	ifd := uint64(order.Uint32(tiff[4:]))
+	count, ok := ifdCount(tiff, order, ifd)
+	if !ok {
+		return nil, false
+	}
+	// Entries are followed by the offset of the next IFD
+	end := ifd + 2 + 12*count + 4
+
+	for i := uint64(0); i < count; i++ {
+		entry := ifd + 2 + 12*i
+		if order.Uint16(tiff[entry:]) != gpsIFDTag {
+			continue
+		}
+		if !clearIFD(tiff, order, uint64(order.Uint32(tiff[entry+8:]))) {
+			return nil, false
+		}
+		copy(tiff[entry:end], tiff[entry+12:end])
+		clear(tiff[end-12 : end])
+		order.PutUint16(tiff[ifd:], uint16(count-1))
+		return out, true
+	}
+	return out, true
+}
+
+// ifdCount returns the number of entries of the IFD at offset, checking
+// that its entries and next IFD offset lie within tiff
+func ifdCount(tiff []byte, order binary.ByteOrder, offset uint64) (uint64, bool) {
+	if offset+2 > uint64(len(tiff)) {
+		return 0, false
+	}
+	count := uint64(order.Uint16(tiff[offset:]))
+	if offset+2+12*count+4 > uint64(len(tiff)) {
+		return 0, false
+	}
+	return count, true
+}
+
+// clearIFD zeroes an IFD together with the values it stores outside of
+// its entries
+func clearIFD(tiff []byte, order binary.ByteOrder, offset uint64) bool {
+	count, ok := ifdCount(tiff, order, offset)
+	if !ok {
+		return false
+	}
+	for i := uint64(0); i < count; i++ {
+		entry := offset + 2 + 12*i
+		size := typeSizes[order.Uint16(tiff[entry+2:])] * uint64(order.Uint32(tiff[entry+4:]))
+		if size <= 4 {
+			continue
+		}
+		value := uint64(order.Uint32(tiff[entry+8:]))
+		if value+size > uint64(len(tiff)) {
+			return false
+		}
+		clear(tiff[value : value+size])
+	}
+	clear(tiff[offset : offset+2+12*count+4])
+	return true
+}
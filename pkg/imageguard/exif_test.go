@@ -0,0 +1,168 @@
+package imageguard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// gpsValue stands in for coordinates, so tests can check they are gone
+var gpsValue = []byte("LATITUDE-DEGREES-MINUTES")
+
+// tiffOrder is a byte order that can also append
+type tiffOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// buildEXIF returns TIFF data whose IFD0 holds a Make entry stored outside
+// the entry and, with gps, a GPS IFD holding gpsValue
+func buildEXIF(order tiffOrder, gps bool) []byte {
+	entries := uint16(1)
+	if gps {
+		entries = 2
+	}
+	const ifd0 = 8
+	makeOffset := ifd0 + 2 + 12*uint32(entries) + 4
+	gpsIFD := makeOffset + 6
+	gpsValueOffset := gpsIFD + 2 + 12 + 4
+
+	var b []byte
+	if order == binary.LittleEndian {
+		b = append(b, "II"...)
+	} else {
+		b = append(b, "MM"...)
+	}
+	b = order.AppendUint16(b, 42)
+	b = order.AppendUint32(b, ifd0)
+
+	b = order.AppendUint16(b, entries)
+	// Make, ASCII, 6 bytes
+	b = order.AppendUint16(b, 0x010F)
+	b = order.AppendUint16(b, 2)
+	b = order.AppendUint32(b, 6)
+	b = order.AppendUint32(b, makeOffset)
+	if gps {
+		b = order.AppendUint16(b, gpsIFDTag)
+		b = order.AppendUint16(b, 4)
+		b = order.AppendUint32(b, 1)
+		b = order.AppendUint32(b, gpsIFD)
+	}
+	b = order.AppendUint32(b, 0)
+	b = append(b, "Canon\x00"...)
+
+	if gps {
+		b = order.AppendUint16(b, 1)
+		// GPSLatitude, three RATIONALs
+		b = order.AppendUint16(b, 0x0002)
+		b = order.AppendUint16(b, 5)
+		b = order.AppendUint32(b, 3)
+		b = order.AppendUint32(b, gpsValueOffset)
+		b = order.AppendUint32(b, 0)
+		b = append(b, gpsValue...)
+	}
+	return b
+}
+
+// ifd0Tags lists the tags of IFD0 in TIFF data
+func ifd0Tags(t *testing.T, tiff []byte) []uint16 {
+	t.Helper()
+	order := binary.ByteOrder(binary.LittleEndian)
+	if string(tiff[:2]) == "MM" {
+		order = binary.BigEndian
+	}
+	ifd := uint64(order.Uint32(tiff[4:]))
+	count, ok := ifdCount(tiff, order, ifd)
+	if !ok {
+		t.Fatal("IFD0 is out of range")
+	}
+	tags := make([]uint16, count)
+	for i := range tags {
+		tags[i] = order.Uint16(tiff[ifd+2+12*uint64(i):])
+	}
+	return tags
+}
+
+func TestStripGPS(t *testing.T) {
+	tests := []struct {
+		name  string
+		order tiffOrder
+		exif  bool
+	}{
+		{"little endian", binary.LittleEndian, false},
+		{"big endian", binary.BigEndian, false},
+		{"with Exif prefix", binary.LittleEndian, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := buildEXIF(tt.order, true)
+			if tt.exif {
+				in = append(bytes.Clone(exifPrefix), in...)
+			}
+			original := bytes.Clone(in)
+
+			out, ok := stripGPS(in)
+			if !ok {
+				t.Fatal("stripGPS() ok = false")
+			}
+			if !bytes.Equal(in, original) {
+				t.Error("stripGPS() modified its input")
+			}
+			if len(out) != len(in) {
+				t.Errorf("len = %d, want %d, offsets must stay valid", len(out), len(in))
+			}
+			if bytes.Contains(out, gpsValue) {
+				t.Error("GPS values are still present")
+			}
+			if !bytes.Contains(out, []byte("Canon")) {
+				t.Error("Make value was removed")
+			}
+
+			tiff := bytes.TrimPrefix(out, exifPrefix)
+			if tags := ifd0Tags(t, tiff); len(tags) != 1 || tags[0] != 0x010F {
+				t.Errorf("IFD0 tags = %#x, want only Make", tags)
+			}
+		})
+	}
+}
+
+func TestStripGPSWithoutGPS(t *testing.T) {
+	in := buildEXIF(binary.LittleEndian, false)
+	out, ok := stripGPS(in)
+	if !ok {
+		t.Fatal("stripGPS() ok = false")
+	}
+	if !bytes.Equal(out, in) {
+		t.Error("stripGPS() changed EXIF without GPS data")
+	}
+}
+
+func TestStripGPSUnreadable(t *testing.T) {
+	valid := buildEXIF(binary.LittleEndian, true)
+
+	badGPSOffset := bytes.Clone(valid)
+	// The GPS entry is IFD0's second, its value holds the IFD offset
+	binary.LittleEndian.PutUint32(badGPSOffset[8+2+12+8:], 0xFFFFFF)
+
+	badValueOffset := bytes.Clone(valid)
+	gpsIFD := binary.LittleEndian.Uint32(valid[8+2+12+8:])
+	binary.LittleEndian.PutUint32(badValueOffset[gpsIFD+2+8:], 0xFFFFFF)
+
+	tests := map[string][]byte{
+		"empty":             nil,
+		"short":             []byte("II*\x00"),
+		"unknown order":     append([]byte("XX"), valid[2:]...),
+		"wrong magic":       append([]byte("II\x2b\x00"), valid[4:]...),
+		"IFD0 out of range": append([]byte("II*\x00\xff\xff\x00\x00"), valid[8:]...),
+		"GPS IFD offset":    badGPSOffset,
+		"GPS value offset":  badValueOffset,
+	}
+	for name, exif := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, ok := stripGPS(exif); ok {
+				t.Error("stripGPS() ok = true, want false")
+			}
+		})
+	}
+}
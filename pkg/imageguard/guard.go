@@ -0,0 +1,171 @@
+// Package imageguard checks untrusted image uploads before anything
+// decodes them in full, and strips metadata that should not be stored.
+package imageguard
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	// Register the header decoders for the accepted formats
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Format is an accepted image format, named as the image package names it
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+)
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Reason says why an image was rejected
+type Reason string
+
+const (
+	ReasonUnsupported   Reason = "unsupported_format"
+	ReasonCorrupt       Reason = "corrupt"
+	ReasonPolyglot      Reason = "polyglot"
+	ReasonTooSmall      Reason = "too_small"
+	ReasonTooLarge      Reason = "too_large"
+	ReasonTooManyPixels Reason = "too_many_pixels"
+)
+
+// Error is returned for a rejected image
+type Error struct {
+	Reason Reason
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("image rejected (%s): %s", e.Reason, e.Detail)
+}
+
+func reject(reason Reason, format string, args ...any) error {
+	return &Error{Reason: reason, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Limits bounds the dimensions of accepted images. Zero means no limit.
+type Limits struct {
+	MinWidth  int
+	MinHeight int
+	MaxWidth  int
+	MaxHeight int
+	// MaxPixels bounds width times height, which is what decoding an
+	// image allocates memory for
+	MaxPixels int
+}
+
+// DefaultLimits returns default dimension limits
+func DefaultLimits() Limits {
+	return Limits{
+		MinWidth:  200,
+		MinHeight: 200,
+		MaxWidth:  12000,
+		MaxHeight: 12000,
+		MaxPixels: 50_000_000,
+	}
+}
+
+// Result describes an accepted image
+type Result struct {
+	Format Format
+	Width  int
+	Height int
+	// Data is the image with GPS data, XMP packets, comments and text
+	// chunks removed. It is the input itself when there was nothing to
+	// remove.
+	Data []byte
+}
+
+// Sniff returns the format of data from its magic bytes, or "" when it is
+// not an accepted format
+func Sniff(data []byte) Format {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return FormatWebP
+	}
+	return ""
+}
+
+// Inspect checks an uploaded image without decoding its pixels. The format
+// comes from the magic bytes, the dimensions from the image header, and
+// the container is walked to its end so that files carrying anything
+// after the image are refused. Returned errors are *Error.
+func Inspect(data []byte, limits Limits) (*Result, error) {
+	format := Sniff(data)
+	if format == "" {
+		return nil, reject(ReasonUnsupported, "not a JPEG, PNG or WebP file")
+	}
+
+	config, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, reject(ReasonCorrupt, "unreadable %s header: %v", format, err)
+	}
+	if Format(name) != format {
+		return nil, reject(ReasonCorrupt, "%s file decodes as %s", format, name)
+	}
+	if err := checkDimensions(config.Width, config.Height, limits); err != nil {
+		return nil, err
+	}
+
+	var sanitized []byte
+	switch format {
+	case FormatJPEG:
+		sanitized, err = sanitizeJPEG(data)
+	case FormatPNG:
+		sanitized, err = sanitizePNG(data)
+	case FormatWebP:
+		sanitized, err = sanitizeWebP(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(sanitized, data) {
+		sanitized = data
+	}
+
+	return &Result{
+		Format: format,
+		Width:  config.Width,
+		Height: config.Height,
+		Data:   sanitized,
+	}, nil
+}
+
+func checkDimensions(width, height int, limits Limits) error {
+	if width < limits.MinWidth || height < limits.MinHeight {
+		return reject(ReasonTooSmall, "%dx%d is below the minimum of %dx%d", width, height, limits.MinWidth, limits.MinHeight)
+	}
+	if (limits.MaxWidth > 0 && width > limits.MaxWidth) || (limits.MaxHeight > 0 && height > limits.MaxHeight) {
+		return reject(ReasonTooLarge, "%dx%d is above the maximum of %dx%d", width, height, limits.MaxWidth, limits.MaxHeight)
+	}
+	if limits.MaxPixels > 0 && int64(width)*int64(height) > int64(limits.MaxPixels) {
+		return reject(ReasonTooManyPixels, "%dx%d is more than %d pixels", width, height, limits.MaxPixels)
+	}
+	return nil
+}
+
+// onlyPadding reports whether trailing bytes are zero padding some
+// writers leave after the image
+func onlyPadding(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
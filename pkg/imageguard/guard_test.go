@@ -0,0 +1,75 @@
+package imageguard
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	limits := Limits{MinWidth: 10, MinHeight: 10, MaxWidth: 100, MaxHeight: 100, MaxPixels: 5000}
+	plain := encodePNG(t, 32, 16)
+
+	result, err := Inspect(plain, limits)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if result.Format != FormatPNG || result.Width != 32 || result.Height != 16 {
+		t.Errorf("Inspect() = %s %dx%d, want png 32x16", result.Format, result.Width, result.Height)
+	}
+	if &result.Data[0] != &plain[0] {
+		t.Error("Inspect() copied an image it did not change")
+	}
+
+	withText := withChunks(plain, pngChunk("tEXt", []byte("Comment\x00hello")))
+	result, err = Inspect(withText, limits)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if !bytes.Equal(result.Data, plain) {
+		t.Error("Inspect() did not return the sanitized image")
+	}
+}
+
+func TestInspectRejects(t *testing.T) {
+	limits := Limits{MinWidth: 10, MinHeight: 10, MaxWidth: 100, MaxHeight: 100, MaxPixels: 5000}
+	jpeg := encodeJPEG(t, 32, 32)
+	// A PNG signature in front of JPEG data
+	mislabelled := append([]byte("\x89PNG\r\n\x1a\n"), jpeg...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		reason Reason
+	}{
+		{"unsupported", []byte("GIF89a..........."), ReasonUnsupported},
+		{"corrupt header", mislabelled, ReasonCorrupt},
+		{"too small", encodePNG(t, 8, 32), ReasonTooSmall},
+		{"too large", encodePNG(t, 101, 20), ReasonTooLarge},
+		{"too many pixels", encodePNG(t, 80, 80), ReasonTooManyPixels},
+		{"polyglot", append(bytes.Clone(jpeg), "PK\x03\x04"...), ReasonPolyglot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Inspect(tt.data, limits)
+			if got := rejectReason(t, err); got != tt.reason {
+				t.Errorf("reason = %s, want %s", got, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSniff(t *testing.T) {
+	tests := map[string]Format{
+		"\xff\xd8\xff\xe0":         FormatJPEG,
+		"\x89PNG\r\n\x1a\n":        FormatPNG,
+		"RIFF\x00\x00\x00\x00WEBP": FormatWebP,
+		"RIFF\x00\x00\x00\x00WAVE": "",
+		"<svg>":                    "",
+		"":                         "",
+	}
+	for data, want := range tests {
+		if got := Sniff([]byte(data)); got != want {
+			t.Errorf("Sniff(%q) = %q, want %q", data, got, want)
+		}
+	}
+}
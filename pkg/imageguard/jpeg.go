@@ -0,0 +1,106 @@
+package imageguard
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	markerSOS  = 0xDA
+	markerEOI  = 0xD9
+	markerCOM  = 0xFE
+	markerAPP1 = 0xE1
+	markerAPP2 = 0xE2
+)
+
+var (
+	xmpPrefix    = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	xmpExtPrefix = []byte("http://ns.adobe.com/xmp/extension/\x00")
+	mpfPrefix    = []byte("MPF\x00")
+)
+
+// sanitizeJPEG walks the segments of a JPEG up to its end marker. GPS data
+// is stripped from EXIF, and comments, XMP packets and the images of
+// multi-picture files that follow the first one are dropped. Anything else
+// after the end marker makes the file a polyglot.
+func sanitizeJPEG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	pos := 2
+
+	for {
+		// Markers may be preceded by any number of fill bytes
+		for pos+1 < len(data) && data[pos] == 0xFF && data[pos+1] == 0xFF {
+			pos++
+		}
+		if pos+1 >= len(data) || data[pos] != 0xFF {
+			return nil, reject(ReasonCorrupt, "JPEG ends before its end marker")
+		}
+		marker := data[pos+1]
+
+		if marker == markerEOI {
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			break
+		}
+		// Restart and TEM markers carry no length
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return nil, reject(ReasonCorrupt, "JPEG segment header is truncated")
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, reject(ReasonCorrupt, "JPEG segment overruns the file")
+		}
+		segment := data[pos:end]
+		payload := segment[4:]
+
+		switch {
+		case marker == markerCOM:
+		case marker == markerAPP1 && (bytes.HasPrefix(payload, xmpPrefix) || bytes.HasPrefix(payload, xmpExtPrefix)):
+		case marker == markerAPP2 && bytes.HasPrefix(payload, mpfPrefix):
+			// Points at the trailing images, which are dropped
+		case marker == markerAPP1 && bytes.HasPrefix(payload, exifPrefix):
+			if exif, ok := stripGPS(payload); ok {
+				out = append(out, segment[:4]...)
+				out = append(out, exif...)
+			}
+		default:
+			out = append(out, segment...)
+		}
+		pos = end
+
+		if marker == markerSOS {
+			scanEnd := entropyEnd(data, pos)
+			out = append(out, data[pos:scanEnd]...)
+			pos = scanEnd
+		}
+	}
+
+	if trailing := data[pos:]; !onlyPadding(trailing) && !bytes.HasPrefix(trailing, []byte{0xFF, 0xD8, 0xFF}) {
+		return nil, reject(ReasonPolyglot, "%d bytes follow the end of the JPEG", len(trailing))
+	}
+	return out, nil
+}
+
+// entropyEnd returns the position of the first marker after the
+// entropy-coded data starting at pos. Stuffed zero bytes and restart
+// markers are part of the data.
+func entropyEnd(data []byte, pos int) int {
+	for pos+1 < len(data) {
+		if data[pos] == 0xFF {
+			next := data[pos+1]
+			if next != 0x00 && (next < 0xD0 || next > 0xD7) && next != 0xFF {
+				return pos
+			}
+		}
+		pos++
+	}
+	return len(data)
+}
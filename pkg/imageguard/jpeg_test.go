@@ -0,0 +1,144 @@
+package imageguard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withSegments inserts segments right after the start of image marker
+func withSegments(data []byte, segments ...[]byte) []byte {
+	out := bytes.Clone(data[:2])
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+func rejectReason(t *testing.T, err error) Reason {
+	t.Helper()
+	var rejected *Error
+	if !errors.As(err, &rejected) {
+		t.Fatalf("error = %v, want *Error", err)
+	}
+	return rejected.Reason
+}
+
+func TestSanitizeJPEG(t *testing.T) {
+	plain := encodeJPEG(t, 16, 16)
+	exif := append(bytes.Clone(exifPrefix), buildEXIF(binary.BigEndian, true)...)
+	xmp := append(bytes.Clone(xmpPrefix), "<x:xmpmeta>secret</x:xmpmeta>"...)
+	data := withSegments(plain,
+		jpegSegment(markerAPP1, exif),
+		jpegSegment(markerAPP1, xmp),
+		jpegSegment(markerCOM, []byte("shot at home")),
+	)
+
+	out, err := sanitizeJPEG(data)
+	if err != nil {
+		t.Fatalf("sanitizeJPEG() error = %v", err)
+	}
+	for _, leaked := range [][]byte{gpsValue, []byte("secret"), []byte("shot at home")} {
+		if bytes.Contains(out, leaked) {
+			t.Errorf("output still contains %q", leaked)
+		}
+	}
+	if !bytes.Contains(out, []byte("Canon")) {
+		t.Error("EXIF without GPS was dropped")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("sanitized JPEG does not decode: %v", err)
+	}
+
+	unchanged, err := sanitizeJPEG(plain)
+	if err != nil {
+		t.Fatalf("sanitizeJPEG() error = %v", err)
+	}
+	if !bytes.Equal(unchanged, plain) {
+		t.Error("sanitizeJPEG() changed a JPEG without metadata")
+	}
+}
+
+func TestSanitizeJPEGDropsUnreadableEXIF(t *testing.T) {
+	exif := append(bytes.Clone(exifPrefix), "MM\x00\x2a\xff\xff\xff\xff"...)
+	out, err := sanitizeJPEG(withSegments(encodeJPEG(t, 16, 16), jpegSegment(markerAPP1, exif)))
+	if err != nil {
+		t.Fatalf("sanitizeJPEG() error = %v", err)
+	}
+	if bytes.Contains(out, exifPrefix) {
+		t.Error("unreadable EXIF was kept")
+	}
+}
+
+func TestSanitizeJPEGTrailingData(t *testing.T) {
+	plain := encodeJPEG(t, 16, 16)
+
+	tests := []struct {
+		name    string
+		trailer []byte
+		reason  Reason
+	}{
+		{"zero padding", make([]byte, 64), ""},
+		{"second image", encodeJPEG(t, 8, 8), ""},
+		{"zip archive", []byte("PK\x03\x04payload"), ReasonPolyglot},
+		{"script", []byte("<?php system($_GET['c']); ?>"), ReasonPolyglot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := sanitizeJPEG(append(bytes.Clone(plain), tt.trailer...))
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("sanitizeJPEG() error = %v", err)
+				}
+				if !bytes.Equal(out, plain) {
+					t.Error("output should end at the first image's end marker")
+				}
+				return
+			}
+			if got := rejectReason(t, err); got != tt.reason {
+				t.Errorf("reason = %s, want %s", got, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSanitizeJPEGCorrupt(t *testing.T) {
+	plain := encodeJPEG(t, 16, 16)
+
+	overrun := bytes.Clone(plain)
+	// The first segment after SOI claims to run past the end of the file
+	binary.BigEndian.PutUint16(overrun[4:], 0xFFFF)
+
+	tests := map[string][]byte{
+		"truncated":    plain[:len(plain)/2],
+		"no end":       plain[:len(plain)-2],
+		"overrun":      overrun,
+		"short header": plain[:5],
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := sanitizeJPEG(data)
+			if got := rejectReason(t, err); got != ReasonCorrupt {
+				t.Errorf("reason = %s, want %s", got, ReasonCorrupt)
+			}
+		})
+	}
+}
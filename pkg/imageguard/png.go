@@ -0,0 +1,54 @@
+package imageguard
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// sanitizePNG walks the chunks of a PNG up to IEND, checking each CRC.
+// GPS data is stripped from eXIf chunks and text chunks are dropped.
+// Anything after IEND makes the file a polyglot.
+func sanitizePNG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:8]...)
+	pos := 8
+
+	for {
+		if pos+12 > len(data) {
+			return nil, reject(ReasonCorrupt, "PNG ends before IEND")
+		}
+		length := uint64(binary.BigEndian.Uint32(data[pos:]))
+		if uint64(pos)+12+length > uint64(len(data)) {
+			return nil, reject(ReasonCorrupt, "PNG chunk overruns the file")
+		}
+		end := pos + 12 + int(length)
+		chunk := data[pos:end]
+		kind := string(chunk[4:8])
+
+		if crc32.ChecksumIEEE(chunk[4:8+length]) != binary.BigEndian.Uint32(chunk[8+length:]) {
+			return nil, reject(ReasonCorrupt, "PNG %s chunk fails its CRC", kind)
+		}
+
+		switch kind {
+		case "tEXt", "zTXt", "iTXt":
+		case "eXIf":
+			if exif, ok := stripGPS(chunk[8 : 8+length]); ok {
+				out = append(out, chunk[:8]...)
+				out = append(out, exif...)
+				out = binary.BigEndian.AppendUint32(out, crc32.Update(crc32.ChecksumIEEE(chunk[4:8]), crc32.IEEETable, exif))
+			}
+		default:
+			out = append(out, chunk...)
+		}
+		pos = end
+
+		if kind == "IEND" {
+			break
+		}
+	}
+
+	if trailing := data[pos:]; !onlyPadding(trailing) {
+		return nil, reject(ReasonPolyglot, "%d bytes follow the end of the PNG", len(trailing))
+	}
+	return out, nil
+}
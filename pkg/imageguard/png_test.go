@@ -0,0 +1,107 @@
+package imageguard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func pngChunk(kind string, payload []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// withChunks inserts chunks before IEND, the last 12 bytes of data
+func withChunks(data []byte, chunks ...[]byte) []byte {
+	iend := len(data) - 12
+	out := bytes.Clone(data[:iend])
+	for _, chunk := range chunks {
+		out = append(out, chunk...)
+	}
+	return append(out, data[iend:]...)
+}
+
+func TestSanitizePNG(t *testing.T) {
+	plain := encodePNG(t, 16, 16)
+	data := withChunks(plain,
+		pngChunk("tEXt", []byte("Comment\x00shot at home")),
+		pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00secret")),
+		pngChunk("eXIf", buildEXIF(binary.LittleEndian, true)),
+	)
+
+	out, err := sanitizePNG(data)
+	if err != nil {
+		t.Fatalf("sanitizePNG() error = %v", err)
+	}
+	for _, leaked := range [][]byte{gpsValue, []byte("secret"), []byte("shot at home")} {
+		if bytes.Contains(out, leaked) {
+			t.Errorf("output still contains %q", leaked)
+		}
+	}
+	if !bytes.Contains(out, []byte("eXIf")) || !bytes.Contains(out, []byte("Canon")) {
+		t.Error("EXIF without GPS was dropped")
+	}
+
+	// The rewritten eXIf chunk must carry a valid CRC
+	if again, err := sanitizePNG(out); err != nil || !bytes.Equal(again, out) {
+		t.Errorf("sanitizing the output again = %v, want it unchanged", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("sanitized PNG does not decode: %v", err)
+	}
+
+	unchanged, err := sanitizePNG(plain)
+	if err != nil {
+		t.Fatalf("sanitizePNG() error = %v", err)
+	}
+	if !bytes.Equal(unchanged, plain) {
+		t.Error("sanitizePNG() changed a PNG without metadata")
+	}
+}
+
+func TestSanitizePNGRejects(t *testing.T) {
+	plain := encodePNG(t, 16, 16)
+
+	badCRC := bytes.Clone(plain)
+	badCRC[len(badCRC)-1] ^= 0xFF
+
+	overrun := bytes.Clone(plain)
+	binary.BigEndian.PutUint32(overrun[8:], 0xFFFFFF)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		reason Reason
+	}{
+		{"bad CRC", badCRC, ReasonCorrupt},
+		{"chunk overrun", overrun, ReasonCorrupt},
+		{"no IEND", plain[:len(plain)-12], ReasonCorrupt},
+		{"trailing data", append(bytes.Clone(plain), "PK\x03\x04"...), ReasonPolyglot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sanitizePNG(tt.data)
+			if got := rejectReason(t, err); got != tt.reason {
+				t.Errorf("reason = %s, want %s", got, tt.reason)
+			}
+		})
+	}
+
+	if _, err := sanitizePNG(append(bytes.Clone(plain), 0, 0, 0, 0)); err != nil {
+		t.Errorf("zero padding after IEND: error = %v", err)
+	}
+}
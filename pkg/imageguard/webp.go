@@ -0,0 +1,70 @@
+package imageguard
+
+import (
+	"encoding/binary"
+)
+
+// VP8X flags marking a WebP as carrying EXIF and XMP chunks
+const (
+	vp8xEXIFFlag = 0x08
+	vp8xXMPFlag  = 0x04
+)
+
+// sanitizeWebP walks the chunks of a WebP RIFF container. GPS data is
+// stripped from the EXIF chunk and the XMP chunk is dropped. Anything
+// after the size the RIFF header declares makes the file a polyglot.
+func sanitizeWebP(data []byte) ([]byte, error) {
+	riffEnd := uint64(8) + uint64(binary.LittleEndian.Uint32(data[4:]))
+	if riffEnd > uint64(len(data)) {
+		return nil, reject(ReasonCorrupt, "WebP is shorter than its RIFF header declares")
+	}
+	if trailing := data[riffEnd:]; !onlyPadding(trailing) {
+		return nil, reject(ReasonPolyglot, "%d bytes follow the end of the WebP", len(trailing))
+	}
+
+	chunks := make([]byte, 0, riffEnd)
+	flags := -1
+	dropped := byte(vp8xXMPFlag)
+	pos := uint64(12)
+	for pos < riffEnd {
+		if pos+8 > riffEnd {
+			return nil, reject(ReasonCorrupt, "WebP chunk header is truncated")
+		}
+		size := uint64(binary.LittleEndian.Uint32(data[pos+4:]))
+		// Chunks are padded to an even size
+		end := pos + 8 + size + size&1
+		if end > riffEnd {
+			return nil, reject(ReasonCorrupt, "WebP chunk overruns the file")
+		}
+		chunk := data[pos:end]
+
+		switch string(chunk[:4]) {
+		case "XMP ":
+		case "EXIF":
+			if exif, ok := stripGPS(chunk[8 : 8+size]); ok {
+				chunks = append(chunks, chunk[:8]...)
+				chunks = append(chunks, exif...)
+				chunks = append(chunks, chunk[8+size:]...)
+			} else {
+				dropped |= vp8xEXIFFlag
+			}
+		case "VP8X":
+			if size > 0 {
+				flags = len(chunks) + 8
+			}
+			chunks = append(chunks, chunk...)
+		default:
+			chunks = append(chunks, chunk...)
+		}
+		pos = end
+	}
+	if flags >= 0 {
+		chunks[flags] &^= dropped
+	}
+
+	out := make([]byte, 0, 12+len(chunks))
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(4+len(chunks)))
+	out = append(out, "WEBP"...)
+	return append(out, chunks...), nil
+}
@@ -0,0 +1,112 @@
+package imageguard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func webpChunk(kind string, payload []byte) []byte {
+	chunk := append([]byte(kind), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// buildWebP wraps chunks in a RIFF container. The image data is not
+// decodable, the sanitizer only walks the container.
+func buildWebP(chunks ...[]byte) []byte {
+	var body []byte
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	out := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(body)))...)
+	out = append(out, "WEBP"...)
+	return append(out, body...)
+}
+
+func vp8x(flags byte) []byte {
+	// Flags, three reserved bytes, then width and height minus one
+	return webpChunk("VP8X", []byte{flags, 0, 0, 0, 15, 0, 0, 15, 0, 0})
+}
+
+func TestSanitizeWebP(t *testing.T) {
+	data := buildWebP(
+		vp8x(vp8xEXIFFlag|vp8xXMPFlag),
+		webpChunk("VP8L", []byte("pixels")),
+		webpChunk("EXIF", buildEXIF(binary.LittleEndian, true)),
+		webpChunk("XMP ", []byte("<x:xmpmeta>secret</x:xmpmeta>")),
+	)
+
+	out, err := sanitizeWebP(data)
+	if err != nil {
+		t.Fatalf("sanitizeWebP() error = %v", err)
+	}
+	if bytes.Contains(out, gpsValue) || bytes.Contains(out, []byte("secret")) {
+		t.Error("output still contains GPS data or the XMP packet")
+	}
+	if !bytes.Contains(out, []byte("Canon")) {
+		t.Error("EXIF without GPS was dropped")
+	}
+	if size := binary.LittleEndian.Uint32(out[4:]); int(size) != len(out)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(out)-8)
+	}
+	if flags := out[20]; flags != vp8xEXIFFlag {
+		t.Errorf("VP8X flags = %#x, want only EXIF", flags)
+	}
+
+	again, err := sanitizeWebP(out)
+	if err != nil || !bytes.Equal(again, out) {
+		t.Errorf("sanitizing the output again = %v, want it unchanged", err)
+	}
+}
+
+func TestSanitizeWebPDropsUnreadableEXIF(t *testing.T) {
+	data := buildWebP(
+		vp8x(vp8xEXIFFlag),
+		webpChunk("VP8L", []byte("pixels")),
+		webpChunk("EXIF", []byte("not exif")),
+	)
+
+	out, err := sanitizeWebP(data)
+	if err != nil {
+		t.Fatalf("sanitizeWebP() error = %v", err)
+	}
+	if bytes.Contains(out, []byte("EXIF")) {
+		t.Error("unreadable EXIF was kept")
+	}
+	if flags := out[20]; flags != 0 {
+		t.Errorf("VP8X flags = %#x, want none", flags)
+	}
+}
+
+func TestSanitizeWebPRejects(t *testing.T) {
+	plain := buildWebP(webpChunk("VP8L", []byte("pixels")))
+
+	short := bytes.Clone(plain)
+	binary.LittleEndian.PutUint32(short[4:], uint32(len(plain)))
+
+	overrun := bytes.Clone(plain)
+	binary.LittleEndian.PutUint32(overrun[16:], 0xFFFF)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		reason Reason
+	}{
+		{"shorter than declared", short, ReasonCorrupt},
+		{"chunk overrun", overrun, ReasonCorrupt},
+		{"truncated chunk header", append(bytes.Clone(plain[:12]), "VP8"...), ReasonCorrupt},
+		{"trailing data", append(bytes.Clone(plain), "PK\x03\x04"...), ReasonPolyglot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sanitizeWebP(tt.data)
+			if got := rejectReason(t, err); got != tt.reason {
+				t.Errorf("reason = %s, want %s", got, tt.reason)
+			}
+		})
+	}
+}
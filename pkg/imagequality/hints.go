@@ -0,0 +1,26 @@
+package imagequality
+
+// retakeHints holds user-facing retake advice keyed by language
+var retakeHints = map[Issue]map[string]string{
+	IssueTooSmall: {
+		"en": "The photo resolution is too low. Move closer to the label or use a higher camera resolution.",
+		"id": "Resolusi foto terlalu rendah. Dekatkan kamera ke label atau gunakan resolusi kamera yang lebih tinggi.",
+	},
+	IssueBlurry: {
+		"en": "The photo is blurry. Hold the phone steady and tap the label to focus before taking the photo.",
+		"id": "Foto buram. Pegang ponsel dengan stabil dan ketuk label untuk fokus sebelum mengambil foto.",
+	},
+	IssueGlare: {
+		"en": "There is too much glare on the label. Tilt the package or move away from direct light.",
+		"id": "Terlalu banyak pantulan cahaya pada label. Miringkan kemasan atau hindari cahaya langsung.",
+	},
+	IssueNoText: {
+		"en": "No readable text was found. Make sure the nutrition table fills most of the frame.",
+		"id": "Tidak ada teks yang terbaca. Pastikan tabel informasi nilai gizi memenuhi sebagian besar bingkai foto.",
+	},
+}
+
+// RetakeHint returns advice for retaking a photo in English ("en") and Indonesian ("id")
+func RetakeHint(issue Issue) map[string]string {
+	return retakeHints[issue]
+}
@@ -0,0 +1,214 @@
+package imagequality
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+
+	// Register decoders for uploaded label photos
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// analysisSize is the long side images are scaled to before measuring,
+// so thresholds do not depend on the camera resolution
+const analysisSize = 1000
+
+type Issue string
+
+const (
+	IssueTooSmall Issue = "too_small"
+	IssueBlurry   Issue = "blurry"
+	IssueGlare    Issue = "glare"
+	IssueNoText   Issue = "no_text"
+)
+
+// Thresholds holds the limits an image must meet to be worth reading
+type Thresholds struct {
+	MinWidth  int
+	MinHeight int
+	// MinSharpness is the minimum variance of the Laplacian
+	MinSharpness float64
+	// MaxGlare is the maximum fraction of blown out pixels
+	MaxGlare float64
+	// MinTextDensity is the minimum fraction of edge pixels
+	MinTextDensity float64
+}
+
+// DefaultThresholds returns default quality thresholds
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		MinWidth:       200,
+		MinHeight:      200,
+		MinSharpness:   80,
+		MaxGlare:       0.25,
+		MinTextDensity: 0.01,
+	}
+}
+
+// Metrics are the measurements taken from an image
+type Metrics struct {
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	Sharpness   float64 `json:"sharpness"`
+	Glare       float64 `json:"glare"`
+	TextDensity float64 `json:"text_density"`
+}
+
+// Report is the result of assessing an image
+type Report struct {
+	Metrics
+	Issues []Issue `json:"issues,omitempty"`
+}
+
+// Usable reports whether the image passed every check
+func (r *Report) Usable() bool {
+	return len(r.Issues) == 0
+}
+
+// Error is returned when an image is not usable
+type Error struct {
+	Report *Report
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("image quality too low: %s", e.Report.Issues[0])
+}
+
+// Issue returns the most important problem with the image
+func (e *Error) Issue() Issue {
+	return e.Report.Issues[0]
+}
+
+// Assess decodes an image and measures its size, sharpness, glare and text density.
+// When checkText is false the text density check is skipped, which suits photos
+// of the front of a pack where text is sparse.
+func Assess(data []byte, t Thresholds, checkText bool) (*Report, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	report := &Report{
+		Metrics: Metrics{
+			Width:  bounds.Dx(),
+			Height: bounds.Dy(),
+		},
+	}
+
+	// A tiny image makes every other measurement meaningless
+	if report.Width < t.MinWidth || report.Height < t.MinHeight {
+		report.Issues = append(report.Issues, IssueTooSmall)
+		return report, nil
+	}
+
+	gray := scaledGray(src, analysisSize)
+	report.Sharpness = round(laplacianVariance(gray))
+	report.Glare = round(glareRatio(gray))
+	report.TextDensity = round(edgeDensity(gray))
+
+	if report.Sharpness < t.MinSharpness {
+		report.Issues = append(report.Issues, IssueBlurry)
+	}
+	if report.Glare > t.MaxGlare {
+		report.Issues = append(report.Issues, IssueGlare)
+	}
+	if checkText && report.TextDensity < t.MinTextDensity {
+		report.Issues = append(report.Issues, IssueNoText)
+	}
+
+	return report, nil
+}
+
+// scaledGray converts to grayscale, scaling the long side down to maxSide
+func scaledGray(src image.Image, maxSide int) *image.Gray {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	scale := 1.0
+	if long := max(w, h); long > maxSide {
+		scale = float64(maxSide) / float64(long)
+	}
+	dw, dh := max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+
+	gray := image.NewGray(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy := bounds.Min.Y + int(float64(y)/scale)
+		for x := 0; x < dw; x++ {
+			sx := bounds.Min.X + int(float64(x)/scale)
+			r, g, b, _ := src.At(sx, sy).RGBA()
+			gray.Pix[y*gray.Stride+x] = uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+		}
+	}
+
+	return gray
+}
+
+// laplacianVariance is the variance of the 4-neighbour Laplacian. Sharp
+// text produces strong second derivatives, blur flattens them.
+func laplacianVariance(gray *image.Gray) float64 {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	if w < 3 || h < 3 {
+		return 0
+	}
+
+	var sum, sumSq float64
+	n := 0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			c := float64(gray.Pix[y*gray.Stride+x])
+			lap := float64(gray.Pix[(y-1)*gray.Stride+x]) +
+				float64(gray.Pix[(y+1)*gray.Stride+x]) +
+				float64(gray.Pix[y*gray.Stride+x-1]) +
+				float64(gray.Pix[y*gray.Stride+x+1]) - 4*c
+			sum += lap
+			sumSq += lap * lap
+			n++
+		}
+	}
+
+	mean := sum / float64(n)
+	return sumSq/float64(n) - mean*mean
+}
+
+// glareRatio is the fraction of pixels that are fully blown out
+func glareRatio(gray *image.Gray) float64 {
+	blown := 0
+	for _, v := range gray.Pix {
+		if v >= 253 {
+			blown++
+		}
+	}
+	return float64(blown) / float64(len(gray.Pix))
+}
+
+// edgeDensity is the fraction of pixels with a strong horizontal or
+// vertical gradient, a cheap proxy for how much text is in frame
+func edgeDensity(gray *image.Gray) float64 {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	if w < 2 || h < 2 {
+		return 0
+	}
+
+	edges := 0
+	for y := 0; y < h-1; y++ {
+		for x := 0; x < w-1; x++ {
+			c := int(gray.Pix[y*gray.Stride+x])
+			dx := int(gray.Pix[y*gray.Stride+x+1]) - c
+			dy := int(gray.Pix[(y+1)*gray.Stride+x]) - c
+			if dx*dx+dy*dy > 40*40 {
+				edges++
+			}
+		}
+	}
+
+	return float64(edges) / float64((w-1)*(h-1))
+}
+
+func round(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,149 @@
+package nutrition
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+)
+
+// Words that mark a line as label boilerplate rather than a product name
+var frontNoiseWords = []string{
+	"netto", "net wt", "berat bersih", "isi bersih", "bpom", "md ", "ml ",
+	"halal", "informasi nilai gizi", "nutrition facts", "komposisi", "ingredients",
+}
+
+// Headers that introduce the ingredient list
+var ingredientHeaders = []string{"komposisi", "bahan-bahan", "bahan", "ingredients"}
+
+// Phrases that end the ingredient list
+var ingredientTerminators = []string{
+	"mengandung alergen", "informasi alergen", "allergen", "contains",
+	"informasi nilai gizi", "nutrition facts", "simpan", "store in",
+	"diproduksi", "produced by", "manufactured",
+}
+
+var reWeight = regexp.MustCompile(`\d+\s*(g|gr|gram|kg|ml|l)\b`)
+
+// ParseFrontText extracts a product name and brand from front-of-pack OCR text.
+// The first clean line is taken as the brand and the longest remaining clean
+// line as the product name.
+func ParseFrontText(text string) (name, brand string) {
+	var candidates []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if isFrontCandidate(line) {
+			candidates = append(candidates, line)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", ""
+	case 1:
+		return titleCase(candidates[0]), ""
+	}
+
+	brand = candidates[0]
+	for _, c := range candidates[1:] {
+		if len(c) > len(name) {
+			name = c
+		}
+	}
+
+	return titleCase(name), titleCase(brand)
+}
+
+func isFrontCandidate(line string) bool {
+	if len(line) < 3 || len(line) > 60 {
+		return false
+	}
+
+	lower := strings.ToLower(line)
+	for _, w := range frontNoiseWords {
+		if strings.Contains(lower, w) {
+			return false
+		}
+	}
+	if reWeight.MatchString(lower) {
+		return false
+	}
+
+	letters := 0
+	for _, r := range line {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	// Mostly letters, otherwise it's probably OCR noise from graphics
+	return letters*10 >= len(line)*7
+}
+
+func titleCase(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for i, w := range words {
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
+
+// ParseIngredientsText extracts the ingredient list from OCR text
+func ParseIngredientsText(text string) string {
+	flat := strings.Join(strings.Fields(text), " ")
+	lower := strings.ToLower(flat)
+
+	start := 0
+	for _, h := range ingredientHeaders {
+		if idx := strings.Index(lower, h); idx >= 0 {
+			start = idx + len(h)
+			break
+		}
+	}
+
+	end := len(flat)
+	for _, t := range ingredientTerminators {
+		if idx := strings.Index(lower[start:], t); idx >= 0 && start+idx < end {
+			end = start + idx
+		}
+	}
+
+	return strings.Trim(flat[start:end], " :.;-")
+}
+
+// MergeNutrients fills nutrients missing from dst with values from src
+func MergeNutrients(dst, src *models.Nutrients) *models.Nutrients {
+	if dst == nil {
+		return src
+	}
+	if src == nil {
+		return dst
+	}
+
+	fill := func(d **float64, s *float64) {
+		if *d == nil && s != nil {
+			*d = s
+		}
+	}
+
+	fill(&dst.EnergyKcal, src.EnergyKcal)
+	fill(&dst.ProteinG, src.ProteinG)
+	fill(&dst.CarbohydrateG, src.CarbohydrateG)
+	fill(&dst.SugarG, src.SugarG)
+	fill(&dst.FatG, src.FatG)
+	fill(&dst.SaturatedFatG, src.SaturatedFatG)
+	fill(&dst.FiberG, src.FiberG)
+	fill(&dst.SodiumMg, src.SodiumMg)
+	fill(&dst.SaltG, src.SaltG)
+	fill(&dst.CholesterolMg, src.CholesterolMg)
+	fill(&dst.TransFatG, src.TransFatG)
+	fill(&dst.VitaminAIU, src.VitaminAIU)
+	fill(&dst.VitaminCMg, src.VitaminCMg)
+	fill(&dst.CalciumMg, src.CalciumMg)
+	fill(&dst.IronMg, src.IronMg)
+	fill(&dst.PotassiumMg, src.PotassiumMg)
+
+	return dst
+}
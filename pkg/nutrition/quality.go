@@ -0,0 +1,78 @@
+package nutrition
+
+import (
+	"math"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+)
+
+// plausibleRange is the range a nutrient can realistically take per 100g or per serving
+type plausibleRange struct {
+	value    *float64
+	min, max float64
+}
+
+// ScoreNutrients rates how complete and plausible a parsed nutrient table is,
+// from 0 (nothing usable) to 1 (every core nutrient found and consistent)
+func ScoreNutrients(n *models.Nutrients) float64 {
+	if n == nil {
+		return 0
+	}
+
+	core := []plausibleRange{
+		{n.EnergyKcal, 0, 900},
+		{n.ProteinG, 0, 100},
+		{n.FatG, 0, 100},
+		{n.SaturatedFatG, 0, 100},
+		{n.CarbohydrateG, 0, 100},
+		{n.SugarG, 0, 100},
+		{n.FiberG, 0, 100},
+		{n.SodiumMg, 0, 40000},
+	}
+
+	found, plausible := 0, 0
+	for _, c := range core {
+		if c.value == nil {
+			continue
+		}
+		found++
+		if *c.value >= c.min && *c.value <= c.max {
+			plausible++
+		}
+	}
+
+	consistent := 0
+	if n.SaturatedFatG != nil && n.FatG != nil && *n.SaturatedFatG <= *n.FatG {
+		consistent++
+	}
+	if n.SugarG != nil && n.CarbohydrateG != nil && *n.SugarG <= *n.CarbohydrateG {
+		consistent++
+	}
+	if energyMatchesMacros(n) {
+		consistent++
+	}
+
+	return float64(found+plausible+consistent) / float64(2*len(core)+3)
+}
+
+// energyMatchesMacros checks the declared energy against 4/4/9 kcal per gram
+// of protein, carbohydrate and fat, allowing for rounding on the label
+func energyMatchesMacros(n *models.Nutrients) bool {
+	if n.EnergyKcal == nil || n.ProteinG == nil || n.CarbohydrateG == nil || n.FatG == nil {
+		return false
+	}
+
+	estimated := 4**n.ProteinG + 4**n.CarbohydrateG + 9**n.FatG
+	largest := math.Max(*n.EnergyKcal, estimated)
+	if largest == 0 {
+		return true
+	}
+
+	return math.Abs(*n.EnergyKcal-estimated)/largest <= 0.35
+}
+
+// ScoreText parses OCR text and scores the resulting nutrient table
+func ScoreText(text string) float64 {
+	nutrients, _ := ParseFromText(text)
+	return ScoreNutrients(nutrients)
+}
@@ -0,0 +1,69 @@
+package nutrition
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/habbazettt/nutrisnap-server/internal/models"
+)
+
+// ParserVersion identifies the current parsing and scoring rules. Bump it
+// whenever a change alters results, so stored scans read by an older
+// version can be found and re-parsed.
+const ParserVersion = "2"
+
+// NutrientChange is a nutrient whose value differs between two readings
+type NutrientChange struct {
+	Nutrient string   `json:"nutrient"`
+	Old      *float64 `json:"old"`
+	New      *float64 `json:"new"`
+}
+
+// DiffNutrients lists the nutrients that were added, removed or changed
+func DiffNutrients(oldNutrients, newNutrients *models.Nutrients) []NutrientChange {
+	oldValues := nutrientValues(oldNutrients)
+	newValues := nutrientValues(newNutrients)
+
+	names := make(map[string]struct{})
+	for name := range oldValues {
+		names[name] = struct{}{}
+	}
+	for name := range newValues {
+		names[name] = struct{}{}
+	}
+
+	var changes []NutrientChange
+	for name := range names {
+		oldValue, hadOld := oldValues[name]
+		newValue, hasNew := newValues[name]
+		if hadOld && hasNew && math.Abs(oldValue-newValue) < 0.001 {
+			continue
+		}
+
+		change := NutrientChange{Nutrient: name}
+		if hadOld {
+			change.Old = &oldValue
+		}
+		if hasNew {
+			change.New = &newValue
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Nutrient < changes[j].Nutrient
+	})
+	return changes
+}
+
+// nutrientValues flattens the nutrients that are set, keyed by JSON name
+func nutrientValues(n *models.Nutrients) map[string]float64 {
+	values := make(map[string]float64)
+	if n == nil {
+		return values
+	}
+	data, _ := json.Marshal(n)
+	_ = json.Unmarshal(data, &values)
+	return values
+}
@@ -0,0 +1,54 @@
+package ocr
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	poolEngines = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "nutrisnap_ocr_pool_engines",
+		Help: "Number of OCR engines owned by the pool",
+	})
+
+	poolInUse = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "nutrisnap_ocr_pool_in_use",
+		Help: "Number of OCR engines currently checked out",
+	})
+
+	poolWaiting = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "nutrisnap_ocr_pool_waiting",
+		Help: "Number of callers waiting for a free OCR engine",
+	})
+
+	poolAcquireWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "nutrisnap_ocr_pool_acquire_wait_seconds",
+		Help:    "Time spent waiting to acquire an OCR engine",
+		Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+	})
+
+	poolRecycled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nutrisnap_ocr_pool_recycled_total",
+		Help: "Number of OCR engines replaced by the pool, by reason",
+	}, []string{"reason"})
+
+	ocrDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nutrisnap_ocr_duration_seconds",
+		Help:    "Time spent running Tesseract on a single image",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 16, 32},
+	}, []string{"outcome"})
+
+	passWins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nutrisnap_ocr_multipass_wins_total",
+		Help: "Number of times each OCR pass produced the best result",
+	}, []string{"pass"})
+)
+
+// ObserveOCR records how long an OCR call took and whether it succeeded
+func ObserveOCR(seconds float64, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	ocrDuration.WithLabelValues(outcome).Observe(seconds)
+}
@@ -0,0 +1,139 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+)
+
+// Pass is one OCR configuration tried by RunPasses
+type Pass struct {
+	Name       string
+	Settings   Settings
+	Preprocess Variant
+}
+
+// PassResult is the outcome of a single pass
+type PassResult struct {
+	Pass     string
+	Text     string
+	Score    float64
+	Duration time.Duration
+	Err      error
+}
+
+// MultiPassResult holds the winning pass and every pass that was attempted
+type MultiPassResult struct {
+	Best   PassResult
+	Passes []PassResult
+	// PreprocessTime is the part of the run spent preparing image variants
+	PreprocessTime time.Duration
+}
+
+// MultiPassConfig controls how RunPasses spends its time
+type MultiPassConfig struct {
+	// Budget is the total time allowed for all passes. The first pass
+	// always runs; later passes are skipped once the budget is spent.
+	Budget time.Duration
+	// GoodEnough stops early once a pass scores at least this much
+	GoodEnough float64
+}
+
+// ScoreFunc rates how useful OCR text is, higher is better
+type ScoreFunc func(text string) float64
+
+// RunPasses reads the same image with several configurations on one engine
+// and keeps the text with the highest score
+func (p *Pool) RunPasses(ctx context.Context, image []byte, passes []Pass, config MultiPassConfig, score ScoreFunc) (*MultiPassResult, error) {
+	if len(passes) == 0 {
+		return nil, fmt.Errorf("no OCR passes configured")
+	}
+
+	client, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire OCR engine: %w", err)
+	}
+
+	deadline := time.Now().Add(config.Budget)
+	variants := map[Variant][]byte{VariantNone: image}
+	result := &MultiPassResult{}
+	var lastErr, engineErr error
+	found := false
+
+	for i, pass := range passes {
+		if i > 0 && (time.Now().After(deadline) || ctx.Err() != nil) {
+			logger.Debug("ocr multi-pass budget exhausted",
+				"completed", i,
+				"total", len(passes),
+			)
+			break
+		}
+
+		start := time.Now()
+		data, err := preprocessed(image, pass.Preprocess, variants)
+		result.PreprocessTime += time.Since(start)
+		var text string
+		if err == nil {
+			text, err = runPass(client, pass, data)
+			ObserveOCR(time.Since(start).Seconds(), err)
+			if err != nil {
+				engineErr = err
+			}
+		}
+
+		pr := PassResult{
+			Pass:     pass.Name,
+			Text:     text,
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			pr.Score = score(text)
+			if !found || pr.Score > result.Best.Score {
+				result.Best = pr
+				found = true
+			}
+		}
+		result.Passes = append(result.Passes, pr)
+
+		if found && config.GoodEnough > 0 && result.Best.Score >= config.GoodEnough {
+			break
+		}
+	}
+
+	p.Release(client, engineErr)
+
+	if !found {
+		return nil, fmt.Errorf("all OCR passes failed: %w", lastErr)
+	}
+
+	passWins.WithLabelValues(result.Best.Pass).Inc()
+	return result, nil
+}
+
+// preprocessed returns the image with the variant applied, caching each
+// variant so passes sharing it only pay for it once
+func preprocessed(image []byte, variant Variant, cache map[Variant][]byte) ([]byte, error) {
+	if data, ok := cache[variant]; ok {
+		return data, nil
+	}
+
+	data, err := Preprocess(image, variant)
+	if err != nil {
+		return nil, err
+	}
+	cache[variant] = data
+	return data, nil
+}
+
+func runPass(client *Client, pass Pass, data []byte) (string, error) {
+	if err := client.Apply(pass.Settings); err != nil {
+		return "", err
+	}
+
+	return client.ProcessImageFromBytes(data)
+}
@@ -2,23 +2,43 @@ package ocr
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/otiai10/gosseract/v2"
 )
 
+// Settings controls how an engine reads an image
+type Settings struct {
+	Languages   []string
+	PageSegMode gosseract.PageSegMode
+}
+
+// DefaultSettings returns the settings engines start with
+func DefaultSettings() Settings {
+	return Settings{
+		Languages:   []string{"eng", "ind"},
+		PageSegMode: gosseract.PSM_AUTO,
+	}
+}
+
 type Client struct {
-	client *gosseract.Client
+	client   *gosseract.Client
+	settings Settings
+	id       int
+	uses     int
 }
 
 func NewClient() *Client {
 	client := gosseract.NewClient()
+	settings := DefaultSettings()
 
-	client.SetLanguage("eng", "ind")
+	client.SetLanguage(settings.Languages...)
 
-	client.SetPageSegMode(3)
+	client.SetPageSegMode(settings.PageSegMode)
 
 	return &Client{
-		client: client,
+		client:   client,
+		settings: settings,
 	}
 }
 
@@ -26,8 +46,40 @@ func (c *Client) Close() error {
 	return c.client.Close()
 }
 
+// Uses returns how many images this client has processed
+func (c *Client) Uses() int {
+	return c.uses
+}
+
+// Apply switches the engine to the given settings. Languages are only
+// changed when they differ, since that forces Tesseract to reload traineddata.
+func (c *Client) Apply(settings Settings) error {
+	if len(settings.Languages) > 0 && !slices.Equal(settings.Languages, c.settings.Languages) {
+		if err := c.client.SetLanguage(settings.Languages...); err != nil {
+			return fmt.Errorf("failed to set OCR languages: %w", err)
+		}
+		c.settings.Languages = slices.Clone(settings.Languages)
+	}
+
+	if settings.PageSegMode != c.settings.PageSegMode {
+		if err := c.client.SetPageSegMode(settings.PageSegMode); err != nil {
+			return fmt.Errorf("failed to set page segmentation mode: %w", err)
+		}
+		c.settings.PageSegMode = settings.PageSegMode
+	}
+
+	return nil
+}
+
+// Reset puts the engine back on DefaultSettings
+func (c *Client) Reset() error {
+	return c.Apply(DefaultSettings())
+}
+
 // ProcessImage performs OCR on an image file path
 func (c *Client) ProcessImage(imagePath string) (string, error) {
+	c.uses++
+
 	if err := c.client.SetImage(imagePath); err != nil {
 		return "", fmt.Errorf("failed to set image for OCR: %w", err)
 	}
@@ -42,6 +94,8 @@ func (c *Client) ProcessImage(imagePath string) (string, error) {
 
 // ProcessImageFromBytes performs OCR on image data in memory
 func (c *Client) ProcessImageFromBytes(imageData []byte) (string, error) {
+	c.uses++
+
 	if err := c.client.SetImageFromBytes(imageData); err != nil {
 		return "", fmt.Errorf("failed to set image bytes for OCR: %w", err)
 	}
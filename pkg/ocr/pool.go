@@ -0,0 +1,271 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"sync"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+)
+
+var (
+	ErrPoolClosed     = errors.New("ocr pool is closed")
+	ErrAcquireTimeout = errors.New("timed out waiting for an ocr engine")
+)
+
+// PoolConfig holds OCR engine pool configuration
+type PoolConfig struct {
+	// Size is the number of warm engines kept by the pool
+	Size int
+	// MaxUses is how many images an engine processes before it is recycled
+	MaxUses int
+	// AcquireTimeout bounds how long a caller waits for a free engine
+	AcquireTimeout time.Duration
+	// HealthCheckInterval is how often idle engines are probed
+	HealthCheckInterval time.Duration
+}
+
+// DefaultPoolConfig returns default pool configuration
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		Size:                5,
+		MaxUses:             200,
+		AcquireTimeout:      30 * time.Second,
+		HealthCheckInterval: time.Minute,
+	}
+}
+
+// Pool keeps a bounded set of warm Tesseract engines so traineddata is
+// loaded once per engine instead of once per scan.
+type Pool struct {
+	config PoolConfig
+	idle   chan *Client
+	nextID int
+
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewPool creates the pool and warms up every engine
+func NewPool(config PoolConfig) *Pool {
+	defaults := DefaultPoolConfig()
+	if config.Size < 1 {
+		config.Size = defaults.Size
+	}
+	if config.MaxUses < 1 {
+		config.MaxUses = defaults.MaxUses
+	}
+	if config.AcquireTimeout <= 0 {
+		config.AcquireTimeout = defaults.AcquireTimeout
+	}
+	if config.HealthCheckInterval <= 0 {
+		config.HealthCheckInterval = defaults.HealthCheckInterval
+	}
+
+	p := &Pool{
+		config: config,
+		idle:   make(chan *Client, config.Size),
+		stop:   make(chan struct{}),
+	}
+
+	for i := 0; i < config.Size; i++ {
+		p.idle <- p.newEngine()
+	}
+	poolEngines.Set(float64(config.Size))
+
+	p.wg.Add(1)
+	go p.healthLoop()
+
+	logger.Info("ocr pool started",
+		"size", config.Size,
+		"max_uses", config.MaxUses,
+	)
+
+	return p
+}
+
+// Size returns the number of engines owned by the pool
+func (p *Pool) Size() int {
+	return p.config.Size
+}
+
+// Acquire checks out a warm engine, waiting until one is free
+func (p *Pool) Acquire(ctx context.Context) (*Client, error) {
+	if p.isClosed() {
+		return nil, ErrPoolClosed
+	}
+
+	start := time.Now()
+	poolWaiting.Inc()
+	defer poolWaiting.Dec()
+
+	timer := time.NewTimer(p.config.AcquireTimeout)
+	defer timer.Stop()
+
+	select {
+	case client := <-p.idle:
+		poolAcquireWait.Observe(time.Since(start).Seconds())
+		poolInUse.Inc()
+		return client, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, ErrAcquireTimeout
+	case <-p.stop:
+		return nil, ErrPoolClosed
+	}
+}
+
+// Release returns an engine to the pool on its default settings, so the
+// next borrower does not inherit another caller's languages. Engines that
+// failed or reached MaxUses are replaced with a fresh one.
+func (p *Pool) Release(client *Client, ocrErr error) {
+	poolInUse.Dec()
+
+	if p.isClosed() {
+		client.Close()
+		return
+	}
+
+	// Recycling and resetting run unlocked, newEngine takes the lock itself
+	switch {
+	case client.Uses() >= p.config.MaxUses:
+		client = p.recycle(client, "max_uses")
+	case ocrErr != nil && !p.healthy(client):
+		client = p.recycle(client, "unhealthy")
+	default:
+		if err := client.Reset(); err != nil {
+			client = p.recycle(client, "reset_failed")
+		}
+	}
+
+	// The closed check and the send share the lock, so Close either sees
+	// the engine when it drains idle or the engine is closed here. The send
+	// never blocks, idle has room for every engine.
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		client.Close()
+		return
+	}
+	p.idle <- client
+}
+
+// Close stops the health checker and closes all idle engines. Engines
+// still checked out are closed when they are released.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	for {
+		select {
+		case client := <-p.idle:
+			client.Close()
+		default:
+			poolEngines.Set(0)
+			logger.Info("ocr pool stopped")
+			return
+		}
+	}
+}
+
+func (p *Pool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+func (p *Pool) newEngine() *Client {
+	p.mu.Lock()
+	p.nextID++
+	id := p.nextID
+	p.mu.Unlock()
+
+	client := NewClient()
+	client.id = id
+
+	// Running the probe forces Tesseract to initialise and load traineddata
+	// now rather than on the first real scan.
+	if !p.healthy(client) {
+		logger.Warn("ocr engine failed warm-up probe", "engine", id)
+	}
+
+	return client
+}
+
+func (p *Pool) recycle(client *Client, reason string) *Client {
+	logger.Debug("recycling ocr engine",
+		"engine", client.id,
+		"uses", client.Uses(),
+		"reason", reason,
+	)
+	poolRecycled.WithLabelValues(reason).Inc()
+	client.Close()
+	return p.newEngine()
+}
+
+// healthy runs a tiny blank image through the engine
+func (p *Pool) healthy(client *Client) bool {
+	_, err := client.ProcessImageFromBytes(probeImage)
+	// The probe must not count towards MaxUses
+	client.uses--
+	return err == nil
+}
+
+// healthLoop periodically probes idle engines and replaces broken ones
+func (p *Pool) healthLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.checkIdle()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *Pool) checkIdle() {
+	n := len(p.idle)
+	for i := 0; i < n; i++ {
+		var client *Client
+		select {
+		case client = <-p.idle:
+		default:
+			return
+		}
+
+		if !p.healthy(client) {
+			client = p.recycle(client, "health_check")
+		}
+		p.idle <- client
+	}
+}
+
+var probeImage = func() []byte {
+	img := image.NewGray(image.Rect(0, 0, 32, 32))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}()
@@ -0,0 +1,162 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/otiai10/gosseract/v2"
+)
+
+func testPool(t *testing.T, config PoolConfig) *Pool {
+	t.Helper()
+	if config.AcquireTimeout == 0 {
+		config.AcquireTimeout = time.Second
+	}
+	p := NewPool(config)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestPoolReusesEngines(t *testing.T) {
+	p := testPool(t, PoolConfig{Size: 1})
+
+	client, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if err := client.Apply(Settings{Languages: []string{"ind"}, PageSegMode: gosseract.PSM_SINGLE_BLOCK}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	p.Release(client, nil)
+
+	again, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer p.Release(again, nil)
+
+	if again != client {
+		t.Error("Acquire() returned a new engine, want the released one")
+	}
+	defaults := DefaultSettings()
+	if !slices.Equal(again.settings.Languages, defaults.Languages) || again.settings.PageSegMode != defaults.PageSegMode {
+		t.Errorf("released engine settings = %+v, want the defaults %+v", again.settings, defaults)
+	}
+}
+
+func TestPoolRecyclesWornEngines(t *testing.T) {
+	p := testPool(t, PoolConfig{Size: 1, MaxUses: 2})
+
+	client, _ := p.Acquire(context.Background())
+	for i := 0; i < 2; i++ {
+		if _, err := client.ProcessImageFromBytes(probeImage); err != nil {
+			t.Fatalf("ProcessImageFromBytes() error = %v", err)
+		}
+	}
+	p.Release(client, nil)
+
+	fresh, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer p.Release(fresh, nil)
+
+	if fresh == client || fresh.id == client.id {
+		t.Error("engine at MaxUses was returned again, want a new one")
+	}
+	if fresh.Uses() != 0 {
+		t.Errorf("new engine uses = %d, want 0, warm-up must not count", fresh.Uses())
+	}
+}
+
+func TestPoolAcquireWaits(t *testing.T) {
+	p := testPool(t, PoolConfig{Size: 1, AcquireTimeout: 50 * time.Millisecond})
+
+	client, _ := p.Acquire(context.Background())
+
+	if _, err := p.Acquire(context.Background()); !errors.Is(err, ErrAcquireTimeout) {
+		t.Errorf("Acquire() on a busy pool error = %v, want ErrAcquireTimeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() with a cancelled context error = %v, want context.Canceled", err)
+	}
+
+	// A waiting caller gets the engine once it is released
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.Release(client, nil)
+	}()
+	got, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	p.Release(got, nil)
+}
+
+func TestPoolClose(t *testing.T) {
+	p := NewPool(PoolConfig{Size: 2, AcquireTimeout: time.Second})
+
+	held, _ := p.Acquire(context.Background())
+	held2, _ := p.Acquire(context.Background())
+
+	// A caller waiting for an engine is woken by Close
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := p.Acquire(context.Background())
+		waiting <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	p.Close()
+	if err := <-waiting; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("waiting Acquire() error = %v, want ErrPoolClosed", err)
+	}
+	if _, err := p.Acquire(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Acquire() after Close error = %v, want ErrPoolClosed", err)
+	}
+
+	// Engines released after Close are closed rather than kept
+	p.Release(held, nil)
+	p.Release(held2, nil)
+	if n := len(p.idle); n != 0 {
+		t.Errorf("idle engines after Close = %d, want 0", n)
+	}
+
+	p.Close()
+}
+
+func TestPoolReleaseDuringClose(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		p := NewPool(PoolConfig{Size: 4, AcquireTimeout: time.Second})
+
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					client, err := p.Acquire(context.Background())
+					if err != nil {
+						return
+					}
+					p.Release(client, nil)
+				}
+			}()
+		}
+
+		time.Sleep(time.Millisecond)
+		p.Close()
+		wg.Wait()
+
+		if n := len(p.idle); n != 0 {
+			t.Fatalf("idle engines after Close = %d, want 0, released engines leaked", n)
+		}
+	}
+}
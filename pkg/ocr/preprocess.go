@@ -0,0 +1,117 @@
+package ocr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+
+	// Register decoders for uploaded label photos
+	_ "image/jpeg"
+)
+
+// Variant is an image preprocessing step applied before OCR
+type Variant string
+
+const (
+	VariantNone      Variant = "none"
+	VariantGrayscale Variant = "grayscale"
+	VariantBinarize  Variant = "binarize"
+)
+
+// Preprocess returns a PNG encoded copy of the image with the variant applied
+func Preprocess(data []byte, variant Variant) ([]byte, error) {
+	if variant == VariantNone || variant == "" {
+		return data, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	gray := toStretchedGray(src)
+
+	if variant == VariantBinarize {
+		threshold := otsuThreshold(gray)
+		for i, v := range gray.Pix {
+			if v > threshold {
+				gray.Pix[i] = 0xff
+			} else {
+				gray.Pix[i] = 0
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gray); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// toStretchedGray converts to grayscale and stretches contrast to the full range
+func toStretchedGray(src image.Image) *image.Gray {
+	bounds := src.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	minV, maxV := uint8(0xff), uint8(0)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			r, g, b, _ := src.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			// ITU-R 601 luma, computed on 16-bit channels
+			v := uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+			gray.Pix[y*gray.Stride+x] = v
+			minV = min(minV, v)
+			maxV = max(maxV, v)
+		}
+	}
+
+	if maxV > minV {
+		span := int(maxV - minV)
+		for i, v := range gray.Pix {
+			gray.Pix[i] = uint8(int(v-minV) * 0xff / span)
+		}
+	}
+
+	return gray
+}
+
+// otsuThreshold picks the threshold that best separates text from background
+func otsuThreshold(gray *image.Gray) uint8 {
+	var hist [256]int
+	for _, v := range gray.Pix {
+		hist[v]++
+	}
+
+	total := len(gray.Pix)
+	sum := 0
+	for i, c := range hist {
+		sum += i * c
+	}
+
+	var sumB, weightB int
+	var best float64
+	var threshold uint8
+	for i, c := range hist {
+		weightB += c
+		if weightB == 0 {
+			continue
+		}
+		weightF := total - weightB
+		if weightF == 0 {
+			break
+		}
+		sumB += i * c
+
+		meanB := float64(sumB) / float64(weightB)
+		meanF := float64(sum-sumB) / float64(weightF)
+		between := float64(weightB) * float64(weightF) * (meanB - meanF) * (meanB - meanF)
+		if between > best {
+			best = between
+			threshold = uint8(i)
+		}
+	}
+
+	return threshold
+}
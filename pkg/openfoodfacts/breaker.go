@@ -0,0 +1,100 @@
+package openfoodfacts
+
+import (
+	"sync"
+	"time"
+
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker is a circuit breaker. After threshold failed calls in a row it
+// opens and rejects calls for the cooldown, then lets one trial call
+// through. The trial closes it on success and opens it again on failure.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	// trial is set while the half-open trial call is running
+	trial bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may go out
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.trial = true
+		return true
+	case breakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// success records a call OFF answered
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+	if b.state != breakerClosed {
+		b.setState(breakerClosed)
+		logger.Info("open food facts circuit closed")
+	}
+}
+
+// failure records a call OFF did not answer usably
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.setState(breakerOpen)
+		logger.Warn("open food facts circuit opened", "failures", b.failures, "cooldown", b.cooldown)
+	}
+}
+
+// release ends a call that says nothing about OFF, such as one the caller
+// cancelled, so a half-open breaker can try again
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+func (b *breaker) setState(state breakerState) {
+	b.state = state
+	breakerStateGauge.Set(float64(state))
+}
@@ -1,71 +1,266 @@
 package openfoodfacts
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/habbazettt/nutrisnap-server/internal/models"
+	"github.com/habbazettt/nutrisnap-server/pkg/backoff"
+	"github.com/habbazettt/nutrisnap-server/pkg/logger"
 )
 
 const (
-	BaseURL = "https://world.openfoodfacts.org/api/v0/product"
+	DefaultBaseURL   = "https://world.openfoodfacts.org/api/v0/product"
+	DefaultUserAgent = "NutriSnap - Android - Version 1.0 - www.nutrisnap.app"
 )
 
+// ErrUnavailable is returned without calling OFF while the circuit breaker
+// is open after repeated failures
+var ErrUnavailable = errors.New("open food facts is unavailable")
+
+// Config holds Open Food Facts client configuration
+type Config struct {
+	// BaseURL is the product API root, a country mirror or a local stub
+	BaseURL   string
+	UserAgent string
+	// Timeout bounds a whole lookup, retries included
+	Timeout time.Duration
+	// AttemptTimeout bounds each request
+	AttemptTimeout time.Duration
+	// MaxRetries is how many times network errors, timeouts, 5xx and 429
+	// are retried, 0 to never retry
+	MaxRetries int
+	// RetryDelay is the base delay before the first retry, doubled on
+	// each retry up to RetryMaxDelay
+	RetryDelay    time.Duration
+	RetryMaxDelay time.Duration
+	// BreakerThreshold is how many failed lookups in a row open the circuit
+	BreakerThreshold int
+	// BreakerCooldown is how long the circuit stays open before a trial lookup
+	BreakerCooldown time.Duration
+}
+
+// DefaultConfig returns default client configuration
+func DefaultConfig() Config {
+	return Config{
+		BaseURL:          DefaultBaseURL,
+		UserAgent:        DefaultUserAgent,
+		Timeout:          6 * time.Second,
+		AttemptTimeout:   3 * time.Second,
+		MaxRetries:       2,
+		RetryDelay:       250 * time.Millisecond,
+		RetryMaxDelay:    2 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
 type Client struct {
+	config     Config
 	httpClient *http.Client
-	userAgent  string
+	breaker    *breaker
 }
 
-func NewClient() *Client {
+// NewClient creates a client, using defaults for unset configuration
+func NewClient(config Config) *Client {
+	defaults := DefaultConfig()
+	if config.BaseURL == "" {
+		config.BaseURL = defaults.BaseURL
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	if config.UserAgent == "" {
+		config.UserAgent = defaults.UserAgent
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.AttemptTimeout <= 0 {
+		config.AttemptTimeout = defaults.AttemptTimeout
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaults.RetryDelay
+	}
+	if config.RetryMaxDelay < config.RetryDelay {
+		config.RetryMaxDelay = config.RetryDelay
+	}
+	if config.BreakerThreshold < 1 {
+		config.BreakerThreshold = defaults.BreakerThreshold
+	}
+	if config.BreakerCooldown <= 0 {
+		config.BreakerCooldown = defaults.BreakerCooldown
+	}
+
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		userAgent: "NutriSnap - Android - Version 1.0 - www.nutrisnap.app",
+		config:     config,
+		httpClient: &http.Client{},
+		breaker:    newBreaker(config.BreakerThreshold, config.BreakerCooldown),
+	}
+}
+
+// FetchResult is the outcome of a conditional product fetch
+type FetchResult struct {
+	// Product is nil when OFF does not know the barcode or NotModified is set
+	Product *models.Product
+	// ETag identifies the returned version, empty when OFF sent none
+	ETag string
+	// NotModified is set when the product still matches the ETag sent
+	NotModified bool
+}
+
+// retryableError is a failed request worth trying again
+type retryableError struct {
+	err error
+	// after is the delay OFF asked for with Retry-After, if any
+	after time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// GetProduct fetches product details by barcode, nil if OFF does not know it
+func (c *Client) GetProduct(ctx context.Context, barcode string) (*models.Product, error) {
+	result, err := c.Fetch(ctx, barcode, "")
+	if err != nil {
+		return nil, err
+	}
+	return result.Product, nil
+}
+
+// Fetch fetches product details by barcode. With an etag from an earlier
+// fetch, the request is conditional and an unchanged product comes back as
+// NotModified without a body. While OFF keeps failing, Fetch returns
+// ErrUnavailable at once instead of waiting on it.
+func (c *Client) Fetch(ctx context.Context, barcode, etag string) (*FetchResult, error) {
+	if !c.breaker.allow() {
+		requestsTotal.WithLabelValues("rejected").Inc()
+		return nil, ErrUnavailable
+	}
+
+	start := time.Now()
+	callCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	result, err := c.fetchWithRetries(callCtx, barcode, etag)
+
+	var outcome string
+	switch {
+	case err == nil:
+		c.breaker.success()
+		outcome = "found"
+		if result.NotModified {
+			outcome = "not_modified"
+		} else if result.Product == nil {
+			outcome = "not_found"
+		}
+	case ctx.Err() != nil:
+		// The caller gave up, which says nothing about OFF
+		c.breaker.release()
+		outcome = "cancelled"
+	default:
+		c.breaker.failure()
+		outcome = "error"
+		logger.Warn("open food facts lookup failed", "barcode", barcode, "error", err)
+	}
+	requestsTotal.WithLabelValues(outcome).Inc()
+	requestDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+
+	return result, err
+}
+
+func (c *Client) fetchWithRetries(ctx context.Context, barcode, etag string) (*FetchResult, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := c.fetchOnce(ctx, barcode, etag)
+		if err == nil {
+			return result, nil
+		}
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) || attempt > c.config.MaxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := c.retryDelay(attempt, retryable.after)
+		// A retry that cannot finish before the deadline is not worth starting
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay+c.config.AttemptTimeout/2 {
+			return nil, err
+		}
+
+		retriesTotal.Inc()
+		logger.Debug("retrying open food facts request", "barcode", barcode, "attempt", attempt, "delay", delay, "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, err
+		}
 	}
 }
 
-// GetProduct fetches product details by barcode
-func (c *Client) GetProduct(barcode string) (*models.Product, error) {
+// retryDelay is exponential backoff with jitter, or the Retry-After delay
+// when OFF sent one, both capped at RetryMaxDelay
+func (c *Client) retryDelay(attempt int, after time.Duration) time.Duration {
+	if after > 0 {
+		return min(after, c.config.RetryMaxDelay)
+	}
+	return backoff.Delay(c.config.RetryDelay, c.config.RetryMaxDelay, attempt)
+}
+
+func (c *Client) fetchOnce(ctx context.Context, barcode, etag string) (*FetchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.AttemptTimeout)
+	defer cancel()
+
 	// Create request
-	url := fmt.Sprintf("%s/%s.json", BaseURL, barcode)
+	url := fmt.Sprintf("%s/%s.json", c.config.BaseURL, barcode)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		fmt.Printf("OFF Client: Network error fetching %s: %v\n", url, err)
-		return nil, fmt.Errorf("failed to fetch product from OFF: %w", err)
+		return nil, &retryableError{err: fmt.Errorf("failed to fetch product from OFF: %w", err)}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		fmt.Printf("OFF Client: Product not found (404) for %s\n", barcode)
-		return nil, nil // Product not found
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("OFF Client: Unexpected status code %d for %s\n", resp.StatusCode, barcode)
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return &FetchResult{ETag: etag, NotModified: true}, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return &FetchResult{}, nil // Product not found
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, &retryableError{
+			err:   fmt.Errorf("OFF API returned status: %d", resp.StatusCode),
+			after: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	case resp.StatusCode != http.StatusOK:
 		return nil, fmt.Errorf("OFF API returned status: %d", resp.StatusCode)
 	}
 
 	var result ProductResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		fmt.Printf("OFF Client: Decode error for %s: %v\n", barcode, err)
-		return nil, fmt.Errorf("failed to decode OFF response: %w", err)
+		err = fmt.Errorf("failed to decode OFF response: %w", err)
+		// A body cut off by the attempt timeout is worth another try
+		if ctx.Err() != nil {
+			return nil, &retryableError{err: err}
+		}
+		return nil, err
 	}
 
-	// Debug result status
-	// fmt.Printf("OFF Client: Result status: %v (Type: %T)\n", result.Status, result.Status)
-
 	// Check status
 	found := false
 	switch v := result.Status.(type) {
@@ -78,11 +273,28 @@ func (c *Client) GetProduct(barcode string) (*models.Product, error) {
 	}
 
 	if !found || result.Product == nil {
-		fmt.Printf("OFF Client: Product logical not found (status not 1 or nil product) for %s. Status: %v\n", barcode, result.Status)
-		return nil, nil // Not found
+		logger.Debug("open food facts does not know product", "barcode", barcode, "status", result.Status)
+		return &FetchResult{}, nil // Not found
 	}
 
-	return c.mapToModel(barcode, result.Product), nil
+	return &FetchResult{
+		Product: c.mapToModel(barcode, result.Product),
+		ETag:    resp.Header.Get("ETag"),
+	}, nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as a date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
 }
 
 func (c *Client) mapToModel(barcode string, offProduct *Product) *models.Product {
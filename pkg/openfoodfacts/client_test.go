@@ -0,0 +1,299 @@
+package openfoodfacts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const productJSON = `{"status":1,"product":{"product_name":"Teh Botol","brands":"Sosro","nutriscore_grade":"c"}}`
+
+// offServer serves the product API with handler and counts its requests
+func offServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, n int32)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, calls.Add(1))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func testClient(srv *httptest.Server) *Client {
+	return NewClient(Config{
+		BaseURL:          srv.URL + "/api/",
+		MaxRetries:       2,
+		RetryDelay:       10 * time.Millisecond,
+		RetryMaxDelay:    50 * time.Millisecond,
+		BreakerThreshold: 2,
+		BreakerCooldown:  200 * time.Millisecond,
+	})
+}
+
+func TestFetchRetriesServerErrors(t *testing.T) {
+	srv, calls := offServer(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(productJSON))
+	})
+
+	result, err := testClient(srv).Fetch(context.Background(), "8991002101005", "")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if result.Product == nil || result.Product.Name != "Teh Botol" {
+		t.Errorf("Fetch() product = %+v, want Teh Botol", result.Product)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestFetchGivesUpAfterMaxRetries(t *testing.T) {
+	srv, calls := offServer(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	if _, err := testClient(srv).Fetch(context.Background(), "1", ""); err == nil {
+		t.Fatal("Fetch() error = nil, want an error")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestFetchDoesNotRetryClientErrors(t *testing.T) {
+	srv, calls := offServer(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	if _, err := testClient(srv).Fetch(context.Background(), "1", ""); err == nil {
+		t.Fatal("Fetch() error = nil, want an error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestFetchHonoursRetryAfter(t *testing.T) {
+	srv, _ := offServer(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if n == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(productJSON))
+	})
+	client := testClient(srv)
+	client.config.RetryMaxDelay = 2 * time.Second
+
+	start := time.Now()
+	if _, err := client.Fetch(context.Background(), "1", ""); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	client := NewClient(Config{RetryDelay: 100 * time.Millisecond, RetryMaxDelay: time.Second})
+
+	if got := client.retryDelay(1, time.Minute); got != time.Second {
+		t.Errorf("retryDelay() with Retry-After = %s, want it capped at 1s", got)
+	}
+	if got := client.retryDelay(1, 300*time.Millisecond); got != 300*time.Millisecond {
+		t.Errorf("retryDelay() with Retry-After = %s, want 300ms", got)
+	}
+
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, 500 * time.Millisecond, time.Second},
+		{100, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := client.retryDelay(tt.attempt, 0); got < tt.min || got > tt.max {
+				t.Fatalf("retryDelay(%d) = %s, want between %s and %s", tt.attempt, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("3"); got != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %s, want 3s", got)
+	}
+	if got := parseRetryAfter(""); got != 0 {
+		t.Errorf("parseRetryAfter(\"\") = %s, want 0", got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("parseRetryAfter(soon) = %s, want 0", got)
+	}
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 8*time.Second || got > 10*time.Second {
+		t.Errorf("parseRetryAfter(%s) = %s, want about 10s", date, got)
+	}
+}
+
+func TestFetchConditional(t *testing.T) {
+	srv, _ := offServer(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(productJSON))
+	})
+	client := testClient(srv)
+
+	first, err := client.Fetch(context.Background(), "1", "")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if first.NotModified || first.Product == nil || first.ETag != `"v1"` {
+		t.Fatalf("Fetch() = %+v, want the product with ETag \"v1\"", first)
+	}
+
+	second, err := client.Fetch(context.Background(), "1", first.ETag)
+	if err != nil {
+		t.Fatalf("conditional Fetch() error = %v", err)
+	}
+	if !second.NotModified || second.Product != nil || second.ETag != `"v1"` {
+		t.Errorf("conditional Fetch() = %+v, want NotModified keeping the ETag", second)
+	}
+}
+
+func TestFetchNotFound(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request, n int32)
+	}{
+		{"404", func(w http.ResponseWriter, r *http.Request, n int32) {
+			w.WriteHeader(http.StatusNotFound)
+		}},
+		{"status 0", func(w http.ResponseWriter, r *http.Request, n int32) {
+			w.Write([]byte(`{"status":0,"status_verbose":"product not found"}`))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := offServer(t, tt.handler)
+			client := testClient(srv)
+
+			result, err := client.Fetch(context.Background(), "1", "")
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if result.Product != nil || result.NotModified {
+				t.Errorf("Fetch() = %+v, want no product", result)
+			}
+			if client.breaker.failures != 0 {
+				t.Errorf("breaker failures = %d, want 0", client.breaker.failures)
+			}
+		})
+	}
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	var healthy atomic.Bool
+	srv, calls := offServer(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(productJSON))
+	})
+	client := testClient(srv)
+	client.config.MaxRetries = 0
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Fetch(context.Background(), "1", ""); err == nil || errors.Is(err, ErrUnavailable) {
+			t.Fatalf("Fetch() %d error = %v, want the upstream error", i+1, err)
+		}
+	}
+	if _, err := client.Fetch(context.Background(), "1", ""); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Fetch() with open breaker error = %v, want ErrUnavailable", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2, the open breaker must not call OFF", got)
+	}
+
+	// The trial after the cooldown fails and opens the breaker again
+	time.Sleep(250 * time.Millisecond)
+	if _, err := client.Fetch(context.Background(), "1", ""); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("trial Fetch() error = %v, want the upstream error", err)
+	}
+	if _, err := client.Fetch(context.Background(), "1", ""); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Fetch() after failed trial error = %v, want ErrUnavailable", err)
+	}
+
+	// A successful trial closes it
+	healthy.Store(true)
+	time.Sleep(250 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, err := client.Fetch(context.Background(), "1", ""); err != nil {
+			t.Fatalf("Fetch() after recovery error = %v", err)
+		}
+	}
+	if client.breaker.state != breakerClosed {
+		t.Errorf("breaker state = %d, want closed", client.breaker.state)
+	}
+}
+
+func TestBreakerHalfOpenAllowsOneTrial(t *testing.T) {
+	b := newBreaker(1, 10*time.Millisecond)
+	b.failure()
+	if b.allow() {
+		t.Fatal("allow() = true while open")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("allow() = false after the cooldown, want a trial")
+	}
+	if b.allow() {
+		t.Fatal("allow() = true while the trial is running")
+	}
+
+	b.success()
+	if !b.allow() || !b.allow() {
+		t.Error("allow() = false after a successful trial")
+	}
+}
+
+func TestFetchCancelledTrialReleasesBreaker(t *testing.T) {
+	srv, _ := offServer(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		<-r.Context().Done()
+	})
+	client := testClient(srv)
+
+	client.breaker.failure()
+	client.breaker.failure()
+	time.Sleep(250 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := client.Fetch(ctx, "1", ""); err == nil {
+		t.Fatal("cancelled Fetch() error = nil, want an error")
+	}
+
+	if client.breaker.state != breakerHalfOpen {
+		t.Errorf("breaker state = %d, want half-open, a cancelled call says nothing about OFF", client.breaker.state)
+	}
+	if !client.breaker.allow() {
+		t.Error("allow() = false, the cancelled trial must make way for another")
+	}
+}
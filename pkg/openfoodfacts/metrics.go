@@ -0,0 +1,29 @@
+package openfoodfacts
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nutrisnap_off_requests_total",
+		Help: "Open Food Facts product lookups, by outcome",
+	}, []string{"outcome"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nutrisnap_off_request_duration_seconds",
+		Help:    "Time spent on an Open Food Facts product lookup, retries included",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8},
+	}, []string{"outcome"})
+
+	retriesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nutrisnap_off_retries_total",
+		Help: "Open Food Facts requests retried after a network error, 5xx or 429",
+	})
+
+	breakerStateGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "nutrisnap_off_circuit_state",
+		Help: "Open Food Facts circuit breaker state: 0 closed, 1 open, 2 half-open",
+	})
+)
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	stageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nutrisnap_scan_stage_duration_seconds",
+		Help:    "Time spent in each scan pipeline stage",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"stage", "outcome"})
+
+	processingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nutrisnap_scan_processing_duration_seconds",
+		Help:    "Total time to process a scan, excluding queue wait",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"outcome"})
+)
+
+// Observe exports the trace to the stage and processing histograms
+func (t *Trace) Observe(outcome string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	for stage, d := range t.stages {
+		stageDuration.WithLabelValues(string(stage), outcome).Observe(d.Seconds())
+	}
+	t.mu.Unlock()
+
+	processingDuration.WithLabelValues(outcome).Observe(t.Elapsed().Seconds())
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Stage is a step of the scan processing pipeline
+type Stage string
+
+const (
+	StageQueueWait  Stage = "queue_wait"
+	StageDownload   Stage = "download"
+	StagePreprocess Stage = "preprocess"
+	StageOCR        Stage = "ocr"
+	StageParse      Stage = "parse"
+	StageScore      Stage = "score"
+	StagePersist    Stage = "persist"
+)
+
+// Trace accumulates how long a scan spends in each stage. Stages that run
+// more than once, such as OCR on several images, are summed. A nil *Trace
+// is valid and records nothing, so callers never need to check for one.
+type Trace struct {
+	mu      sync.Mutex
+	start   time.Time
+	stages  map[Stage]time.Duration
+	onStage func(Stage)
+	current Stage
+}
+
+// Summary is the stored form of a trace
+type Summary struct {
+	// TotalMs is the processing time, which does not include queue wait
+	TotalMs  int64           `json:"total_ms"`
+	StagesMs map[Stage]int64 `json:"stages_ms"`
+	Outcome  string          `json:"outcome"`
+}
+
+// NewTrace starts a trace at the current time
+func NewTrace() *Trace {
+	return &Trace{
+		start:  time.Now(),
+		stages: make(map[Stage]time.Duration),
+	}
+}
+
+// Add records time spent in a stage
+func (t *Trace) Add(stage Stage, d time.Duration) {
+	if t == nil || d < 0 {
+		return
+	}
+	t.mu.Lock()
+	t.stages[stage] += d
+	t.mu.Unlock()
+}
+
+// OnStage registers fn to be called when a different stage starts being
+// measured, so progress can be reported while the scan is processed
+func (t *Trace) OnStage(fn func(Stage)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.onStage = fn
+	t.mu.Unlock()
+}
+
+// Measure starts timing a stage and returns the func that stops it:
+//
+//	defer trace.Measure(pipeline.StageParse)()
+func (t *Trace) Measure(stage Stage) func() {
+	t.enter(stage)
+	start := time.Now()
+	return func() {
+		t.Add(stage, time.Since(start))
+	}
+}
+
+// enter notifies the stage observer unless the stage is already current,
+// as when OCR runs on several images in a row
+func (t *Trace) enter(stage Stage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	fn := t.onStage
+	changed := t.current != stage
+	t.current = stage
+	t.mu.Unlock()
+
+	if fn != nil && changed {
+		fn(stage)
+	}
+}
+
+// Elapsed is the time since the trace started
+func (t *Trace) Elapsed() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return time.Since(t.start)
+}
+
+// Summarize returns the trace in milliseconds with the outcome of the scan
+func (t *Trace) Summarize(outcome string) Summary {
+	summary := Summary{
+		TotalMs:  t.Elapsed().Milliseconds(),
+		StagesMs: make(map[Stage]int64),
+		Outcome:  outcome,
+	}
+	if t == nil {
+		return summary
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for stage, d := range t.stages {
+		summary.StagesMs[stage] = d.Milliseconds()
+	}
+	return summary
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context carrying the trace
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// FromContext returns the trace carried by ctx, or nil
+func FromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
@@ -26,9 +26,10 @@ type Pagination struct {
 // ErrorDetail contains error information
 // @Description Error details
 type ErrorDetail struct {
-	Code    int    `json:"code" example:"400"`
-	Message string `json:"message" example:"Invalid request"`
-	Field   string `json:"field,omitempty" example:"email"`
+	Code    int               `json:"code" example:"400"`
+	Message string            `json:"message" example:"Invalid request"`
+	Field   string            `json:"field,omitempty" example:"email"`
+	Hint    map[string]string `json:"hint,omitempty"`
 }
 
 // SuccessEnvelope is the standard success response wrapper
@@ -128,6 +129,20 @@ func ErrorWithField(c *fiber.Ctx, code int, message string, field string) error
 	})
 }
 
+// ErrorWithHint sends an error response with an API status code and a
+// user-facing hint keyed by language
+func ErrorWithHint(c *fiber.Ctx, status int, code int, message string, hint map[string]string) error {
+	return c.Status(status).JSON(ErrorEnvelope{
+		Success: false,
+		Error: ErrorDetail{
+			Code:    code,
+			Message: message,
+			Hint:    hint,
+		},
+		Metadata: newMetadata(),
+	})
+}
+
 // ValidationErrors sends multiple validation errors
 func ValidationErrors(c *fiber.Ctx, errors []ErrorDetail) error {
 	return c.Status(fiber.StatusBadRequest).JSON(ErrorEnvelope{
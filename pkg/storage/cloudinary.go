@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/admin"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
-	"github.com/google/uuid"
 )
 
 // CloudinaryConfig holds Cloudinary configuration
@@ -23,10 +27,15 @@ type CloudinaryConfig struct {
 	Folder    string // Folder for uploads (e.g., "nutrisnap/scans")
 }
 
-// CloudinaryClient wraps Cloudinary operations
+// CloudinaryClient stores images in Cloudinary. Refs are the secure
+// delivery URLs Cloudinary returns, as scans have always stored them.
+// Images and their variants are uploaded with authenticated delivery, so
+// they can only be fetched through expiring download URLs. Images
+// uploaded earlier with public delivery stay public.
 type CloudinaryClient struct {
-	cld    *cloudinary.Cloudinary
-	folder string
+	cld        *cloudinary.Cloudinary
+	folder     string
+	httpClient *http.Client
 }
 
 // NewCloudinaryClient creates a new Cloudinary storage client
@@ -50,79 +59,58 @@ func NewCloudinaryClient(cfg CloudinaryConfig) (*CloudinaryClient, error) {
 	return &CloudinaryClient{
 		cld:    cld,
 		folder: cfg.Folder,
+		// Longer timeout for Docker environment
+		httpClient: &http.Client{Timeout: 60 * time.Second},
 	}, nil
 }
 
-// Upload stores a file in Cloudinary and returns the public URL
-func (c *CloudinaryClient) Upload(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) (string, error) {
-	// Create a temp file to upload (Cloudinary SDK requires file path or URL)
-	tmpFile := filepath.Join(os.TempDir(), fmt.Sprintf("upload-%s%s", uuid.New().String(), filepath.Ext(objectName)))
-	file, err := os.Create(tmpFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer func() {
-		file.Close()
-		os.Remove(tmpFile)
-	}()
+const (
+	// downloadURLExpiry bounds the URLs Get fetches private images through
+	downloadURLExpiry = 5 * time.Minute
+	// uploadSignatureTTL is how long Cloudinary accepts a signed upload
+	uploadSignatureTTL = time.Hour
+)
 
-	// Copy reader to temp file
-	if _, err := io.Copy(file, reader); err != nil {
-		return "", fmt.Errorf("failed to write temp file: %w", err)
+// Put uploads a private image and returns its secure URL. Variant refs
+// are accepted as keys and keep their public ID.
+func (c *CloudinaryClient) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) (string, error) {
+	publicID := c.publicID(key)
+	if asset, err := parseDeliveryURL(key); err == nil {
+		publicID = asset.publicID
 	}
-	file.Close()
-
-	// Generate a unique public ID
-	publicID := fmt.Sprintf("%s/%s-%d", c.folder, uuid.New().String(), time.Now().UnixNano())
 
-	// Upload to Cloudinary
-	uploadResult, err := c.cld.Upload.Upload(ctx, tmpFile, uploader.UploadParams{
+	uploadResult, err := c.cld.Upload.Upload(ctx, reader, uploader.UploadParams{
 		PublicID:     publicID,
-		Folder:       "",
 		ResourceType: "image",
+		Type:         api.Authenticated,
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to upload to cloudinary: %w", err)
 	}
-
-	return uploadResult.SecureURL, nil
-}
-
-// Delete removes a file from Cloudinary
-func (c *CloudinaryClient) Delete(ctx context.Context, publicURL string) error {
-	// Extract public ID from URL
-	publicID := extractPublicIDFromURL(publicURL)
-	if publicID == "" {
-		return fmt.Errorf("could not extract public ID from URL: %s", publicURL)
-	}
-
-	_, err := c.cld.Upload.Destroy(ctx, uploader.DestroyParams{
-		PublicID: publicID,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to delete from cloudinary: %w", err)
+	if uploadResult.Error.Message != "" {
+		return "", fmt.Errorf("failed to upload to cloudinary: %s", uploadResult.Error.Message)
 	}
 
-	return nil
+	return canonicalRef(uploadResult.SecureURL), nil
 }
 
-// Download fetches an image from Cloudinary URL with retry logic
-func (c *CloudinaryClient) Download(ctx context.Context, publicURL string) (io.ReadCloser, error) {
-	// Create HTTP client with longer timeout for Docker environment
-	httpClient := &http.Client{
-		Timeout: 60 * time.Second,
+// Get fetches an image from Cloudinary with retry logic
+func (c *CloudinaryClient) Get(ctx context.Context, ref string) (io.ReadCloser, error) {
+	downloadURL, err := c.SignedURL(ctx, ref, downloadURLExpiry)
+	if err != nil {
+		return nil, err
 	}
 
 	var lastErr error
 	maxRetries := 3
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		req, err := http.NewRequestWithContext(ctx, "GET", publicURL, nil)
+		req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
-		resp, err := httpClient.Do(req)
+		resp, err := c.httpClient.Do(req)
 		if err != nil {
 			lastErr = err
 			// Wait before retry
@@ -133,6 +121,12 @@ func (c *CloudinaryClient) Download(ctx context.Context, publicURL string) (io.R
 			return nil, fmt.Errorf("failed to download from cloudinary after %d attempts: %w", maxRetries, err)
 		}
 
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			resp.Body.Close()
+			// Retrying will not bring a deleted image back
+			return nil, fmt.Errorf("%w: cloudinary returned status %d", ErrNotFound, resp.StatusCode)
+		}
+
 		if resp.StatusCode != http.StatusOK {
 			resp.Body.Close()
 			lastErr = fmt.Errorf("cloudinary returned status %d", resp.StatusCode)
@@ -149,20 +143,264 @@ func (c *CloudinaryClient) Download(ctx context.Context, publicURL string) (io.R
 	return nil, lastErr
 }
 
-// GetURL returns the URL directly (Cloudinary URLs are public)
-func (c *CloudinaryClient) GetURL(publicURL string) string {
-	return publicURL
+// Delete removes an image from Cloudinary
+func (c *CloudinaryClient) Delete(ctx context.Context, ref string) error {
+	asset, err := parseDeliveryURL(ref)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.cld.Upload.Destroy(ctx, uploader.DestroyParams{
+		PublicID: asset.publicID,
+		Type:     asset.deliveryType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete from cloudinary: %w", err)
+	}
+	if result.Error.Message != "" {
+		return fmt.Errorf("failed to delete from cloudinary: %s", result.Error.Message)
+	}
+
+	// "not found" means it is already gone, which is what we wanted
+	return nil
+}
+
+// SignedURL returns a download URL that stops working after expiry.
+// Publicly delivered images are returned as they are.
+func (c *CloudinaryClient) SignedURL(ctx context.Context, ref string, expiry time.Duration) (string, error) {
+	asset, err := parseDeliveryURL(ref)
+	if err != nil {
+		return "", err
+	}
+	if asset.deliveryType == string(api.Upload) {
+		return ref, nil
+	}
+
+	expiresAt := time.Now().Add(expiry)
+	url, err := c.cld.Upload.PrivateDownloadURL(uploader.PrivateDownloadURLParams{
+		PublicID:     asset.publicID,
+		Format:       asset.format,
+		DeliveryType: asset.deliveryType,
+		ExpiresAt:    &expiresAt,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign cloudinary URL: %w", err)
+	}
+	return url, nil
+}
+
+// VariantRef names a variant after the public ID of its image, e.g.
+// .../image/authenticated/v123/nutrisnap/scans/u/abc.png ->
+// .../image/authenticated/nutrisnap/scans/u/abc.thumbnail.jpg. Variants
+// are private even for public images, and the ref has no version since
+// the variant is uploaded after its image. List names variants the same
+// way.
+func (c *CloudinaryClient) VariantRef(ref string, variant Variant) string {
+	ref = deliveryTypeSegment.ReplaceAllLiteralString(canonicalRef(ref), "/image/"+string(api.Authenticated)+"/")
+	return VariantRef(dropVersion(ref), variant)
+}
+
+// PresignUpload returns the signed form fields for uploading a private
+// image straight to Cloudinary. Cloudinary stops accepting the signature
+// after an hour, whatever the expiry asked for.
+func (c *CloudinaryClient) PresignUpload(ctx context.Context, key string, contentType string, expiry time.Duration) (*UploadTarget, error) {
+	now := time.Now()
+	params := url.Values{
+		"public_id": {c.publicID(key)},
+		"type":      {string(api.Authenticated)},
+		"timestamp": {strconv.FormatInt(now.Unix(), 10)},
+	}
+	cloud := c.cld.Config.Cloud
+	signature, err := api.SignParametersUsingAlgoAndVersion(params, cloud.APISecret, cloud.GetSignatureAlgorithm(), cloud.GetSignatureVersion())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign cloudinary upload: %w", err)
+	}
+
+	fields := map[string]string{
+		"api_key":   cloud.APIKey,
+		"signature": signature,
+	}
+	for name := range params {
+		fields[name] = params.Get(name)
+	}
+
+	return &UploadTarget{
+		Method:    http.MethodPost,
+		URL:       fmt.Sprintf("%s/%s/image/upload", api.BaseURL(c.cld.Config.API.UploadPrefix, ""), cloud.CloudName),
+		Fields:    fields,
+		FileField: "file",
+		ExpiresAt: now.Add(min(expiry, uploadSignatureTTL)),
+	}, nil
 }
 
-// extractPublicIDFromURL extracts the public ID from a Cloudinary URL
-// e.g., https://res.cloudinary.com/demo/image/upload/v1234567890/folder/image.jpg
-// -> folder/image
-func extractPublicIDFromURL(url string) string {
-	// This is a simplified extraction - in production you might want more robust parsing
-	// Cloudinary URLs follow pattern: .../upload/v{version}/{public_id}.{format}
-	// We need to extract the public_id part
+// UploadedRef looks up the delivery URL of an image uploaded for key
+func (c *CloudinaryClient) UploadedRef(ctx context.Context, key string) (string, error) {
+	result, err := c.cld.Admin.Asset(ctx, admin.AssetParams{
+		PublicID:     c.publicID(key),
+		DeliveryType: api.Authenticated,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to check cloudinary asset: %w", err)
+	}
+	if result.Error.Message != "" {
+		if strings.Contains(strings.ToLower(result.Error.Message), "not found") {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return "", fmt.Errorf("failed to check cloudinary asset: %s", result.Error.Message)
+	}
+
+	return canonicalRef(result.SecureURL), nil
+}
+
+// Exists asks the Admin API whether the image is still stored
+func (c *CloudinaryClient) Exists(ctx context.Context, ref string) (bool, error) {
+	asset, err := parseDeliveryURL(ref)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := c.cld.Admin.Asset(ctx, admin.AssetParams{
+		PublicID:     asset.publicID,
+		DeliveryType: api.DeliveryType(asset.deliveryType),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check cloudinary asset: %w", err)
+	}
+	if result.Error.Message != "" {
+		if strings.Contains(strings.ToLower(result.Error.Message), "not found") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check cloudinary asset: %s", result.Error.Message)
+	}
+
+	return true, nil
+}
+
+// List returns the images whose key starts with prefix, whether public or
+// private
+func (c *CloudinaryClient) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	for _, deliveryType := range []api.DeliveryType{api.Upload, api.Authenticated} {
+		params := admin.AssetsParams{
+			AssetType:    api.Image,
+			DeliveryType: string(deliveryType),
+			Prefix:       c.publicID(prefix),
+			MaxResults:   500,
+		}
+		for {
+			result, err := c.cld.Admin.Assets(ctx, params)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list cloudinary assets: %w", err)
+			}
+			if result.Error.Message != "" {
+				return nil, fmt.Errorf("failed to list cloudinary assets: %s", result.Error.Message)
+			}
+
+			for _, asset := range result.Assets {
+				ref := canonicalRef(asset.SecureURL)
+				if isVariantID(asset.PublicID) {
+					ref = dropVersion(ref)
+				}
+				objects = append(objects, ObjectInfo{
+					Ref:          ref,
+					Size:         int64(asset.Bytes),
+					LastModified: asset.CreatedAt,
+				})
+			}
+
+			if result.NextCursor == "" {
+				break
+			}
+			params.NextCursor = result.NextCursor
+		}
+	}
+
+	return objects, nil
+}
+
+// publicID places a key in the configured folder. Cloudinary adds the
+// format itself, so the extension is dropped.
+func (c *CloudinaryClient) publicID(key string) string {
+	key = strings.TrimSuffix(key, path.Ext(key))
+	if c.folder == "" {
+		return key
+	}
+	return c.folder + "/" + key
+}
+
+var (
+	// deliveryTypeSegment matches the delivery type part of image URLs
+	deliveryTypeSegment = regexp.MustCompile(`/image/(upload|authenticated|private)/`)
+	// versionSegment matches the "v1234567890" path segment of delivery URLs
+	versionSegment = regexp.MustCompile(`^v\d+$`)
+	// signatureSegment matches the "s--abcd1234--" path segment of signed URLs
+	signatureSegment = regexp.MustCompile(`/s--[A-Za-z0-9_-]+--/`)
+)
+
+// deliveryAsset identifies the image a delivery URL points at
+type deliveryAsset struct {
+	deliveryType string
+	publicID     string
+	format       string
+}
+
+// parseDeliveryURL reads the asset out of a Cloudinary URL
+// e.g., https://res.cloudinary.com/demo/image/authenticated/v1234567890/folder/image.jpg
+// -> authenticated, folder/image, jpg
+func parseDeliveryURL(url string) (deliveryAsset, error) {
+	match := deliveryTypeSegment.FindStringSubmatchIndex(url)
+	if match == nil {
+		return deliveryAsset{}, fmt.Errorf("could not extract public ID from URL: %s", url)
+	}
+	rest, _, _ := strings.Cut(url[match[1]:], "?")
+
+	// Signatures and transformations, if any, come before the version
+	segments := strings.Split(rest, "/")
+	for i, segment := range segments {
+		if versionSegment.MatchString(segment) {
+			segments = segments[i+1:]
+			break
+		}
+	}
+
+	publicID := strings.Join(segments, "/")
+	if publicID == "" {
+		return deliveryAsset{}, fmt.Errorf("could not extract public ID from URL: %s", url)
+	}
+	ext := path.Ext(publicID)
+	return deliveryAsset{
+		deliveryType: url[match[2]:match[3]],
+		publicID:     strings.TrimSuffix(publicID, ext),
+		format:       strings.TrimPrefix(ext, "."),
+	}, nil
+}
+
+// dropVersion removes the version segment of a delivery URL
+func dropVersion(url string) string {
+	match := deliveryTypeSegment.FindStringIndex(url)
+	if match == nil {
+		return url
+	}
+	if version, rest, ok := strings.Cut(url[match[1]:], "/"); ok && versionSegment.MatchString(version) {
+		return url[:match[1]] + rest
+	}
+	return url
+}
+
+// isVariantID reports whether a public ID is one VariantRef names
+func isVariantID(publicID string) bool {
+	for _, variant := range Variants {
+		if strings.HasSuffix(publicID, "."+string(variant)) {
+			return true
+		}
+	}
+	return false
+}
 
-	// For now, we'll store the full URL and use it directly for deletion
-	// The proper approach is to store the public_id separately in the database
-	return ""
+// canonicalRef drops the signature Cloudinary puts in URLs of private
+// images. Stored refs are not meant to grant access, and the same image
+// is then named the same way by uploads and listings.
+func canonicalRef(url string) string {
+	return signatureSegment.ReplaceAllString(url, "/")
 }
@@ -0,0 +1,58 @@
+package storage
+
+import "testing"
+
+func TestCloudinaryVariantRef(t *testing.T) {
+	c := &CloudinaryClient{}
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{
+			name: "private",
+			ref:  "https://res.cloudinary.com/demo/image/authenticated/v1700000000/nutrisnap/scans/u/abc.png",
+			want: "https://res.cloudinary.com/demo/image/authenticated/nutrisnap/scans/u/abc.thumbnail.jpg",
+		},
+		{
+			name: "public",
+			ref:  "https://res.cloudinary.com/demo/image/upload/v1700000000/nutrisnap/scans/u/abc.jpg",
+			want: "https://res.cloudinary.com/demo/image/authenticated/nutrisnap/scans/u/abc.thumbnail.jpg",
+		},
+		{
+			name: "signed",
+			ref:  "https://res.cloudinary.com/demo/image/authenticated/s--abc123--/v1700000000/v2/abc.jpg",
+			want: "https://res.cloudinary.com/demo/image/authenticated/v2/abc.thumbnail.jpg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.VariantRef(tt.ref, VariantThumbnail)
+			if got != tt.want {
+				t.Errorf("VariantRef() = %q, want %q", got, tt.want)
+			}
+
+			asset, err := parseDeliveryURL(got)
+			if err != nil {
+				t.Fatalf("parseDeliveryURL() error = %v", err)
+			}
+			if asset.deliveryType != "authenticated" || !isVariantID(asset.publicID) || asset.format != "jpg" {
+				t.Errorf("variant asset = %+v, want a private jpg variant", asset)
+			}
+		})
+	}
+}
+
+func TestCloudinaryListedVariantRef(t *testing.T) {
+	c := &CloudinaryClient{}
+	image := "https://res.cloudinary.com/demo/image/authenticated/v1700000000/nutrisnap/scans/u/abc.jpg"
+	// Cloudinary lists the variant with the version of its own upload
+	listed := dropVersion("https://res.cloudinary.com/demo/image/authenticated/v1700000042/nutrisnap/scans/u/abc.medium.jpg")
+
+	if got := c.VariantRef(image, VariantMedium); got != listed {
+		t.Errorf("VariantRef() = %q, listed as %q", got, listed)
+	}
+	if isVariantID("nutrisnap/scans/u/abc") {
+		t.Error("isVariantID() of an image = true, want false")
+	}
+}
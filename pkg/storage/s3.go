@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config holds S3 or MinIO configuration
+type S3Config struct {
+	Endpoint  string
+	PublicURL string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	UseSSL    bool
+}
+
+// S3Store stores objects in an S3 compatible bucket such as MinIO. Refs are
+// object keys.
+type S3Store struct {
+	client       *minio.Client
+	publicClient *minio.Client // Client configured with public endpoint for presigned URLs
+	bucket       string
+}
+
+// NewS3Store creates a new S3 storage client
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	// Main client for internal operations (upload, download, etc.)
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	// Create a second client for presigned URLs using public endpoint
+	var publicClient *minio.Client
+	if cfg.PublicURL != "" {
+		// Parse public URL to get host
+		parsedURL, err := url.Parse(cfg.PublicURL)
+		if err == nil && parsedURL.Host != "" {
+			publicClient, err = minio.New(parsedURL.Host, &minio.Options{
+				Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+				Secure: strings.HasPrefix(cfg.PublicURL, "https"),
+			})
+			if err != nil {
+				// If public client fails, we'll fall back to main client
+				publicClient = nil
+			}
+		}
+	}
+
+	return &S3Store{
+		client:       client,
+		publicClient: publicClient,
+		bucket:       cfg.Bucket,
+	}, nil
+}
+
+// EnsureBucket creates the bucket if it doesn't exist
+func (s *S3Store) EnsureBucket(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket: %w", err)
+	}
+
+	if !exists {
+		err = s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create bucket: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Put stores an object in the bucket
+func (s *S3Store) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) (string, error) {
+	_, err := s.client.PutObject(ctx, s.bucket, key, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	return key, nil
+}
+
+// Get retrieves an object from the bucket
+func (s *S3Store) Get(ctx context.Context, ref string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, ref, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	// GetObject is lazy, so stat it to report a missing object now
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if isNoSuchKey(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+		}
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return object, nil
+}
+
+// Delete removes an object from the bucket
+func (s *S3Store) Delete(ctx context.Context, ref string) error {
+	err := s.client.RemoveObject(ctx, s.bucket, ref, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	return nil
+}
+
+// SignedURL generates a presigned URL for temporary access
+func (s *S3Store) SignedURL(ctx context.Context, ref string, expiry time.Duration) (string, error) {
+	reqParams := make(url.Values)
+
+	// Use public client if available (generates URLs with correct public host)
+	clientToUse := s.client
+	if s.publicClient != nil {
+		clientToUse = s.publicClient
+	}
+
+	presignedURL, err := clientToUse.PresignedGetObject(ctx, s.bucket, ref, expiry, reqParams)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+
+	return presignedURL.String(), nil
+}
+
+// PresignUpload returns a presigned PUT URL. The content type is part of
+// the signature, so the client has to send the one it declared.
+func (s *S3Store) PresignUpload(ctx context.Context, key string, contentType string, expiry time.Duration) (*UploadTarget, error) {
+	clientToUse := s.client
+	if s.publicClient != nil {
+		clientToUse = s.publicClient
+	}
+
+	expiresAt := time.Now().Add(expiry)
+	headers := http.Header{"Content-Type": {contentType}}
+	presignedURL, err := clientToUse.PresignHeader(ctx, http.MethodPut, s.bucket, key, expiry, nil, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate presigned upload URL: %w", err)
+	}
+
+	return &UploadTarget{
+		Method:    http.MethodPut,
+		URL:       presignedURL.String(),
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// UploadedRef returns key once an object has been uploaded under it
+func (s *S3Store) UploadedRef(ctx context.Context, key string) (string, error) {
+	exists, err := s.Exists(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return key, nil
+}
+
+// Exists checks if an object exists in the bucket
+func (s *S3Store) Exists(ctx context.Context, ref string) (bool, error) {
+	_, err := s.client.StatObject(ctx, s.bucket, ref, minio.StatObjectOptions{})
+	if err != nil {
+		if isNoSuchKey(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check object: %w", err)
+	}
+
+	return true, nil
+}
+
+// List lists all objects with a given prefix
+func (s *S3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	objectCh := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+
+	for object := range objectCh {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		objects = append(objects, ObjectInfo{
+			Ref:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		})
+	}
+
+	return objects, nil
+}
+
+func isNoSuchKey(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// Backend names accepted in configuration
+const (
+	BackendCloudinary = "cloudinary"
+	BackendS3         = "s3"
+	BackendLocal      = "local"
+)
+
+// ErrNotFound is returned when the requested object no longer exists
+var ErrNotFound = errors.New("object not found")
+
+// ObjectStore is implemented by every storage backend. Put returns the ref
+// an object is addressed by afterwards, which is what callers persist:
+// the delivery URL for Cloudinary and the object key for S3 and local
+// storage.
+type ObjectStore interface {
+	// Put stores an object under key and returns its ref
+	Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) (string, error)
+	// Get opens an object, returning ErrNotFound when it is gone
+	Get(ctx context.Context, ref string) (io.ReadCloser, error)
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, ref string) error
+	// SignedURL returns a URL clients can fetch the object from until expiry
+	SignedURL(ctx context.Context, ref string, expiry time.Duration) (string, error)
+	// Exists reports whether an object is stored
+	Exists(ctx context.Context, ref string) (bool, error)
+	// List returns the objects whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Ref          string
+	Size         int64
+	LastModified time.Time
+}
+
+// DirectUploader is implemented by backends clients can upload to
+// directly, so large files do not pass through the API
+type DirectUploader interface {
+	// PresignUpload returns a target for uploading one object under key
+	PresignUpload(ctx context.Context, key string, contentType string, expiry time.Duration) (*UploadTarget, error)
+	// UploadedRef returns the ref of the object uploaded for key, or
+	// ErrNotFound when nothing has been uploaded yet
+	UploadedRef(ctx context.Context, key string) (string, error)
+}
+
+// UploadTarget tells a client how to upload an object
+type UploadTarget struct {
+	// Method is PUT for a raw body or POST for a multipart form
+	Method string
+	URL    string
+	// Headers must be sent with the upload exactly as given
+	Headers map[string]string
+	// Fields are the form fields of POST uploads, sent before the file
+	Fields map[string]string
+	// FileField names the form field holding the file in POST uploads
+	FileField string
+	ExpiresAt time.Time
+}
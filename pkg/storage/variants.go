@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	// Register decoders for uploaded label photos
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Variant names a downscaled rendition of a stored image
+type Variant string
+
+const (
+	VariantThumbnail Variant = "thumbnail"
+	VariantMedium    Variant = "medium"
+)
+
+// Variants lists every variant, smallest first
+var Variants = []Variant{VariantThumbnail, VariantMedium}
+
+// variantSizes is the long side of each variant in pixels. Images that are
+// already smaller are not scaled up.
+var variantSizes = map[Variant]int{
+	VariantThumbnail: 256,
+	VariantMedium:    1024,
+}
+
+// variantQuality is the JPEG quality variants are encoded with
+const variantQuality = 80
+
+// VariantNamer is implemented by backends whose refs are not object keys,
+// to name the variants of their images. Their Put accepts the refs it
+// returns as keys.
+type VariantNamer interface {
+	// VariantRef returns the ref a variant of the image at ref is stored
+	// under
+	VariantRef(ref string, variant Variant) string
+}
+
+// StoreVariants resizes an image stored under ref and stores each variant
+// next to it
+func StoreVariants(ctx context.Context, store ObjectStore, ref string, data []byte) error {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// Each variant is scaled from the previous one, which is much cheaper
+	// than scaling a full size photo twice
+	stored := make([]string, 0, len(Variants))
+	for i := len(Variants) - 1; i >= 0; i-- {
+		variant := Variants[i]
+		src = resize(src, variantSizes[variant])
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: variantQuality}); err != nil {
+			discard(ctx, store, stored)
+			return fmt.Errorf("failed to encode %s variant: %w", variant, err)
+		}
+
+		variantRef := variantRef(store, ref, variant)
+		if _, err := store.Put(ctx, variantRef, &buf, int64(buf.Len()), "image/jpeg"); err != nil {
+			discard(ctx, store, stored)
+			return fmt.Errorf("failed to store %s variant: %w", variant, err)
+		}
+		stored = append(stored, variantRef)
+	}
+
+	return nil
+}
+
+// RestoreVariants renders the variants of an image stored under ref again,
+// for images whose variants were lost or never stored
+func RestoreVariants(ctx context.Context, store ObjectStore, ref string) error {
+	reader, err := store.Get(ctx, ref)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+	return StoreVariants(ctx, store, ref, data)
+}
+
+// VariantURL returns a URL to a variant of the image at ref that stops
+// working after expiry
+func VariantURL(ctx context.Context, store ObjectStore, ref string, variant Variant, expiry time.Duration) (string, error) {
+	return store.SignedURL(ctx, variantRef(store, ref, variant), expiry)
+}
+
+// VariantRef returns the ref a variant of the image at ref is stored under
+// by backends whose refs are object keys, e.g. scans/u/abc.png ->
+// scans/u/abc.thumbnail.jpg
+func VariantRef(ref string, variant Variant) string {
+	return strings.TrimSuffix(ref, path.Ext(ref)) + "." + string(variant) + ".jpg"
+}
+
+// StoredVariantRefs returns the refs the variants of ref are stored under
+func StoredVariantRefs(store ObjectStore, ref string) []string {
+	refs := make([]string, len(Variants))
+	for i, variant := range Variants {
+		refs[i] = variantRef(store, ref, variant)
+	}
+	return refs
+}
+
+// variantRef names a variant in the way of the store
+func variantRef(store ObjectStore, ref string, variant Variant) string {
+	if namer, ok := store.(VariantNamer); ok {
+		return namer.VariantRef(ref, variant)
+	}
+	return VariantRef(ref, variant)
+}
+
+// DeleteImage removes an image together with its variants. Every object is
+// attempted, and the first failure is returned.
+func DeleteImage(ctx context.Context, store ObjectStore, ref string) error {
+	var firstErr error
+	for _, r := range append([]string{ref}, StoredVariantRefs(store, ref)...) {
+		if err := store.Delete(ctx, r); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// discard removes variants stored before a later one failed
+func discard(ctx context.Context, store ObjectStore, refs []string) {
+	for _, ref := range refs {
+		_ = store.Delete(ctx, ref)
+	}
+}
+
+// resize scales src so its long side is at most maxSide, onto a white
+// background since JPEG has no transparency
+func resize(src image.Image, maxSide int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	scale := 1.0
+	if long := max(w, h); long > maxSide {
+		scale = float64(maxSide) / float64(long)
+	}
+	dw, dh := max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.BiLinear.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
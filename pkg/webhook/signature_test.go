@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() error = %v", err)
+	}
+	body := []byte(`{"event":"scan.completed"}`)
+	header := Sign(secret, time.Now(), body)
+
+	if err := Verify(secret, header, body, 5*time.Minute); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	// Receivers may add spaces after the comma
+	if err := Verify(secret, strings.Replace(header, ",", ", ", 1), body, 5*time.Minute); err != nil {
+		t.Errorf("Verify() with spaces error = %v", err)
+	}
+}
+
+func TestSignFormat(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte("payload")
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000.payload"))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("whsec_test", at, body); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"event":"scan.completed"}`)
+	now := time.Now()
+	valid := Sign(secret, now, body)
+	ts, sig, _ := strings.Cut(valid, ",")
+	// A captured signature sent again under a fresh timestamp
+	replayed := "t=" + strconv.FormatInt(now.Unix()+1, 10) + "," + sig
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		want   error
+	}{
+		{"wrong secret", "whsec_other", valid, body, ErrInvalidSignature},
+		{"tampered body", secret, valid, []byte(`{"event":"scan.failed"}`), ErrInvalidSignature},
+		{"too old", secret, Sign(secret, now.Add(-10*time.Minute), body), body, ErrSignatureExpired},
+		{"too far ahead", secret, Sign(secret, now.Add(10*time.Minute), body), body, ErrSignatureExpired},
+		{"replayed signature", secret, replayed, body, ErrInvalidSignature},
+		{"missing signature", secret, ts, body, ErrInvalidSignature},
+		{"missing timestamp", secret, sig, body, ErrInvalidSignature},
+		{"bad timestamp", secret, "t=soon," + sig, body, ErrInvalidSignature},
+		{"empty", secret, "", body, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() error = %v", err)
+	}
+	b, _ := NewSecret()
+
+	if !strings.HasPrefix(a, secretPrefix) || len(a) != len(secretPrefix)+64 {
+		t.Errorf("NewSecret() = %q, want %s and 64 hex characters", a, secretPrefix)
+	}
+	if a == b {
+		t.Error("NewSecret() returned the same secret twice")
+	}
+}
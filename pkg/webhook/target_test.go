@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := IsPublicAddress(netip.MustParseAddr(tt.addr)); got != tt.public {
+				t.Errorf("IsPublicAddress(%s) = %v, want %v", tt.addr, got, tt.public)
+			}
+		})
+	}
+}
+
+func TestCheckHostRejectsLiteralAndLocalhost(t *testing.T) {
+	ctx := context.Background()
+	for _, host := range []string{"127.0.0.1", "169.254.169.254", "::1", "localhost"} {
+		if err := CheckHost(ctx, host); !errors.Is(err, ErrForbiddenTarget) {
+			t.Errorf("CheckHost(%q) = %v, want ErrForbiddenTarget", host, err)
+		}
+	}
+	if err := CheckHost(ctx, "93.184.216.34"); err != nil {
+		t.Errorf("CheckHost(public literal) = %v, want nil", err)
+	}
+}
+
+func TestDialerRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = NewDialer(time.Second).DialContext
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the dialer to refuse a loopback receiver")
+	}
+	if !errors.Is(err, ErrForbiddenTarget) {
+		t.Errorf("error = %v, want ErrForbiddenTarget", err)
+	}
+}